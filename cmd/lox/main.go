@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hrumst/gox-lox/lib"
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/lox"
+)
+
+const usage = `usage: lox [options] [script | -]
+       lox [options] -e <source>
+       lox test [test options] [path ...]
+
+options:
+  -backend tree|vm          run scripts on the tree-walking interpreter or the bytecode VM
+  -color auto|always|never  colorize diagnostics
+  -max-steps n              stop the script after n statements (VM instructions)
+  -max-depth n              raise a stack overflow when calls nest deeper than n
+  -max-memory bytes         stop the script after allocating about that many bytes
+  -timeout duration         stop the script after running for duration, e.g. 500ms
+  -path dirs                directories searched for imported modules, $LOX_PATH by default
+
+Without arguments lox starts an interactive prompt when stdin is a terminal
+and runs the script read from stdin otherwise. Run lox test -h for the
+options of the test runner.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) > 0 && args[0] == "test" {
+		return runTests(args[1:])
+	}
+
+	flags := flag.NewFlagSet("lox", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	source := flags.String("e", "", "execute `source` instead of a script file")
+	backendName := flags.String("backend", "tree", "run scripts on the tree-walking interpreter (tree) or the bytecode VM (vm)")
+	color := flags.String("color", "auto", "colorize diagnostics: auto, always or never")
+	maxSteps := flags.Int("max-steps", 0, "stop the script after `n` statements, 0 means no limit")
+	maxDepth := flags.Int("max-depth", interpret.DefaultMaxCallDepth, "raise a stack overflow when calls nest deeper than `n`")
+	maxMemory := flags.Int64("max-memory", 0, "stop the script after allocating about `bytes`, 0 means no limit")
+	timeout := flags.Duration("timeout", 0, "stop the script after running for `duration`, 0 means no limit")
+	modulePath := flags.String("path", os.Getenv("LOX_PATH"), "`dirs` searched for imported modules, separated like PATH")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return lib.ExitOK
+		}
+		return lib.ExitUsage
+	}
+
+	var useColor bool
+	switch *color {
+	case "auto":
+		useColor = os.Getenv("NO_COLOR") == "" && isTerminal(os.Stderr)
+	case "always":
+		useColor = true
+	case "never":
+		useColor = false
+	default:
+		flags.Usage()
+		return lib.ExitUsage
+	}
+
+	backend, ok := parseBackend(*backendName)
+	if !ok {
+		flags.Usage()
+		return lib.ExitUsage
+	}
+
+	var name, script string
+	switch {
+	case isFlagSet(flags, "e"):
+		if flags.NArg() != 0 {
+			flags.Usage()
+			return lib.ExitUsage
+		}
+		name, script = "-e", *source
+	case flags.NArg() > 1:
+		flags.Usage()
+		return lib.ExitUsage
+	case flags.NArg() == 1 && flags.Arg(0) != "-":
+		input, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error on reading input:", err)
+			return lib.ExitNoInput
+		}
+		name, script = flags.Arg(0), string(input)
+	case flags.NArg() == 0 && isTerminal(os.Stdin):
+		lib.RunPrompt(useColor)
+		return lib.ExitOK
+	default:
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error on reading input:", err)
+			return lib.ExitIOErr
+		}
+		name, script = "<stdin>", string(input)
+	}
+
+	runtime := lox.New(
+		lox.WithBackend(backend),
+		lox.WithColor(useColor),
+		lox.WithMaxSteps(*maxSteps),
+		lox.WithMaxCallDepth(*maxDepth),
+		lox.WithMaxMemory(*maxMemory),
+		lox.WithTimeout(*timeout),
+		lox.WithModulePath(filepath.SplitList(*modulePath)...),
+	)
+	err := runtime.RunSource(name, script)
+	if err != nil {
+		runtime.ReportError(err)
+	}
+	return lib.ExitCode(err)
+}
+
+func parseBackend(name string) (lox.Backend, bool) {
+	switch name {
+	case "tree":
+		return lox.TreeWalker, true
+	case "vm":
+		return lox.VM, true
+	}
+	return lox.TreeWalker, false
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	found := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hrumst/gox-lox/lib"
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/lox"
+	"github.com/hrumst/gox-lox/lib/loxtest"
+)
+
+// exitTestsFailed is returned when some test fails, like go test does.
+const exitTestsFailed = 1
+
+const testUsage = `usage: lox test [options] [path ...]
+
+Runs the test_* functions of the *_test.lox files found in the paths
+(the current directory by default), each test in a fresh interpreter with
+assert(cond, msg?), assertEqual(expected, actual, msg?) and fail(msg?) defined.
+
+options:
+  -v                  list passed tests and the output of every test
+  -junit file         also write the report as JUnit XML to file
+  -backend tree|vm    run tests on the tree-walking interpreter or the bytecode VM
+  -max-steps n        fail a test after n statements (VM instructions)
+  -max-depth n        raise a stack overflow when calls nest deeper than n
+  -max-memory bytes   fail a test after allocating about that many bytes
+  -timeout duration   fail a test after running for duration, e.g. 500ms
+  -path dirs          directories searched for imported modules, $LOX_PATH by default
+`
+
+func runTests(args []string) int {
+	flags := flag.NewFlagSet("lox test", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, testUsage)
+	}
+	verbose := flags.Bool("v", false, "list passed tests and the output of every test")
+	junit := flags.String("junit", "", "write the report as JUnit XML to `file`")
+	backendName := flags.String("backend", "tree", "run tests on the tree-walking interpreter (tree) or the bytecode VM (vm)")
+	maxSteps := flags.Int("max-steps", 0, "fail a test after `n` statements, 0 means no limit")
+	maxDepth := flags.Int("max-depth", interpret.DefaultMaxCallDepth, "raise a stack overflow when calls nest deeper than `n`")
+	maxMemory := flags.Int64("max-memory", 0, "fail a test after allocating about `bytes`, 0 means no limit")
+	timeout := flags.Duration("timeout", 0, "fail a test after running for `duration`, 0 means no limit")
+	modulePath := flags.String("path", os.Getenv("LOX_PATH"), "`dirs` searched for imported modules, separated like PATH")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return lib.ExitOK
+		}
+		return lib.ExitUsage
+	}
+	backend, ok := parseBackend(*backendName)
+	if !ok {
+		flags.Usage()
+		return lib.ExitUsage
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	runner := loxtest.NewRunner(
+		lox.WithBackend(backend),
+		lox.WithMaxSteps(*maxSteps),
+		lox.WithMaxCallDepth(*maxDepth),
+		lox.WithMaxMemory(*maxMemory),
+		lox.WithTimeout(*timeout),
+		lox.WithModulePath(filepath.SplitList(*modulePath)...),
+	)
+	report, err := runner.Run(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error on finding tests:", err)
+		return lib.ExitNoInput
+	}
+
+	if err := report.WriteText(os.Stdout, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, "error on writing report:", err)
+		return lib.ExitIOErr
+	}
+	if *junit != "" {
+		if err := writeJUnit(report, *junit); err != nil {
+			fmt.Fprintln(os.Stderr, "error on writing report:", err)
+			return lib.ExitIOErr
+		}
+	}
+	if !report.Ok() {
+		return exitTestsFailed
+	}
+	return lib.ExitOK
+}
+
+func writeJUnit(report *loxtest.Report, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJUnit(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,156 @@
+package diagnostics
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type Kind string
+
+const (
+	ScanKind    Kind = "scan error"
+	ParseKind   Kind = "parse error"
+	ResolveKind Kind = "resolve error"
+	RuntimeKind Kind = "runtime error"
+	LimitKind   Kind = "execution limit"
+	OtherKind   Kind = "error"
+)
+
+// Diagnostic is a single reportable problem. Position is meaningful only
+// when HasPosition is set; errors without a token have no source location.
+// Trace lists Lox call frames of a runtime error, innermost first.
+type Diagnostic struct {
+	Kind        Kind
+	Code        string
+	Message     string
+	Hint        string
+	Position    scan.Position
+	HasPosition bool
+	Trace       []string
+}
+
+type codeRule struct {
+	kind    Kind
+	pattern string
+	code    string
+	hint    string
+}
+
+// codeRules are matched in order against the error message, the first
+// matching rule of the error kind wins.
+var codeRules = []codeRule{
+	{ScanKind, "unterminated string", "E0101", "close the string with '\"'"},
+	{ScanKind, "comment not terminated", "E0102", "close the comment with '*/'"},
+	{ScanKind, "unexpected character", "E0103", "remove the character or put it into a string"},
+	{ScanKind, "", "E0100", ""},
+
+	{ParseKind, "expect ';'", "E0201", "add ';' at the end of the statement"},
+	{ParseKind, "unexpected token type", "E0203", "an expression is expected here"},
+	{ParseKind, "expect", "E0202", ""},
+	{ParseKind, "invalid assignment target", "E0204", "only variables and properties can be assigned"},
+	{ParseKind, "can't have more", "E0205", "pass fewer than 256 values"},
+	{ParseKind, "", "E0200", ""},
+
+	{ResolveKind, "already variable with this name", "E0301", "rename one of the variables"},
+	{ResolveKind, "in its own initializer", "E0302", "use another name for the new variable"},
+	{ResolveKind, "can't return from top-level code", "E0303", "'return' is allowed only inside functions"},
+	{ResolveKind, "can't return a value from an initializer", "E0304", "use a bare 'return;' in 'init'"},
+	{ResolveKind, "can't use 'this'", "E0305", "'this' is allowed only inside class methods"},
+	{ResolveKind, "can't use 'super'", "E0306", "'super' is allowed only inside methods of a subclass"},
+	{ResolveKind, "can't inherit from itself", "E0307", ""},
+	{ResolveKind, "outside of a loop", "E0308", "'break' and 'continue' are allowed only inside loops"},
+	{ResolveKind, "can't import outside of top-level code", "E0309", "move the import to the top level of the script"},
+	{ResolveKind, "", "E0300", ""},
+
+	{RuntimeKind, "undefined variable", "E0401", "declare the variable with 'var' before using it"},
+	{RuntimeKind, "zero division error", "E0402", "check the divisor before dividing"},
+	{RuntimeKind, "is not a number", "E0403", "operands must be numbers"},
+	{RuntimeKind, "can only call functions or classes", "E0404", ""},
+	{RuntimeKind, "arguments but got", "E0405", "check the function declaration for its parameters"},
+	{RuntimeKind, "undefined property", "E0406", ""},
+	{RuntimeKind, "only instances have", "E0407", ""},
+	{RuntimeKind, "uncaught exception", "E0408", "wrap the code in try/catch to handle the exception"},
+	{RuntimeKind, "stack overflow", "E0409", "check that the recursion reaches its base case"},
+	{RuntimeKind, "module \"", "E0410", "modules are searched next to the importing script, then in the module path"},
+	{RuntimeKind, "import cycle", "E0411", "move the shared code into a module both can import"},
+	{RuntimeKind, "", "E0400", ""},
+
+	{LimitKind, "step limit exceeded", "E0501", "the script ran more statements than allowed, look for endless loops"},
+	{LimitKind, "execution timed out", "E0502", "the script ran longer than allowed, look for endless loops"},
+	{LimitKind, "memory limit exceeded", "E0503", "the script allocated more memory than allowed"},
+	{LimitKind, "", "E0500", ""},
+}
+
+// FromError converts an error returned by any stage of the pipeline into
+// diagnostics. ParseErrors produce one diagnostic per syntax error.
+func FromError(err error) []Diagnostic {
+	var (
+		parseErrs  parse.ParseErrors
+		scanErr    *scan.ScanError
+		parseErr   *parse.ParseError
+		resolveErr *interpret.ResolveError
+		runtimeErr *interpret.RuntimeError
+		limitErr   *interpret.LimitError
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &parseErrs):
+		diagnostics := make([]Diagnostic, 0, len(parseErrs))
+		for _, parseErr := range parseErrs {
+			diagnostics = append(diagnostics, fromParseError(parseErr))
+		}
+		return diagnostics
+	case errors.As(err, &scanErr):
+		return []Diagnostic{newDiagnostic(ScanKind, errors.Unwrap(scanErr).Error(), scanErr.Position(), true)}
+	case errors.As(err, &parseErr):
+		return []Diagnostic{fromParseError(parseErr)}
+	case errors.As(err, &resolveErr):
+		return []Diagnostic{fromToken(ResolveKind, resolveErr.Message(), resolveErr.Token())}
+	case errors.As(err, &runtimeErr):
+		diagnostic := fromToken(RuntimeKind, runtimeErr.Message(), runtimeErr.Token())
+		for _, frame := range runtimeErr.Trace() {
+			diagnostic.Trace = append(diagnostic.Trace, frame.String())
+		}
+		return []Diagnostic{diagnostic}
+	case errors.As(err, &limitErr):
+		return []Diagnostic{fromToken(LimitKind, limitErr.Message(), limitErr.Token())}
+	}
+	return []Diagnostic{newDiagnostic(OtherKind, err.Error(), scan.Position{}, false)}
+}
+
+func fromParseError(err *parse.ParseError) Diagnostic {
+	message := errors.Unwrap(err).Error()
+	if err.Token().Type == scan.EOF {
+		message = message + " at end"
+	}
+	return newDiagnostic(ParseKind, message, err.Token().Position, true)
+}
+
+func fromToken(kind Kind, message string, token *scan.Token) Diagnostic {
+	if token == nil {
+		return newDiagnostic(kind, message, scan.Position{}, false)
+	}
+	return newDiagnostic(kind, message, token.Position, true)
+}
+
+func newDiagnostic(kind Kind, message string, position scan.Position, hasPosition bool) Diagnostic {
+	diagnostic := Diagnostic{
+		Kind:        kind,
+		Message:     message,
+		Position:    position,
+		HasPosition: hasPosition,
+	}
+	for _, rule := range codeRules {
+		if rule.kind == kind && strings.Contains(message, rule.pattern) {
+			diagnostic.Code, diagnostic.Hint = rule.code, rule.hint
+			break
+		}
+	}
+	return diagnostic
+}
@@ -0,0 +1,156 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[1;31m"
+	colorBlue  = "\x1b[1;34m"
+	colorCyan  = "\x1b[1;36m"
+)
+
+// Renderer prints diagnostics with an excerpt of the source they point at:
+//
+//	error[E0201]: parse error: expect ';' after value
+//	  --> script.lox:2:8
+//	   |
+//	 2 | print a
+//	   |        ^
+//	   = hint: add ';' at the end of the statement
+//
+// Runtime errors additionally get their Lox stack trace listed.
+type Renderer struct {
+	color   bool
+	sources map[string][]string
+}
+
+func NewRenderer(color bool) *Renderer {
+	return &Renderer{
+		color:   color,
+		sources: make(map[string][]string),
+	}
+}
+
+// AddSource registers the text of file so diagnostics pointing into it get a source excerpt.
+func (r *Renderer) AddSource(file, source string) {
+	r.sources[file] = strings.Split(source, "\n")
+}
+
+func (r *Renderer) Render(w io.Writer, diagnostics []Diagnostic) error {
+	for i, diagnostic := range diagnostics {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, r.render(diagnostic)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderError is a shortcut for rendering all diagnostics of err.
+func (r *Renderer) RenderError(w io.Writer, err error) error {
+	return r.Render(w, FromError(err))
+}
+
+func (r *Renderer) render(diagnostic Diagnostic) string {
+	var sb strings.Builder
+
+	header := "error"
+	if diagnostic.Code != "" {
+		header = fmt.Sprintf("error[%s]", diagnostic.Code)
+	}
+	sb.WriteString(r.paint(colorRed, header))
+	sb.WriteString(r.paint(colorBold, fmt.Sprintf(": %s: %s", diagnostic.Kind, diagnostic.Message)))
+	sb.WriteString("\n")
+
+	gutter := ""
+	if diagnostic.HasPosition {
+		lineNumber := strconv.Itoa(diagnostic.Position.Line)
+		gutter = strings.Repeat(" ", len(lineNumber))
+		sb.WriteString(fmt.Sprintf("%s%s %s\n", gutter, r.paint(colorBlue, "-->"), diagnostic.Position))
+
+		if line, ok := r.sourceLine(diagnostic.Position.File, diagnostic.Position.Line); ok {
+			sb.WriteString(fmt.Sprintf("%s %s\n", gutter, r.paint(colorBlue, "|")))
+			sb.WriteString(fmt.Sprintf("%s %s %s\n", r.paint(colorBlue, lineNumber), r.paint(colorBlue, "|"), line))
+			sb.WriteString(fmt.Sprintf(
+				"%s %s %s\n",
+				gutter,
+				r.paint(colorBlue, "|"),
+				r.paint(colorRed, caretLine(line, diagnostic)),
+			))
+		}
+	}
+
+	if diagnostic.Hint != "" {
+		sb.WriteString(fmt.Sprintf("%s %s %s\n", gutter, r.paint(colorCyan, "="), "hint: "+diagnostic.Hint))
+	}
+	// a lone script frame repeats the position shown above
+	if len(diagnostic.Trace) > 1 {
+		sb.WriteString(fmt.Sprintf("%s %s %s\n", gutter, r.paint(colorCyan, "="), "stack trace:"))
+		for _, frame := range diagnostic.Trace {
+			sb.WriteString(fmt.Sprintf("%s     %s\n", gutter, frame))
+		}
+	}
+	return sb.String()
+}
+
+func (r *Renderer) sourceLine(file string, line int) (string, bool) {
+	lines, ok := r.sources[file]
+	if !ok || line < 1 || line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[line-1], "\r"), true
+}
+
+// caretLine underlines the diagnostic span, keeping tabs of the source line
+// so the carets stay aligned. Spans running past the line end are clipped.
+func caretLine(line string, diagnostic Diagnostic) string {
+	lineRunes := []rune(line)
+	column := diagnostic.Position.Column - 1
+	if column < 0 {
+		column = 0
+	}
+	if column > len(lineRunes) {
+		column = len(lineRunes)
+	}
+
+	var sb strings.Builder
+	for _, char := range lineRunes[:column] {
+		if char == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+
+	width, spanBytes := 0, diagnostic.Position.End-diagnostic.Position.Start
+	for _, char := range lineRunes[column:] {
+		if spanBytes <= 0 {
+			break
+		}
+		spanBytes -= utf8.RuneLen(char)
+		width += 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	sb.WriteString(strings.Repeat("^", width))
+	return sb.String()
+}
+
+func (r *Renderer) paint(color, text string) string {
+	if !r.color {
+		return text
+	}
+	return color + text + colorReset
+}
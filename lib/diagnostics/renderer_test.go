@@ -0,0 +1,125 @@
+package diagnostics
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func runSource(source string) error {
+	tokens, err := scan.NewFileScanner("script.lox", source).ScanTokens()
+	if err != nil {
+		return err
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		return err
+	}
+	interpreter := interpret.NewInterpreter(bytes.NewBufferString(""))
+	if err := interpret.NewResolver(interpreter).Resolve(stmts); err != nil {
+		return err
+	}
+	return interpreter.Interpret(stmts)
+}
+
+func TestRenderer_Render(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			source: "var s = \"ok\";\nvar t = \"never closed;",
+			expected: "error[E0101]: scan error: unterminated string\n" +
+				" --> script.lox:2:9\n" +
+				"  |\n" +
+				"2 | var t = \"never closed;\n" +
+				"  |         ^^^^^^^^^^^^^^\n" +
+				"  = hint: close the string with '\"'\n",
+		}, {
+			source: "print 1\nvar x = 2;\n\tprint 2 +;",
+			expected: "error[E0201]: parse error: expect ';' after value\n" +
+				" --> script.lox:2:1\n" +
+				"  |\n" +
+				"2 | var x = 2;\n" +
+				"  | ^^^\n" +
+				"  = hint: add ';' at the end of the statement\n" +
+				"\n" +
+				"error[E0203]: parse error: unexpected token type\n" +
+				" --> script.lox:3:11\n" +
+				"  |\n" +
+				"3 | \tprint 2 +;\n" +
+				"  | \t         ^\n" +
+				"  = hint: an expression is expected here\n",
+		}, {
+			source: "{ var ж = 1; var ж = 2; }",
+			expected: "error[E0301]: resolve error: already variable with this name in this scope\n" +
+				" --> script.lox:1:18\n" +
+				"  |\n" +
+				"1 | { var ж = 1; var ж = 2; }\n" +
+				"  |                  ^\n" +
+				"  = hint: rename one of the variables\n",
+		}, {
+			source: "if (true) break;",
+			expected: "error[E0308]: resolve error: can't use 'break' outside of a loop\n" +
+				" --> script.lox:1:11\n" +
+				"  |\n" +
+				"1 | if (true) break;\n" +
+				"  |           ^^^^^\n" +
+				"  = hint: 'break' and 'continue' are allowed only inside loops\n",
+		}, {
+			source: "var a = 10;\nprint a / (a - 10);",
+			expected: "error[E0402]: runtime error: evaluate expression error: zero division error\n" +
+				" --> script.lox:2:9\n" +
+				"  |\n" +
+				"2 | print a / (a - 10);\n" +
+				"  |         ^\n" +
+				"  = hint: check the divisor before dividing\n",
+		}, {
+			source: "fun inner(x) {\n  return x.field;\n}\nfun outer() {\n  return inner(1);\n}\nouter();",
+			expected: "error[E0407]: runtime error: only instances have properties\n" +
+				" --> script.lox:2:12\n" +
+				"  |\n" +
+				"2 |   return x.field;\n" +
+				"  |            ^^^^^\n" +
+				"  = stack trace:\n" +
+				"      [script.lox:2:12] in inner()\n" +
+				"      [script.lox:5:17] in outer()\n" +
+				"      [script.lox:7:7] in script\n",
+		}, {
+			source: "print 1",
+			expected: "error[E0201]: parse error: expect ';' after value at end\n" +
+				" --> script.lox:1:8\n" +
+				"  |\n" +
+				"1 | print 1\n" +
+				"  |        ^\n" +
+				"  = hint: add ';' at the end of the statement\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("renderer_test_case_%d", i),
+			func(t *testing.T) {
+				renderer := NewRenderer(false)
+				renderer.AddSource("script.lox", tc.source)
+				buf := bytes.NewBufferString("")
+				assert.NoError(t, renderer.RenderError(buf, runSource(tc.source)))
+				assert.Equal(t, tc.expected, buf.String())
+			},
+		)
+	}
+}
+
+func TestRenderer_RenderColor(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	err := NewRenderer(true).Render(buf, []Diagnostic{{Kind: OtherKind, Message: "boom"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[1;31merror\x1b[0m\x1b[1m: error: boom\x1b[0m\n", buf.String())
+}
@@ -11,43 +11,63 @@ type AstPrinter struct {
 }
 
 func (v *AstPrinter) VisitSuperExpr(expr *parse.SuperExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return v.parenthesize("super", expr.Method.Lexeme)
 }
 
 func (v *AstPrinter) VisitGetExpr(expr *parse.GetExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return v.parenthesize(".", expr.Object, expr.Name.Lexeme)
 }
 
 func (v *AstPrinter) VisitSetExpr(expr *parse.SetExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return v.parenthesize("=", expr.Object, expr.Name.Lexeme, expr.Value)
 }
 
 func (v *AstPrinter) VisitThisExpr(expr *parse.ThisExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return expr.Keyword.Lexeme, nil
 }
 
 func (v *AstPrinter) VisitCallExpr(expr *parse.CallExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	parts := []interface{}{expr.Callee}
+	for _, arg := range expr.Arguments {
+		parts = append(parts, arg)
+	}
+	return v.parenthesize("call", parts...)
 }
 
 func (v *AstPrinter) VisitLogicalExpr(expr *parse.LogicalExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return v.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
 
 func (v *AstPrinter) VisitVariableExpr(expr *parse.VariableExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return expr.Name.Lexeme, nil
 }
 
 func (v *AstPrinter) VisitAssignExpr(expr *parse.AssignExpression) (interface{}, error) {
-	//TODO implement me
-	panic("implement me")
+	return v.parenthesize("=", expr.Name.Lexeme, expr.Value)
+}
+
+func (v *AstPrinter) VisitListExpr(expr *parse.ListExpression) (interface{}, error) {
+	parts := make([]interface{}, 0, len(expr.Elements))
+	for _, element := range expr.Elements {
+		parts = append(parts, element)
+	}
+	return v.parenthesize("list", parts...)
+}
+
+func (v *AstPrinter) VisitIndexExpr(expr *parse.IndexExpression) (interface{}, error) {
+	return v.parenthesize("[]", expr.Object, expr.Index)
+}
+
+func (v *AstPrinter) VisitIndexSetExpr(expr *parse.IndexSetExpression) (interface{}, error) {
+	return v.parenthesize("[]=", expr.Object, expr.Index, expr.Value)
+}
+
+func (v *AstPrinter) VisitMapExpr(expr *parse.MapExpression) (interface{}, error) {
+	parts := make([]interface{}, 0, 2*len(expr.Keys))
+	for idx, key := range expr.Keys {
+		parts = append(parts, key, expr.Values[idx])
+	}
+	return v.parenthesize("map", parts...)
 }
 
 func NewAstPrinter(isReverseNotation bool) *AstPrinter {
@@ -61,29 +81,59 @@ func (v *AstPrinter) Print(expr parse.Expression) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return acpt.(string), nil
+	return v.format(acpt), nil
 }
 
-func (v *AstPrinter) parenthesize(name string, expressions ...parse.Expression) (string, error) {
+// PrintStmts prints every statement on its own line.
+func (v *AstPrinter) PrintStmts(stmts []parse.Statement) (string, error) {
+	lines := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		acpt, err := stmt.Accept(v)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, v.format(acpt))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (v *AstPrinter) format(acpt interface{}) string {
+	switch actt := acpt.(type) {
+	case *scan.Literal:
+		return actt.Value.String()
+	case string:
+		return actt
+	}
+	return ""
+}
+
+// parenthesize accepts expressions, statements and plain strings (names, keywords) as parts.
+func (v *AstPrinter) parenthesize(name string, parts ...interface{}) (string, error) {
 	var sb strings.Builder
 	sb.WriteString("(")
 	if !v.isReverseNotation {
 		sb.WriteString(name)
 	}
-	for _, expr := range expressions {
+	for _, part := range parts {
 		if !v.isReverseNotation {
 			sb.WriteString(" ")
 		}
-		acpt, err := expr.Accept(v)
+		var (
+			acpt interface{}
+			err  error
+		)
+		switch pt := part.(type) {
+		case parse.Expression:
+			acpt, err = pt.Accept(v)
+		case parse.Statement:
+			acpt, err = pt.Accept(v)
+		default:
+			acpt = pt
+		}
 		if err != nil {
 			return "", err
 		}
-		switch actt := acpt.(type) {
-		case *scan.Literal:
-			sb.WriteString(actt.Value.String())
-		case string:
-			sb.WriteString(actt)
-		}
+		sb.WriteString(v.format(acpt))
 
 		if v.isReverseNotation {
 			sb.WriteString(" ")
@@ -114,3 +164,102 @@ func (v *AstPrinter) VisitLiteralExpr(expr *parse.LiteralExpression) (interface{
 func (v *AstPrinter) VisitUnaryExpr(expr *parse.UnaryExpression) (interface{}, error) {
 	return v.parenthesize(expr.Operator.Lexeme, expr.Right)
 }
+
+func (v *AstPrinter) VisitStmtExpression(stmt *parse.StmtExpression) (interface{}, error) {
+	return v.parenthesize(";", stmt.Expression)
+}
+
+func (v *AstPrinter) VisitStmtPrint(stmt *parse.StmtPrint) (interface{}, error) {
+	return v.parenthesize("print", stmt.Expression)
+}
+
+func (v *AstPrinter) VisitStmtVar(stmt *parse.StmtVar) (interface{}, error) {
+	if stmt.Initializer == nil {
+		return v.parenthesize("var", stmt.Name.Lexeme)
+	}
+	return v.parenthesize("var", stmt.Name.Lexeme, stmt.Initializer)
+}
+
+func (v *AstPrinter) VisitStmtBlock(stmt *parse.StmtBlock) (interface{}, error) {
+	return v.parenthesize("block", v.stmtParts(stmt.Stmts)...)
+}
+
+func (v *AstPrinter) VisitStmtIf(stmt *parse.StmtIf) (interface{}, error) {
+	if stmt.ElseBranch == nil {
+		return v.parenthesize("if", stmt.Condition, stmt.ThenBranch)
+	}
+	return v.parenthesize("if", stmt.Condition, stmt.ThenBranch, stmt.ElseBranch)
+}
+
+func (v *AstPrinter) VisitStmtWhile(stmt *parse.StmtWhile) (interface{}, error) {
+	if stmt.Increment != nil {
+		return v.parenthesize("while", stmt.Condition, stmt.Body, stmt.Increment)
+	}
+	return v.parenthesize("while", stmt.Condition, stmt.Body)
+}
+
+func (v *AstPrinter) VisitStmtExecuteControl(stmt *parse.StmtExecuteControl) (interface{}, error) {
+	return v.parenthesize(stmt.Control.Lexeme)
+}
+
+func (v *AstPrinter) VisitStmtFunction(stmt *parse.StmtFunction) (interface{}, error) {
+	params := make([]string, 0, len(stmt.Params))
+	for _, param := range stmt.Params {
+		params = append(params, param.Lexeme)
+	}
+	parts := []interface{}{stmt.Name.Lexeme, "(" + strings.Join(params, " ") + ")"}
+	return v.parenthesize("fun", append(parts, v.stmtParts(stmt.Body)...)...)
+}
+
+func (v *AstPrinter) VisitStmtReturn(stmt *parse.StmtReturn) (interface{}, error) {
+	if stmt.Value == nil {
+		return v.parenthesize("return")
+	}
+	return v.parenthesize("return", stmt.Value)
+}
+
+func (v *AstPrinter) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error) {
+	parts := []interface{}{stmt.Name.Lexeme}
+	if stmt.SuperClass != nil {
+		parts = append(parts, "<", stmt.SuperClass.Name.Lexeme)
+	}
+	return v.parenthesize("class", append(parts, v.stmtParts(stmt.Methods)...)...)
+}
+
+func (v *AstPrinter) VisitStmtThrow(stmt *parse.StmtThrow) (interface{}, error) {
+	return v.parenthesize("throw", stmt.Value)
+}
+
+func (v *AstPrinter) VisitStmtImport(stmt *parse.StmtImport) (interface{}, error) {
+	if stmt.Name != nil {
+		return v.parenthesize("import", stmt.Name.Lexeme, stmt.Path.Lexeme)
+	}
+	return v.parenthesize("import", stmt.Path.Lexeme)
+}
+
+func (v *AstPrinter) VisitStmtTry(stmt *parse.StmtTry) (interface{}, error) {
+	parts := []interface{}{parse.NewStmtBlock(stmt.TryBlock)}
+	if stmt.CatchName != nil {
+		catch, err := v.parenthesize("catch", append([]interface{}{stmt.CatchName.Lexeme}, v.stmtParts(stmt.CatchBlock)...)...)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, catch)
+	}
+	if stmt.FinallyBlock != nil {
+		finally, err := v.parenthesize("finally", v.stmtParts(stmt.FinallyBlock)...)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, finally)
+	}
+	return v.parenthesize("try", parts...)
+}
+
+func (v *AstPrinter) stmtParts(stmts []parse.Statement) []interface{} {
+	parts := make([]interface{}, 0, len(stmts))
+	for _, stmt := range stmts {
+		parts = append(parts, stmt)
+	}
+	return parts
+}
@@ -49,3 +49,40 @@ func TestAstPrinter(t *testing.T) {
 		)
 	}
 }
+
+func TestAstPrinter_PrintStmts(t *testing.T) {
+	// fun add(a, b) { return a + b; } print add(1, 2);
+	stmts := []parse.Statement{
+		parse.NewStmtFunction(
+			scan.NewToken(scan.IDENTIFIER, "add", nil, 1),
+			[]scan.Token{
+				scan.NewToken(scan.IDENTIFIER, "a", nil, 1),
+				scan.NewToken(scan.IDENTIFIER, "b", nil, 1),
+			},
+			[]parse.Statement{
+				parse.NewStmtReturn(
+					scan.NewToken(scan.RETURN, "return", nil, 1),
+					parse.NewBinaryExpression(
+						parse.NewVariableExpression(scan.NewToken(scan.IDENTIFIER, "a", nil, 1)),
+						scan.NewToken(scan.PLUS, "+", nil, 1),
+						parse.NewVariableExpression(scan.NewToken(scan.IDENTIFIER, "b", nil, 1)),
+					),
+				),
+			},
+		),
+		parse.NewStmtPrint(
+			parse.NewCallExpression(
+				parse.NewVariableExpression(scan.NewToken(scan.IDENTIFIER, "add", nil, 1)),
+				scan.NewToken(scan.RIGHT_PAREN, ")", nil, 1),
+				[]parse.Expression{
+					parse.NewLiteralExpression(scan.NewLiteral(scan.NewFloatLoxValue(1.))),
+					parse.NewLiteralExpression(scan.NewLiteral(scan.NewFloatLoxValue(2.))),
+				},
+			),
+		),
+	}
+
+	result, err := NewAstPrinter(false).PrintStmts(stmts)
+	assert.NoError(t, err)
+	assert.Equal(t, "(fun add (a b) (return (+ a b)))\n(print (call add 1 2))", result)
+}
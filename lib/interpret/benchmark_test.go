@@ -0,0 +1,45 @@
+package interpret
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+func benchmarkSource(b *testing.B, source string) {
+	tokens, err := scan.NewScanner(source).ScanTokens()
+	if err != nil {
+		b.Fatal(err)
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n += 1 {
+		interpreter := NewInterpreter(io.Discard)
+		if err := NewResolver(interpreter).Resolve(stmts); err != nil {
+			b.Fatal(err)
+		}
+		if err := interpreter.Interpret(stmts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInterpreter_Fib(b *testing.B) {
+	benchmarkSource(b, `fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(20);`)
+}
+
+func BenchmarkInterpreter_Loop(b *testing.B) {
+	benchmarkSource(b, `fun loop() {
+  var sum = 0;
+  for (var i = 0; i < 100000; i = i + 1) { var twice = i * 2; sum = sum + twice; }
+  return sum;
+}
+print loop();`)
+}
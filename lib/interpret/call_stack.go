@@ -0,0 +1,54 @@
+package interpret
+
+import (
+	"errors"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type callFrame struct {
+	function string
+	callSite scan.Token
+}
+
+// pushFrame records a call of a Lox function or class. Native functions
+// have no Lox-level frame, an error inside them is reported at the call site.
+func (i *Interpreter) pushFrame(callee scan.LoxCallable, callSite scan.Token) bool {
+	var name string
+	switch calleeType := callee.(type) {
+	case *LoxFunction:
+		name = calleeType.declaration.Name.Lexeme
+	case *LoxClass:
+		name = calleeType.declaration.Name.Lexeme
+	default:
+		return false
+	}
+	i.callStack = append(i.callStack, callFrame{function: name, callSite: callSite})
+	return true
+}
+
+func (i *Interpreter) popFrame() {
+	i.callStack = i.callStack[:len(i.callStack)-1]
+}
+
+// attachTrace stores the current call stack in a runtime error that doesn't have one yet.
+// Being called on the way out of the innermost frame it captures the full chain.
+func (i *Interpreter) attachTrace(err error) error {
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.trace != nil {
+		return err
+	}
+
+	position := scan.Position{}
+	if runtimeErr.token != nil {
+		position = runtimeErr.token.Position
+	}
+	trace := make([]StackFrame, 0, len(i.callStack)+1)
+	for frame := len(i.callStack) - 1; frame >= 0; frame -= 1 {
+		trace = append(trace, StackFrame{Function: i.callStack[frame].function, Position: position})
+		position = i.callStack[frame].callSite.Position
+	}
+	trace = append(trace, StackFrame{Function: ScriptFrameName, Position: position})
+	runtimeErr.SetTrace(trace)
+	return err
+}
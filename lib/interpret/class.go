@@ -32,6 +32,9 @@ func (l *LoxClass) String() string {
 }
 
 func (l *LoxClass) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	if err := l.interpreter.limits.Allocate(InstanceSize, &l.declaration.Name); err != nil {
+		return nil, err
+	}
 	instance := NewLoxClassInstance(l)
 	initializer := l.findMethod("init")
 	if initializer != nil {
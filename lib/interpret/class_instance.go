@@ -32,6 +32,21 @@ func (li *LoxClassInstance) Get(name scan.Token) (*scan.LoxValue, error) {
 	return nil, NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), &name)
 }
 
-func (li *LoxClassInstance) Set(name scan.Token, value *scan.LoxValue) {
+// Fields returns a copy of the fields set on the instance.
+func (li *LoxClassInstance) Fields() map[string]*scan.LoxValue {
+	fields := make(map[string]*scan.LoxValue, len(li.fields))
+	for name, value := range li.fields {
+		fields[name] = value
+	}
+	return fields
+}
+
+func (li *LoxClassInstance) Set(name scan.Token, value *scan.LoxValue) error {
+	if _, ok := li.fields[name.Lexeme]; !ok {
+		if err := li.class.interpreter.limits.Allocate(FieldSize, &name); err != nil {
+			return err
+		}
+	}
 	li.fields[name.Lexeme] = value
+	return nil
 }
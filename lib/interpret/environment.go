@@ -1,12 +1,21 @@
 package interpret
 
 import (
+	"sort"
+
 	"github.com/hrumst/gox-lox/lib/scan"
 )
 
+// Environment is a scope of variables. The global and script scopes keep
+// values by name, the local scopes (blocks, calls) keep them in slots in
+// declaration order, and resolved expressions read them by the slot index
+// assigned by the Resolver.
 type Environment struct {
 	values    map[string]*scan.LoxValue
+	slots     []*scan.LoxValue
 	enclosing *Environment
+	// inline backs the slots of small scopes, so most of them take one allocation
+	inline [4]*scan.LoxValue
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
@@ -16,7 +25,21 @@ func NewEnvironment(enclosing *Environment) *Environment {
 	}
 }
 
+// newLocalEnvironment creates a slot based scope, its names are known to the
+// Resolver only.
+func newLocalEnvironment(enclosing *Environment) *Environment {
+	env := &Environment{enclosing: enclosing}
+	env.slots = env.inline[:0]
+	return env
+}
+
+// Define adds a name to the scope, a local scope takes the next slot, so
+// names must be defined in the order the Resolver declared them.
 func (e *Environment) Define(name string, value *scan.LoxValue) {
+	if e.values == nil {
+		e.slots = append(e.slots, value)
+		return
+	}
 	e.values[name] = value
 }
 
@@ -28,7 +51,7 @@ func (e *Environment) Get(token scan.Token) (*scan.LoxValue, error) {
 	if e.enclosing != nil {
 		return e.enclosing.Get(token)
 	}
-	return nil, NewRuntimeError("undefined variable", &token)
+	return nil, undefinedVariable(token)
 }
 
 func (e *Environment) Assign(token scan.Token, value *scan.LoxValue) error {
@@ -39,7 +62,38 @@ func (e *Environment) Assign(token scan.Token, value *scan.LoxValue) error {
 	if e.enclosing != nil {
 		return e.enclosing.Assign(token, value)
 	}
-	return NewRuntimeError("undefined variable", &token)
+	return undefinedVariable(token)
+}
+
+// Enclosing returns the parent scope, nil for the outermost one.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// Names returns names defined directly in this scope in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the value defined directly in this scope.
+func (e *Environment) Lookup(name string) (*scan.LoxValue, bool) {
+	value, ok := e.values[name]
+	return value, ok
+}
+
+// script returns the outermost scope below the globals, the script scope
+// of the module the environment belongs to.
+func (e *Environment) script() *Environment {
+	env := e
+	for env.enclosing != nil && env.enclosing.enclosing != nil {
+		env = env.enclosing
+	}
+	return env
 }
 
 func (e *Environment) ancestor(distance int) *Environment {
@@ -50,10 +104,32 @@ func (e *Environment) ancestor(distance int) *Environment {
 	return env
 }
 
-func (e *Environment) getAt(distance int, token scan.Token) (*scan.LoxValue, error) {
-	return e.ancestor(distance).Get(token)
+func (e *Environment) getAt(local local, token scan.Token) (*scan.LoxValue, error) {
+	env := e.ancestor(local.depth)
+	if env.values != nil {
+		return env.Get(token)
+	}
+	if local.slot >= len(env.slots) {
+		return nil, undefinedVariable(token)
+	}
+	return env.slots[local.slot], nil
 }
 
-func (e *Environment) assignAt(distance int, name scan.Token, value *scan.LoxValue) {
-	e.ancestor(distance).values[name.Lexeme] = value
+func (e *Environment) assignAt(local local, token scan.Token, value *scan.LoxValue) error {
+	env := e.ancestor(local.depth)
+	if env.values != nil {
+		env.values[token.Lexeme] = value
+		return nil
+	}
+	if local.slot >= len(env.slots) {
+		return undefinedVariable(token)
+	}
+	env.slots[local.slot] = value
+	return nil
+}
+
+// undefinedVariable takes the token by value, so that only the failing
+// lookups move it to the heap.
+func undefinedVariable(token scan.Token) error {
+	return NewRuntimeError("undefined variable", &token)
 }
@@ -1,13 +1,49 @@
 package interpret
 
 import (
+	"errors"
 	"fmt"
 	"github.com/hrumst/gox-lox/lib/scan"
+	"strings"
 )
 
+// ScriptFrameName is the function name of the outermost frame, the top-level code.
+const ScriptFrameName = "script"
+
+// maxTraceFrames caps the frames kept in a stack trace, the middle of
+// deeper traces (e.g. of a stack overflow) is replaced by a single frame.
+const maxTraceFrames = 50
+
+// StackFrame is a Lox-level call frame: the function and the position
+// execution was at inside it. Position.Line is 0 when the position is unknown.
+// A frame with Omitted set stands for that many frames left out of a deep trace.
+type StackFrame struct {
+	Function string
+	Position scan.Position
+	Omitted  int
+}
+
+func (sf StackFrame) String() string {
+	if sf.Omitted > 0 {
+		return fmt.Sprintf("... %d frames omitted", sf.Omitted)
+	}
+	name := sf.Function
+	if name != ScriptFrameName {
+		name = name + "()"
+	}
+	if sf.Position.Line == 0 {
+		return fmt.Sprintf("[unknown] in %s", name)
+	}
+	return fmt.Sprintf("[%s] in %s", sf.Position, name)
+}
+
+// RuntimeError is an error raised while executing a script. Errors created
+// by a throw statement carry the thrown value.
 type RuntimeError struct {
 	message string
 	token   *scan.Token
+	trace   []StackFrame
+	value   *scan.LoxValue
 }
 
 func NewRuntimeError(message string, token *scan.Token) *RuntimeError {
@@ -17,17 +53,105 @@ func NewRuntimeError(message string, token *scan.Token) *RuntimeError {
 	}
 }
 
-func ConvertToRuntimeError(message string, err error, token *scan.Token) *RuntimeError {
+func NewThrowError(value *scan.LoxValue, token *scan.Token) *RuntimeError {
+	return &RuntimeError{
+		message: fmt.Sprintf("uncaught exception: %s", value.String()),
+		token:   token,
+		value:   value,
+	}
+}
+
+// ConvertToRuntimeError keeps errors that are already runtime errors as is,
+// so the innermost position and stack trace are not lost. Limit errors stay
+// as they are too, a runtime error would make them catchable.
+func ConvertToRuntimeError(message string, err error, token *scan.Token) error {
+	var runtimeErr *RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return runtimeErr
+	}
+	if isLimitError(err) {
+		return err
+	}
 	return NewRuntimeError(
 		fmt.Sprintf("%s: %s", message, err.Error()),
 		token,
 	)
 }
 
+func (re *RuntimeError) Message() string {
+	return re.message
+}
+
+// Token returns the token the error is reported at, nil when unknown.
+func (re *RuntimeError) Token() *scan.Token {
+	return re.token
+}
+
+// Value returns the value a catch clause binds: the thrown value, or the
+// message as a string for errors raised by the interpreter itself.
+func (re *RuntimeError) Value() *scan.LoxValue {
+	if re.value != nil {
+		return re.value
+	}
+	return scan.NewStringLoxValue(re.message)
+}
+
+// Trace returns the call stack at the moment of the error, innermost frame first.
+func (re *RuntimeError) Trace() []StackFrame {
+	return re.trace
+}
+
+// SetTrace stores the call stack of the error, innermost frame first.
+func (re *RuntimeError) SetTrace(trace []StackFrame) {
+	if len(trace) > maxTraceFrames {
+		omitted := len(trace) - maxTraceFrames + 1
+		tail := trace[len(trace)-maxTraceFrames/2:]
+		trace = append(trace[:maxTraceFrames/2-1:maxTraceFrames/2-1], StackFrame{Omitted: omitted})
+		trace = append(trace, tail...)
+	}
+	re.trace = trace
+}
+
 func (re *RuntimeError) Error() string {
 	errStr := re.message
 	if re.token != nil {
-		errStr = errStr + fmt.Sprintf("\nat line: %d, token: %s", re.token.Line, re.token.Lexeme)
+		errStr = errStr + fmt.Sprintf("\nat %s, token: %s", re.token.Position, re.token.Lexeme)
+	}
+	if len(re.trace) > 1 {
+		frames := make([]string, 0, len(re.trace))
+		for _, frame := range re.trace {
+			frames = append(frames, "  "+frame.String())
+		}
+		errStr = errStr + "\nstack trace:\n" + strings.Join(frames, "\n")
+	}
+	return errStr
+}
+
+type ResolveError struct {
+	message string
+	token   *scan.Token
+}
+
+func NewResolveError(message string, token *scan.Token) *ResolveError {
+	return &ResolveError{
+		message: message,
+		token:   token,
+	}
+}
+
+func (re *ResolveError) Message() string {
+	return re.message
+}
+
+// Token returns the token the error is reported at, nil when unknown.
+func (re *ResolveError) Token() *scan.Token {
+	return re.token
+}
+
+func (re *ResolveError) Error() string {
+	errStr := re.message
+	if re.token != nil {
+		errStr = errStr + fmt.Sprintf("\nat %s, token: %s", re.token.Position, re.token.Lexeme)
 	}
 	return errStr
 }
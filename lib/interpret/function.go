@@ -7,9 +7,11 @@ import (
 )
 
 type LoxFunction struct {
-	interpreter   *Interpreter
-	declaration   *parse.StmtFunction
-	closure       *Environment
+	interpreter *Interpreter
+	declaration *parse.StmtFunction
+	closure     *Environment
+	// script is the script environment of the module the function is declared in
+	script        *Environment
 	isInitializer bool
 }
 
@@ -23,6 +25,7 @@ func NewLoxFunction(
 		interpreter:   interpreter,
 		declaration:   declaration,
 		closure:       closure,
+		script:        closure.script(),
 		isInitializer: isInitializer,
 	}
 }
@@ -36,7 +39,20 @@ func (l *LoxFunction) Arity() int {
 }
 
 func (l *LoxFunction) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
-	environment := NewEnvironment(l.closure)
+	if err := l.interpreter.limits.Check(&l.declaration.Name); err != nil {
+		return nil, err
+	}
+	if err := l.interpreter.limits.EnterCall(&l.declaration.Name); err != nil {
+		return nil, err
+	}
+	// late-bound top-level names are looked up in the module of the function
+	prevScript := l.interpreter.script
+	l.interpreter.script = l.script
+	defer func() {
+		l.interpreter.script = prevScript
+		l.interpreter.limits.ExitCall()
+	}()
+	environment := newLocalEnvironment(l.closure)
 	for i, param := range l.declaration.Params {
 		environment.Define(param.Lexeme, args[i])
 	}
@@ -52,14 +68,14 @@ func (l *LoxFunction) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
 
 	if l.isInitializer {
 		//todo make refactor token -> string
-		this := scan.NewToken(scan.THIS, "this", nil, l.declaration.Name.Line)
-		return l.closure.getAt(0, this)
+		this := scan.Token{Type: scan.THIS, Lexeme: "this", Position: l.declaration.Name.Position}
+		return l.closure.getAt(local{}, this)
 	}
 	return scan.NewNilLoxValue(), nil
 }
 
 func (l *LoxFunction) bind(instance *LoxClassInstance) *LoxFunction {
-	environment := NewEnvironment(l.closure)
+	environment := newLocalEnvironment(l.closure)
 	environment.Define("this", scan.NewClassInstanceLoxValue(instance))
 	return NewLoxFunction(l.interpreter, l.declaration, environment, l.isInitializer)
 }
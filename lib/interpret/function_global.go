@@ -1,8 +1,10 @@
 package interpret
 
 import (
+	"fmt"
 	"github.com/hrumst/gox-lox/lib/scan"
 	"time"
+	"unicode/utf8"
 )
 
 type ClockFunction struct {
@@ -24,3 +26,60 @@ func (c ClockFunction) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
 	timeMs := time.Now().Unix()
 	return scan.NewFloatLoxValue(float64(timeMs)), nil
 }
+
+// NativeFunc is the Go implementation of a native function.
+type NativeFunc func(args []*scan.LoxValue) (*scan.LoxValue, error)
+
+// NativeFunction is a callable implemented in Go: global natives and
+// methods of built-in value types bound to their receiver.
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    NativeFunc
+}
+
+// NewNativeFunction creates a native function, arity is scan.VariadicArity
+// for functions accepting any number of arguments.
+func NewNativeFunction(name string, arity int, fn NativeFunc) *NativeFunction {
+	return &NativeFunction{
+		name:  name,
+		arity: arity,
+		fn:    fn,
+	}
+}
+
+func (n *NativeFunction) String() string {
+	return fmt.Sprintf("[function] %s", n.name)
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	return n.fn(args)
+}
+
+// Builtins returns the native functions every script can call.
+func Builtins() map[string]scan.LoxCallable {
+	return map[string]scan.LoxCallable{
+		"clock": NewClockFunction(),
+		"len":   newLenFunction(),
+	}
+}
+
+func newLenFunction() *NativeFunction {
+	return NewNativeFunction("len", 1, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+		switch {
+		case args[0].IsList():
+			list, _ := args[0].List()
+			return scan.NewFloatLoxValue(float64(list.Len())), nil
+		case args[0].IsMap():
+			loxMap, _ := args[0].Map()
+			return scan.NewFloatLoxValue(float64(loxMap.Len())), nil
+		case args[0].IsString():
+			return scan.NewFloatLoxValue(float64(utf8.RuneCountInString(args[0].String()))), nil
+		}
+		return nil, fmt.Errorf("len() argument must be a list, a map or a string")
+	})
+}
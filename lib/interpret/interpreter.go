@@ -9,31 +9,88 @@ import (
 type Interpreter struct {
 	writer      io.Writer
 	environment *Environment
+	script      *Environment
 	globals     *Environment
-	locals      map[parse.Expression]int
+	locals      map[parse.Expression]local
+	callStack   []callFrame
+	limits      Limits
+	// loader finds and reads imported modules
+	loader ModuleLoader
+	// modules caches imported modules by path
+	modules map[string]*Module
+	// importing is the chain of modules being run, to detect import cycles
+	importing []string
 }
 
-func NewInterpreter(writer io.Writer) *Interpreter {
+func NewInterpreter(writer io.Writer, options ...InterpreterOption) *Interpreter {
 	globalFuncs := NewEnvironment(nil)
-	globalFuncs.Define("clock", scan.NewCallableLoxValue(NewClockFunction()))
+	for name, native := range Builtins() {
+		globalFuncs.Define(name, scan.NewCallableLoxValue(native))
+	}
 
-	return &Interpreter{
+	// top-level declarations live in the script scope, names the resolver
+	// left unresolved are looked up there late, so top-level functions may
+	// refer to each other regardless of the declaration order
+	script := NewEnvironment(globalFuncs)
+	interpreter := &Interpreter{
 		writer:      writer,
-		environment: NewEnvironment(nil),
+		environment: script,
+		script:      script,
 		globals:     globalFuncs,
-		locals:      make(map[parse.Expression]int),
+		locals:      make(map[parse.Expression]local),
+		limits:      Limits{MaxCallDepth: DefaultMaxCallDepth},
+		loader:      NewFileLoader(),
+		modules:     make(map[string]*Module),
+	}
+	for _, option := range options {
+		option(interpreter)
 	}
+	return interpreter
+}
+
+// DefineNative makes a Go function callable from scripts under name. Arity is
+// checked before every call unless it is scan.VariadicArity, an error returned
+// by fn is reported as a runtime error at the call site.
+func (i *Interpreter) DefineNative(name string, arity int, fn NativeFunc) {
+	i.globals.Define(name, scan.NewCallableLoxValue(NewNativeFunction(name, arity, fn)))
+}
+
+// DefineClass makes a native class available to scripts under its name.
+func (i *Interpreter) DefineClass(class *NativeClass) {
+	i.globals.Define(class.Name(), scan.NewClassLoxValue(class))
+}
+
+// Environment returns the scope statements are currently executed in.
+func (i *Interpreter) Environment() *Environment {
+	return i.environment
+}
+
+// Globals returns the scope of native functions, it encloses the script scope.
+func (i *Interpreter) Globals() *Environment {
+	return i.globals
 }
 
 func (i *Interpreter) Interpret(stmts []parse.Statement) error {
+	i.limits.Begin()
 	for _, stmt := range stmts {
 		if _, err := i.execute(stmt); err != nil {
-			return err
+			return i.attachTrace(err)
 		}
 	}
 	return nil
 }
 
+// Eval evaluates a top-level expression the way Interpret runs statements:
+// the execution limits start over and runtime errors get a stack trace.
+func (i *Interpreter) Eval(expr parse.Expression) (*scan.LoxValue, error) {
+	i.limits.Begin()
+	value, err := i.Evaluate(expr)
+	if err != nil {
+		return nil, i.attachTrace(err)
+	}
+	return value, nil
+}
+
 func (i *Interpreter) Evaluate(expr parse.Expression) (*scan.LoxValue, error) {
 	result, err := expr.Accept(i)
 	if err != nil {
@@ -52,11 +109,15 @@ func (i *Interpreter) Evaluate(expr parse.Expression) (*scan.LoxValue, error) {
 }
 
 func (i *Interpreter) execute(stmt parse.Statement) (interface{}, error) {
+	i.limits.Step()
 	res, err := stmt.Accept(i)
 	return res, err
 }
 
 func (i *Interpreter) executeBlock(stmts []parse.Statement, nextEnv *Environment) (interface{}, error) {
+	if err := i.limits.Allocate(EnvironmentSize, nil); err != nil {
+		return nil, err
+	}
 	prevEnv := i.environment
 	i.environment = nextEnv
 	defer func() {
@@ -74,13 +135,19 @@ func (i *Interpreter) executeBlock(stmts []parse.Statement, nextEnv *Environment
 }
 
 func (i *Interpreter) lookUpVariable(token scan.Token, expr parse.Expression) (*scan.LoxValue, error) {
-	distance, ok := i.locals[expr]
-	if ok {
-		return i.environment.getAt(distance, token)
+	if local, ok := i.locals[expr]; ok {
+		return i.environment.getAt(local, token)
 	}
-	return i.globals.Get(token)
+	return i.script.Get(token)
+}
+
+// local is where the Resolver found a variable: the number of scopes between
+// the expression and the declaration, and the slot in the declaring scope.
+type local struct {
+	depth int
+	slot  int
 }
 
-func (i *Interpreter) resolve(expr parse.Expression, depth int) {
-	i.locals[expr] = depth
+func (i *Interpreter) resolve(expr parse.Expression, depth int, slot int) {
+	i.locals[expr] = local{depth: depth, slot: slot}
 }
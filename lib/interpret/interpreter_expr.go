@@ -20,31 +20,23 @@ func (i *Interpreter) VisitBinaryExpr(expr *parse.BinaryExpression) (interface{}
 		return nil, ConvertToRuntimeError("evaluate expression error", err, &expr.Operator)
 	}
 
-	if leftVal.IsBoolean() && rightVal.IsBoolean() {
-		leftBool, rightBool := leftVal.Bool(), rightVal.Bool()
-		switch expr.Operator.Type {
-		case scan.BANG_EQUAL:
-			return scan.NewBooleanLoxValue(leftBool != rightBool), nil
-		case scan.EQUAL_EQUAL:
-			return scan.NewBooleanLoxValue(leftBool == rightBool), nil
-		}
-	}
-
-	if leftVal.IsString() && rightVal.IsString() {
-		leftStr, rightStr := leftVal.String(), rightVal.String()
-		switch expr.Operator.Type {
-		case scan.BANG_EQUAL:
-			return scan.NewBooleanLoxValue(leftStr != rightStr), nil
-		case scan.EQUAL_EQUAL:
-			return scan.NewBooleanLoxValue(leftStr == rightStr), nil
-		}
+	// values of different types are never equal
+	switch expr.Operator.Type {
+	case scan.BANG_EQUAL:
+		return scan.NewBooleanLoxValue(!leftVal.Equal(rightVal)), nil
+	case scan.EQUAL_EQUAL:
+		return scan.NewBooleanLoxValue(leftVal.Equal(rightVal)), nil
 	}
 
 	if leftVal.IsString() || rightVal.IsString() {
 		leftStr, rightStr := leftVal.String(), rightVal.String()
 		switch expr.Operator.Type {
 		case scan.PLUS:
-			return scan.NewStringLoxValue(leftStr + rightStr), nil
+			concatenated := leftStr + rightStr
+			if err := i.limits.Allocate(StringAllocation(concatenated), &expr.Operator); err != nil {
+				return nil, err
+			}
+			return scan.NewStringLoxValue(concatenated), nil
 		}
 	}
 
@@ -67,11 +59,6 @@ func (i *Interpreter) VisitBinaryExpr(expr *parse.BinaryExpression) (interface{}
 	case scan.LESS_EQUAL:
 		return scan.NewBooleanLoxValue(leftNum <= rightNum), nil
 
-	case scan.BANG_EQUAL:
-		return scan.NewBooleanLoxValue(leftNum != rightNum), nil
-	case scan.EQUAL_EQUAL:
-		return scan.NewBooleanLoxValue(leftNum == rightNum), nil
-
 	case scan.MINUS:
 		return scan.NewFloatLoxValue(leftNum - rightNum), nil
 	case scan.SLASH:
@@ -133,11 +120,9 @@ func (i *Interpreter) VisitLogicalExpr(expr *parse.LogicalExpression) (interface
 	if expr.Operator.Type == scan.OR {
 		if left.Bool() {
 			return left, nil
-		} else {
-			if !left.Bool() {
-				return left, nil
-			}
 		}
+	} else if !left.Bool() {
+		return left, nil
 	}
 
 	return i.Evaluate(expr.Right)
@@ -153,10 +138,12 @@ func (i *Interpreter) VisitAssignExpr(expr *parse.AssignExpression) (interface{}
 		return nil, err
 	}
 
-	if distance, ok := i.locals[expr]; ok {
-		i.environment.assignAt(distance, expr.Name, value)
+	if local, ok := i.locals[expr]; ok {
+		if err := i.environment.assignAt(local, expr.Name, value); err != nil {
+			return nil, err
+		}
 	} else {
-		if err := i.globals.Assign(expr.Name, value); err != nil {
+		if err := i.script.Assign(expr.Name, value); err != nil {
 			return nil, err
 		}
 	}
@@ -183,25 +170,41 @@ func (i *Interpreter) VisitCallExpr(expr *parse.CallExpression) (interface{}, er
 		return nil, ConvertToRuntimeError("can only call functions or classes", err, &expr.Paren)
 	}
 
-	if len(arguments) != calleeFunc.Arity() {
+	if calleeFunc.Arity() != scan.VariadicArity && len(arguments) != calleeFunc.Arity() {
 		return nil, NewRuntimeError(
 			fmt.Sprintf("expected %d arguments but got %d", calleeFunc.Arity(), len(arguments)),
 			&expr.Paren,
 		)
 	}
 
-	return calleeFunc.Call(arguments)
+	isFrame := i.pushFrame(calleeFunc, expr.Paren)
+	result, err := calleeFunc.Call(arguments)
+	if err != nil {
+		if isFrame {
+			err = i.attachTrace(err)
+		} else if !isLimitError(err) {
+			err = ConvertToRuntimeError("native function error", err, &expr.Paren)
+		}
+	}
+	if isFrame {
+		i.popFrame()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func (i *Interpreter) VisitSuperExpr(expr *parse.SuperExpression) (interface{}, error) {
-	distance := i.locals[expr]
-	superclass, err := i.environment.getAt(distance, expr.Keyword)
+	// "super" and "this" are the only names of their scopes
+	resolved := i.locals[expr]
+	superclass, err := i.environment.getAt(resolved, expr.Keyword)
 	if err != nil {
 		return nil, err
 	}
 	superclassInstance, err := i.environment.getAt(
-		distance-1,
-		scan.NewToken(scan.THIS, "this", nil, expr.Keyword.Line),
+		local{depth: resolved.depth - 1},
+		scan.Token{Type: scan.THIS, Lexeme: "this", Position: expr.Keyword.Position},
 	)
 	if err != nil {
 		return nil, err
@@ -241,8 +244,10 @@ func (i *Interpreter) VisitSetExpr(expr *parse.SetExpression) (interface{}, erro
 	if err != nil {
 		return nil, err
 	}
-	instance.Set(expr.Name, value)
-	return nil, nil
+	if err := instance.Set(expr.Name, value); err != nil {
+		return nil, err
+	}
+	return value, nil
 }
 
 func (i *Interpreter) VisitGetExpr(expr *parse.GetExpression) (interface{}, error) {
@@ -250,6 +255,17 @@ func (i *Interpreter) VisitGetExpr(expr *parse.GetExpression) (interface{}, erro
 	if err != nil {
 		return nil, err
 	}
+	if object.IsList() {
+		list, _ := object.List()
+		return ListMethod(list, expr.Name, &i.limits)
+	}
+	if object.IsMap() {
+		loxMap, _ := object.Map()
+		return MapMethod(loxMap, expr.Name, &i.limits)
+	}
+	if object.IsString() {
+		return StringMethod(object.String(), expr.Name, &i.limits)
+	}
 	if !object.IsClassInstance() {
 		return nil, NewRuntimeError("only instances have properties", &expr.Name)
 	}
@@ -259,3 +275,127 @@ func (i *Interpreter) VisitGetExpr(expr *parse.GetExpression) (interface{}, erro
 	}
 	return instance.Get(expr.Name)
 }
+
+func (i *Interpreter) VisitListExpr(expr *parse.ListExpression) (interface{}, error) {
+	elements := make([]*scan.LoxValue, 0, len(expr.Elements))
+	for _, element := range expr.Elements {
+		value, err := i.Evaluate(element)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, value)
+	}
+	if err := i.limits.Allocate(ListAllocation(len(elements)), &expr.Bracket); err != nil {
+		return nil, err
+	}
+	return scan.NewListLoxValue(scan.NewLoxList(elements)), nil
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *parse.IndexExpression) (interface{}, error) {
+	object, err := i.Evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.Evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	if object.IsMap() {
+		loxMap, _ := object.Map()
+		value, ok, err := loxMap.Get(index)
+		if err != nil {
+			return nil, ConvertToRuntimeError("invalid map key", err, &expr.Bracket)
+		}
+		if !ok {
+			return nil, NewRuntimeError(fmt.Sprintf("undefined map key %s", index.String()), &expr.Bracket)
+		}
+		return value, nil
+	}
+	if object.IsString() {
+		position, err := index.Number()
+		if err != nil {
+			return nil, ConvertToRuntimeError("invalid string index", err, &expr.Bracket)
+		}
+		value, err := StringIndex(object.String(), position)
+		if err != nil {
+			return nil, ConvertToRuntimeError("invalid string index", err, &expr.Bracket)
+		}
+		return value, nil
+	}
+	list, err := object.List()
+	if err != nil {
+		return nil, ConvertToRuntimeError("only lists, maps and strings can be indexed", err, &expr.Bracket)
+	}
+	position, err := index.Number()
+	if err != nil {
+		return nil, ConvertToRuntimeError("invalid list index", err, &expr.Bracket)
+	}
+	value, err := list.Get(position)
+	if err != nil {
+		return nil, ConvertToRuntimeError("invalid list index", err, &expr.Bracket)
+	}
+	return value, nil
+}
+
+func (i *Interpreter) VisitIndexSetExpr(expr *parse.IndexSetExpression) (interface{}, error) {
+	object, err := i.Evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.Evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := i.Evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if object.IsMap() {
+		loxMap, _ := object.Map()
+		lengthBefore := loxMap.Len()
+		if err := loxMap.Set(index, value); err != nil {
+			return nil, ConvertToRuntimeError("invalid map key", err, &expr.Bracket)
+		}
+		if loxMap.Len() > lengthBefore {
+			if err := i.limits.Allocate(MapEntrySize, &expr.Bracket); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	list, err := object.List()
+	if err != nil {
+		return nil, ConvertToRuntimeError("only lists and maps support index assignment", err, &expr.Bracket)
+	}
+	position, err := index.Number()
+	if err != nil {
+		return nil, ConvertToRuntimeError("invalid list index", err, &expr.Bracket)
+	}
+	if err := list.Set(position, value); err != nil {
+		return nil, ConvertToRuntimeError("invalid list index", err, &expr.Bracket)
+	}
+	return value, nil
+}
+
+func (i *Interpreter) VisitMapExpr(expr *parse.MapExpression) (interface{}, error) {
+	if err := i.limits.Allocate(MapAllocation(len(expr.Keys)), &expr.Brace); err != nil {
+		return nil, err
+	}
+	loxMap := scan.NewLoxMap()
+	for idx, keyExpr := range expr.Keys {
+		key, err := i.Evaluate(keyExpr)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.Evaluate(expr.Values[idx])
+		if err != nil {
+			return nil, err
+		}
+		if err := loxMap.Set(key, value); err != nil {
+			return nil, ConvertToRuntimeError("invalid map key", err, &expr.Brace)
+		}
+	}
+	return scan.NewMapLoxValue(loxMap), nil
+}
@@ -1,6 +1,7 @@
 package interpret
 
 import (
+	"errors"
 	"fmt"
 	"github.com/hrumst/gox-lox/lib/parse"
 	"github.com/hrumst/gox-lox/lib/scan"
@@ -20,7 +21,7 @@ func (i *Interpreter) VisitStmtVar(stmt *parse.StmtVar) (interface{}, error) {
 }
 
 func (i *Interpreter) VisitStmtBlock(stmt *parse.StmtBlock) (interface{}, error) {
-	res, err := i.executeBlock(stmt.Stmts, NewEnvironment(i.environment))
+	res, err := i.executeBlock(stmt.Stmts, newLocalEnvironment(i.environment))
 	return res, err
 }
 
@@ -36,8 +37,10 @@ func (i *Interpreter) VisitStmtIf(stmt *parse.StmtIf) (interface{}, error) {
 			return control, nil
 		}
 	} else if stmt.ElseBranch != nil {
-		if _, err := i.execute(stmt.ElseBranch); err != nil {
+		if res, err := i.execute(stmt.ElseBranch); err != nil {
 			return nil, err
+		} else if control, ok := res.(executeControl); ok {
+			return control, nil
 		}
 	}
 	return nil, nil
@@ -45,6 +48,9 @@ func (i *Interpreter) VisitStmtIf(stmt *parse.StmtIf) (interface{}, error) {
 
 func (i *Interpreter) VisitStmtWhile(stmt *parse.StmtWhile) (interface{}, error) {
 	for {
+		if err := i.limits.Check(nil); err != nil {
+			return nil, err
+		}
 		conditionValue, err := i.Evaluate(stmt.Condition)
 		if err != nil {
 			return nil, err
@@ -59,6 +65,15 @@ func (i *Interpreter) VisitStmtWhile(stmt *parse.StmtWhile) (interface{}, error)
 			if control.isBreak() {
 				break
 			}
+			if control.isReturn() {
+				return control, nil
+			}
+		}
+
+		if stmt.Increment != nil {
+			if _, err := i.Evaluate(stmt.Increment); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return nil, nil
@@ -104,7 +119,6 @@ func (i *Interpreter) VisitStmtPrint(stmt *parse.StmtPrint) (interface{}, error)
 }
 
 func (i *Interpreter) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error) {
-	i.environment.Define(stmt.Name.Lexeme, nil)
 	environment := i.environment
 
 	var superClass *LoxClass
@@ -121,10 +135,16 @@ func (i *Interpreter) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error)
 			)
 		}
 
-		environment = NewEnvironment(environment)
+		environment = newLocalEnvironment(environment)
 		environment.Define("super", superClassLoxValue)
 
-		superClass = superClassLox.(*LoxClass)
+		var ok bool
+		if superClass, ok = superClassLox.(*LoxClass); !ok {
+			return nil, NewRuntimeError(
+				fmt.Sprintf("superclass must be a class declared in Lox, got %s", superClassLox.String()),
+				&stmt.SuperClass.Name,
+			)
+		}
 	}
 
 	methods := make(map[string]scan.LoxCallable)
@@ -134,8 +154,41 @@ func (i *Interpreter) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error)
 		methods[stmtFunc.Name.Lexeme] = loxFunc
 	}
 	class := NewLoxClass(i, stmt, superClass, methods)
-	if err := i.environment.Assign(stmt.Name, scan.NewClassLoxValue(scan.LoxClass(class))); err != nil {
+	// methods only capture the environment, so the name can be defined last
+	i.environment.Define(stmt.Name.Lexeme, scan.NewClassLoxValue(scan.LoxClass(class)))
+	return nil, nil
+}
+
+func (i *Interpreter) VisitStmtThrow(stmt *parse.StmtThrow) (interface{}, error) {
+	value, err := i.Evaluate(stmt.Value)
+	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return nil, NewThrowError(value, &stmt.Keyword)
+}
+
+// VisitStmtTry catches runtime errors of the try block, other errors (failed
+// output and the like) are not catchable. The finally block runs on every way
+// out of the statement, a break, continue or return inside it wins over the
+// outcome of the try and catch blocks.
+func (i *Interpreter) VisitStmtTry(stmt *parse.StmtTry) (interface{}, error) {
+	res, err := i.executeBlock(stmt.TryBlock, newLocalEnvironment(i.environment))
+
+	var runtimeErr *RuntimeError
+	if err != nil && stmt.CatchName != nil && errors.As(err, &runtimeErr) {
+		environment := newLocalEnvironment(i.environment)
+		environment.Define(stmt.CatchName.Lexeme, runtimeErr.Value())
+		res, err = i.executeBlock(stmt.CatchBlock, environment)
+	}
+
+	if stmt.FinallyBlock != nil {
+		finallyRes, finallyErr := i.executeBlock(stmt.FinallyBlock, newLocalEnvironment(i.environment))
+		if finallyErr != nil {
+			return nil, finallyErr
+		}
+		if control, ok := finallyRes.(executeControl); ok {
+			return control, nil
+		}
+	}
+	return res, err
 }
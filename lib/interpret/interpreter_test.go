@@ -2,11 +2,14 @@ package interpret
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/hrumst/gox-lox/lib/parse"
 	"github.com/hrumst/gox-lox/lib/scan"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInterpreter_Evaluate(t *testing.T) {
@@ -982,3 +985,716 @@ func TestInterpreter_Evaluate(t *testing.T) {
 		)
 	}
 }
+
+func interpretSource(t *testing.T, source string) (string, error) {
+	buf := bytes.NewBufferString("")
+	err := interpretSourceWith(t, NewInterpreter(buf), source)
+	return buf.String(), err
+}
+
+// interpretSourceWith runs source on a prepared interpreter, e.g. one with natives defined.
+func interpretSourceWith(t *testing.T, interpreter *Interpreter, source string) error {
+	tokens, err := scan.NewFileScanner("test.lox", source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewResolver(interpreter).Resolve(stmts); err != nil {
+		t.Fatal(err)
+	}
+	return interpreter.Interpret(stmts)
+}
+
+func TestInterpreter_Equality(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`print nil == nil; print nil != false;`, "true\ntrue\n"},
+		{`print 1 == "1"; print "a" != 1;`, "false\ntrue\n"},
+		{`print true == 1; print [1] == "[1]";`, "false\nfalse\n"},
+		{`class A {} var a = A(); print a == a; print a == A();`, "true\nfalse\n"},
+		{`fun f() {} print f == f; print f == clock;`, "true\nfalse\n"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_equality_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_Logical(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`print nil or "right"; print false or false;`, "right\nfalse\n"},
+		{`print 1 or "right"; print true and 2; print nil and 2;`, "1\n2\nnil\n"},
+		{`fun loud() { print "called"; return true; } print false and loud(); print true or loud();`, "false\ntrue\n"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_logical_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_SetExpressionValue(t *testing.T) {
+	output, err := interpretSource(t, `class A {} var a = A(); var b = A();
+print a.x = 1;
+a.y = b.y = 2;
+print a.y + b.y;`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n4\n", output)
+}
+
+func TestInterpreter_ControlFlowPropagation(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`fun f() { if (false) {} else { return 1; } return 2; } print f();`, "1\n"},
+		{`var i = 0; while (i < 5) { i = i + 1; if (i < 2) {} else break; } print i;`, "2\n"},
+		{`fun f() { var i = 0; while (i < 3) { i = i + 1; return i; } return 10; } print f();`, "1\n"},
+		{`fun f() { for (var i = 0; i < 3; i = i + 1) { if (i == 1) return i; } } print f();`, "1\n"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_control_flow_propagation_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_ForContinue(t *testing.T) {
+	output, err := interpretSource(t, `for (var i = 0; i < 4; i = i + 1) {
+  if (i == 1) continue;
+  var j = 0;
+  while (j < 5) { j = j + 1; if (j < 3) continue; break; }
+  print i + j;
+}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "3\n5\n6\n", output)
+}
+
+func TestInterpreter_LateBoundGlobals(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	interpreter := NewInterpreter(buf)
+
+	// top-level functions may call functions declared after them
+	assert.NoError(t, interpretSourceWith(t, interpreter, `fun isEven(n) { if (n == 0) return true; return isOdd(n - 1); }
+fun isOdd(n) { if (n == 0) return false; return isEven(n - 1); }
+print isEven(4);
+fun useLater() { return later; }`))
+	// and names defined by a later run
+	assert.NoError(t, interpretSourceWith(t, interpreter, `var later = "later"; print useLater(); later = "assigned"; print useLater();`))
+	assert.Equal(t, "true\nlater\nassigned\n", buf.String())
+
+	_, err := interpretSource(t, `{ fun f() { return local; } var local = 1; f(); }`)
+	assert.ErrorContains(t, err, "undefined variable")
+}
+
+func TestInterpreter_RuntimeErrorTrace(t *testing.T) {
+	source := `class Point {
+  init(x) { this.x = x; }
+  norm() { return this.x / 0; }
+}
+fun measure(p) {
+  return p.norm();
+}
+print "start";
+measure(Point(1));`
+
+	output, err := interpretSource(t, source)
+	assert.Equal(t, "start\n", output)
+
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected RuntimeError, got %T", err)
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"[test.lox:3:26] in norm()",
+			"[test.lox:6:17] in measure()",
+			"[test.lox:9:17] in script",
+		},
+		func() []string {
+			frames := make([]string, 0)
+			for _, frame := range runtimeErr.Trace() {
+				frames = append(frames, frame.String())
+			}
+			return frames
+		}(),
+	)
+	assert.Contains(t, err.Error(), "stack trace:\n  [test.lox:3:26] in norm()\n")
+
+	_, err = interpretSource(t, `fun f() { return nil + 1; } f();`)
+	assert.Len(t, err.(*RuntimeError).Trace(), 2)
+	assert.Equal(t, "f", err.(*RuntimeError).Trace()[0].Function)
+}
+
+func TestInterpreter_Lists(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `var xs = [1, "two", [3]]; print xs; print xs[0]; print xs[-1][0]; print len(xs);`,
+			expected: "[1, \"two\", [3]]\n1\n3\n3\n",
+		}, {
+			source:   `var xs = []; xs.push(1); xs.push(2); xs[0] = xs[1] + 10; print xs; print xs.len();`,
+			expected: "[12, 2]\n2\n",
+		}, {
+			source:   `var xs = [1, 2, 3]; print xs.pop(); print xs.remove(0); print xs;`,
+			expected: "3\n1\n[2]\n",
+		}, {
+			source:   `var xs = [1, 3]; xs.insert(1, 2); xs.insert(3, 4); xs.insert(-1, 0); print xs;`,
+			expected: "[1, 2, 3, 0, 4]\n",
+		}, {
+			source:   `var xs = [1, 2, 3, 4]; print xs.slice(1, -1); print xs.slice(2, nil); print xs.slice(-10, 10);`,
+			expected: "[2, 3]\n[3, 4]\n[1, 2, 3, 4]\n",
+		}, {
+			source:   `var xs = [1]; var ys = xs; ys.push(2); print xs; print xs == ys; print xs == [1, 2];`,
+			expected: "[1, 2]\ntrue\nfalse\n",
+		}, {
+			source:   `var xs = []; xs.push(xs); print xs; print len("héllo");`,
+			expected: "[[...]]\n5\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_lists_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_Strings(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `var s = "日本語"; print s.len(); print s[0]; print s[-1]; print s.indexOf("語");`,
+			expected: "3\n日\n語\n2\n",
+		}, {
+			source:   `print "a,b,,c".split(","); print "".split(","); print "ab".split("");`,
+			expected: "[\"a\", \"b\", \"\", \"c\"]\n[\"\"]\n[\"a\", \"b\"]\n",
+		}, {
+			source:   `var s = "hello"; print s.substring(1, 3); print s.substring(3, 1) == ""; print s.substring(-10, nil); print s.substring(-2);`,
+			expected: "el\ntrue\nhello\nlo\n",
+		}, {
+			source:   `print "aaa".replace("a", "bb"); print "Ab".upper() + "Ab".lower(); print "  x y ".trim().len();`,
+			expected: "bbbbbb\nABab\n3\n",
+		}, {
+			source:   `print "lox".contains(""); print "lox".startsWith("lo"); print "lox".startsWith("x");`,
+			expected: "true\ntrue\nfalse\n",
+		}, {
+			source:   `var s = "abc"; var out = ""; for (var i = s.len() - 1; i >= 0; i = i - 1) out = out + s[i]; print out; print s;`,
+			expected: "cba\nabc\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_strings_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_StringErrors(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`print "ab"[-3];`, "invalid string index: string index -3 out of range"},
+		{`print "ab"[0.5];`, "invalid string index: string index must be an integer, got 0.5"},
+		{`"ab".substring(0.5);`, "native function error: argument 1 must be an integer, got 0.5"},
+		{`"ab".substring();`, "native function error: expected at least 1 arguments but got 0"},
+		{`"ab".substring(0, 1, 2);`, "native function error: expected at most 2 arguments but got 3"},
+		{`"ab".replace("a");`, "expected 2 arguments but got 1"},
+		{`"ab".size();`, "undefined string method 'size'"},
+		{`"ab".field = 1;`, "only instances have fields"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_string_errors_test_case_%d", i),
+			func(t *testing.T) {
+				_, err := interpretSource(t, tc.source)
+				assert.ErrorContains(t, err, tc.expected)
+			},
+		)
+	}
+}
+
+func TestInterpreter_ListErrors(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`var xs = [1]; print xs[1];`, "invalid list index: list index 1 out of range"},
+		{`var xs = [1]; print xs[0.5];`, "invalid list index: list index must be an integer, got 0.5"},
+		{`var xs = [1]; print xs["0"];`, "invalid list index: string is not a number"},
+		{`var s = 1; print s[0];`, "only lists, maps and strings can be indexed: float is not a list"},
+		{`[].pop();`, "native function error: pop from empty list"},
+		{`[].sort();`, "undefined list method 'sort'"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_list_errors_test_case_%d", i),
+			func(t *testing.T) {
+				_, err := interpretSource(t, tc.source)
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.expected, err.(*RuntimeError).Message())
+				}
+			},
+		)
+	}
+}
+
+func TestInterpreter_Maps(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `var m = {"a": 1, 2: "two", true: [3], nil: 4}; print m; print m["a"]; print m[2]; print m[true][0]; print m[nil]; print len(m);`,
+			expected: "{\"a\": 1, 2: \"two\", true: [3], nil: 4}\n1\ntwo\n3\n4\n4\n",
+		}, {
+			source:   `var m = {}; m["x"] = 1; m["y"] = 2; m["x"] = m["x"] + 10; print m; print m.len();`,
+			expected: "{\"x\": 11, \"y\": 2}\n2\n",
+		}, {
+			source: `var m = {"b": 1, "a": 2, "c": 3};
+var keys = m.keys();
+for (var i = 0; i < len(keys); i = i + 1) { print keys[i] + "=" + m[keys[i]]; }
+print m.values();`,
+			expected: "b=1\na=2\nc=3\n[1, 2, 3]\n",
+		}, {
+			source:   `var m = {1: "one"}; print m.has(1); print m.has("1"); print m.delete(1); print m.delete(1); print m;`,
+			expected: "true\nfalse\ntrue\nfalse\n{}\n",
+		}, {
+			source:   `var m = {"a": 1}; print m.get("a", 0); print m.get("b", 0); print m[1.0] = "x"; print m[1];`,
+			expected: "1\n0\nx\nx\n",
+		}, {
+			source:   `var m = {}; var n = m; n["k"] = m; print m; print m == n; print m == {};`,
+			expected: "{\"k\": {...}}\ntrue\nfalse\n",
+		}, {
+			source:   `var m = {"a": 1, "b": 2}; m.delete("a"); m["a"] = 3; print m.keys();`,
+			expected: "[\"b\", \"a\"]\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_maps_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_MapErrors(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`var m = {"a": 1}; print m["b"];`, "undefined map key b"},
+		{`var m = {}; m[[1]] = 1;`, "invalid map key: list can't be a map key"},
+		{`var m = {[]: 1};`, "invalid map key: list can't be a map key"},
+		{`var m = {}; print m[{}];`, "invalid map key: map can't be a map key"},
+		{`var m = {}; m.clear();`, "undefined map method 'clear'"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_map_errors_test_case_%d", i),
+			func(t *testing.T) {
+				_, err := interpretSource(t, tc.source)
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.expected, err.(*RuntimeError).Message())
+				}
+			},
+		)
+	}
+}
+
+func TestInterpreter_TryCatch(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `try { print 1 / 0; } catch (e) { print "caught: " + e; }`,
+			expected: "caught: evaluate expression error: zero division error\n",
+		}, {
+			source:   `try { throw "boom"; print "unreachable"; } catch (e) { print e; } finally { print "finally"; }`,
+			expected: "boom\nfinally\n",
+		}, {
+			source:   `try { throw {"code": 42}; } catch (err) { print err["code"]; }`,
+			expected: "42\n",
+		}, {
+			source: `class A {}
+try { A().missing; } catch (e) { print e; }
+try { [].pop(); } catch (e) { print e; }`,
+			expected: "undefined property 'missing'\nnative function error: pop from empty list\n",
+		}, {
+			source: `fun inner() { throw "deep"; }
+fun outer() { inner(); print "unreachable"; }
+try { outer(); } catch (e) { print e; }
+print "after";`,
+			expected: "deep\nafter\n",
+		}, {
+			source: `fun f() {
+  try { return "try"; } finally { print "finally"; }
+}
+print f();`,
+			expected: "finally\ntry\n",
+		}, {
+			source: `fun f() {
+  try { throw "x"; } finally { return "finally"; }
+}
+print f();`,
+			expected: "finally\n",
+		}, {
+			source: `for (var i = 0; i < 5; i = i + 1) {
+  try { if (i == 2) break; throw i; } catch (e) { print e; } finally { print "f"; }
+}`,
+			expected: "0\nf\n1\nf\nf\n",
+		}, {
+			source:   `try { try { throw 1; } finally { print "inner"; } } catch (e) { print e + 1; }`,
+			expected: "inner\n2\n",
+		}, {
+			source:   `try { throw 1; } catch (e) { try { throw e + 1; } catch (e) { print e; } print e; }`,
+			expected: "2\n1\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_try_catch_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_LocalSlots(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `{ var a = 1; var b = 2; { var a = b + 10; b = a; print a; } print a; print b; }`,
+			expected: "12\n1\n12\n",
+		}, {
+			source: `fun counter() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }
+var c = counter(); c(); print c();`,
+			expected: "2\n",
+		}, {
+			source:   `{ var x = 1; class A { get() { return x; } } class B < A { get() { return super.get() + 1; } } print B().get(); }`,
+			expected: "2\n",
+		}, {
+			source:   `fun f(a, b) { var c = a * b; { var d = c + a; return d - b; } } print f(3, 4);`,
+			expected: "11\n",
+		}, {
+			source:   `var top = "top"; fun f() { var local = "local"; return top + local; } print f();`,
+			expected: "toplocal\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_local_slots_test_case_%d", i),
+			func(t *testing.T) {
+				output, err := interpretSource(t, tc.source)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, output)
+			},
+		)
+	}
+}
+
+func TestInterpreter_UncaughtThrow(t *testing.T) {
+	output, err := interpretSource(t, `fun f() { throw [1, "a"]; }
+try { f(); } catch (e) { throw e; } finally { print "finally"; }`)
+	assert.Equal(t, "finally\n", output)
+	if assert.Error(t, err) {
+		runtimeErr := err.(*RuntimeError)
+		assert.Equal(t, "uncaught exception: [1, \"a\"]", runtimeErr.Message())
+		assert.Equal(t, "[1, \"a\"]", runtimeErr.Value().String())
+		assert.Equal(t, "test.lox:2:26", runtimeErr.Token().Position.String())
+	}
+}
+
+func TestInterpreter_DefineNative(t *testing.T) {
+	newInterpreter := func(buf *bytes.Buffer) *Interpreter {
+		interpreter := NewInterpreter(buf)
+		interpreter.DefineNative("add", 2, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			a, err := NumberArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			b, err := NumberArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return scan.NewFloatLoxValue(a + b), nil
+		})
+		interpreter.DefineNative("join", scan.VariadicArity, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			if err := CheckArgCount(args, 1, -1); err != nil {
+				return nil, err
+			}
+			sep, err := StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, 0, len(args)-1)
+			for _, part := range args[1:] {
+				parts = append(parts, part.String())
+			}
+			return scan.NewStringLoxValue(strings.Join(parts, sep)), nil
+		})
+		interpreter.DefineNative("apply", 2, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			fn, err := CallableArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			list, err := ListArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return fn.Call(list.Elements())
+		})
+		return interpreter
+	}
+
+	type testCase struct {
+		source   string
+		expected string
+		err      string
+	}
+
+	tcs := []testCase{
+		{source: `print add(1, 2);`, expected: "3\n"},
+		{source: `print join("-"); print join(", ", 1, "a", true);`, expected: "\n1, a, true\n"},
+		{source: `fun sub(a, b) { return a - b; } print apply(sub, [5, 3]);`, expected: "2\n"},
+		{source: `print add;`, expected: "[function] add\n"},
+		{source: `add(1, "2");`, err: "native function error: argument 2 must be a number, got string"},
+		{source: `add(1);`, err: "expected 2 arguments but got 1"},
+		{source: `join();`, err: "native function error: expected at least 1 arguments but got 0"},
+		{source: `try { add(nil, 1); } catch (e) { print e; }`, expected: "native function error: argument 1 must be a number, got nil\n"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_define_native_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				err := interpretSourceWith(t, newInterpreter(buf), tc.source)
+				if tc.err != "" {
+					if assert.Error(t, err) {
+						assert.Equal(t, tc.err, err.(*RuntimeError).Message())
+					}
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, buf.String())
+			},
+		)
+	}
+}
+
+func TestInterpreter_Limits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	type testCase struct {
+		options  []InterpreterOption
+		source   string
+		expected error
+	}
+
+	tcs := []testCase{
+		{[]InterpreterOption{WithMaxSteps(100)}, `while (true) {}`, ErrStepLimit},
+		{[]InterpreterOption{WithMaxSteps(100)}, `fun f() { f(); } f();`, ErrStepLimit},
+		{[]InterpreterOption{WithMaxSteps(100)}, `try { while (true) {} } catch (e) { print e; }`, ErrStepLimit},
+		{[]InterpreterOption{WithTimeout(10 * time.Millisecond)}, `while (true) {}`, ErrTimeout},
+		{[]InterpreterOption{WithDeadline(time.Now().Add(-time.Second))}, `fun f() {} f();`, ErrTimeout},
+		{[]InterpreterOption{WithContext(canceled)}, `while (true) {}`, context.Canceled},
+		{[]InterpreterOption{WithMaxSteps(1000)}, `var i = 0; while (i < 10) { i = i + 1; }`, nil},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_limits_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				err := interpretSourceWith(t, NewInterpreter(buf, tc.options...), tc.source)
+				if tc.expected == nil {
+					assert.NoError(t, err)
+					return
+				}
+				assert.ErrorIs(t, err, tc.expected)
+				var limitErr *LimitError
+				assert.ErrorAs(t, err, &limitErr)
+				assert.Empty(t, buf.String())
+			},
+		)
+	}
+}
+
+func TestInterpreter_LimitsThroughNatives(t *testing.T) {
+	interpreter := NewInterpreter(bytes.NewBufferString(""), WithMaxSteps(50))
+	interpreter.DefineNative("call", 1, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+		fn, err := CallableArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn.Call(nil)
+	})
+	err := interpretSourceWith(t, interpreter, `fun spin() { while (true) {} } try { call(spin); } catch (e) {}`)
+	assert.ErrorIs(t, err, ErrStepLimit)
+
+	// every run gets a fresh budget
+	for run := 0; run < 3; run++ {
+		assert.NoError(t, interpretSourceWith(t, interpreter, `var i = 0; while (i < 10) { i = i + 1; }`))
+	}
+}
+
+func TestInterpreter_StackOverflow(t *testing.T) {
+	output, err := interpretSource(t, `fun down(n) { if (n == 0) return 0; return 1 + down(n - 1); }
+print down(5000);
+fun forever(n) { return forever(n + 1); }
+try { forever(0); } catch (e) { print e; }
+print down(10);`)
+	assert.NoError(t, err)
+	assert.Equal(t, "5000\nstack overflow\n10\n", output)
+
+	buf := bytes.NewBufferString("")
+	err = interpretSourceWith(t, NewInterpreter(buf, WithMaxCallDepth(10)), `
+fun down(n) { if (n == 0) return 0; return 1 + down(n - 1); }
+print down(9);
+class A { init(n) { if (n > 0) A(n - 1); } }
+try { A(20); } catch (e) { print "class " + e; }
+down(10);`)
+	assert.Equal(t, "9\nclass stack overflow\n", buf.String())
+	if assert.Error(t, err) {
+		runtimeErr := err.(*RuntimeError)
+		assert.Equal(t, "stack overflow", runtimeErr.Message())
+		assert.Len(t, runtimeErr.Trace(), 12)
+	}
+}
+
+func TestInterpreter_StackOverflowTrace(t *testing.T) {
+	_, err := interpretSource(t, `fun forever() { forever(); }
+forever();`)
+	if !assert.Error(t, err) {
+		return
+	}
+	trace := err.(*RuntimeError).Trace()
+	assert.Len(t, trace, maxTraceFrames)
+	assert.Equal(t, "[test.lox:1:5] in forever()", trace[0].String())
+	assert.Equal(t, fmt.Sprintf("... %d frames omitted", DefaultMaxCallDepth+2-maxTraceFrames+1), trace[maxTraceFrames/2-1].String())
+	assert.Equal(t, "[test.lox:2:9] in script", trace[len(trace)-1].String())
+}
+
+func TestInterpreter_MemoryLimit(t *testing.T) {
+	type testCase struct {
+		source   string
+		exceeded bool
+	}
+
+	tcs := []testCase{
+		{`var s = "x"; while (true) { s = s + s; }`, true},
+		{`var xs = []; while (true) { xs.push(1); }`, true},
+		{`var m = {}; var i = 0; while (true) { m[i] = i; i = i + 1; }`, true},
+		{`class A {} var as = []; while (true) { as.push(A()); }`, true},
+		{`fun f() { var a = [1, 2, 3]; } while (true) { f(); }`, true},
+		{`var m = {"a": 1, "b": 2}; while (true) { m.keys(); }`, true},
+		{`var xs = [1, 2, 3]; try { while (true) { xs.slice(0, nil); } } catch (e) {}`, true},
+		{`var s = "a,b,c"; while (true) { s.split(","); }`, true},
+		{`var s = ""; for (var i = 0; i < 10; i = i + 1) { s = s + "ab"; } print len(s);`, false},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_memory_limit_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				err := interpretSourceWith(t, NewInterpreter(buf, WithMaxMemory(64*1024)), tc.source)
+				if !tc.exceeded {
+					assert.NoError(t, err)
+					return
+				}
+				assert.ErrorIs(t, err, ErrMemoryLimit)
+			},
+		)
+	}
+
+	// the quota restarts with every run
+	interpreter := NewInterpreter(bytes.NewBufferString(""), WithMaxMemory(16*1024))
+	for run := 0; run < 10; run++ {
+		assert.NoError(t, interpretSourceWith(t, interpreter, `var xs = []; for (var i = 0; i < 20; i = i + 1) { xs.push(i); }`))
+	}
+}
@@ -0,0 +1,165 @@
+package interpret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// DefaultMaxCallDepth keeps deep recursion well within the Go stack.
+const DefaultMaxCallDepth = 10000
+
+var (
+	ErrStepLimit = errors.New("step limit exceeded")
+	ErrTimeout   = errors.New("execution timed out")
+)
+
+// LimitError is returned when a script runs out of an execution limit. It
+// wraps ErrStepLimit, ErrTimeout, ErrMemoryLimit or the error of the cancelled
+// context and, unlike RuntimeError, can't be caught by try/catch.
+type LimitError struct {
+	err   error
+	token *scan.Token
+}
+
+func newLimitError(err error, token *scan.Token) *LimitError {
+	return &LimitError{
+		err:   err,
+		token: token,
+	}
+}
+
+func (le *LimitError) Message() string {
+	return le.err.Error()
+}
+
+// Token returns the token execution was stopped at, nil when unknown.
+func (le *LimitError) Token() *scan.Token {
+	return le.token
+}
+
+func (le *LimitError) Unwrap() error {
+	return le.err
+}
+
+func (le *LimitError) Error() string {
+	errStr := le.err.Error()
+	if le.token != nil {
+		errStr = errStr + fmt.Sprintf("\nat %s, token: %s", le.token.Position, le.token.Lexeme)
+	}
+	return errStr
+}
+
+func isLimitError(err error) bool {
+	var limitErr *LimitError
+	return errors.As(err, &limitErr)
+}
+
+type InterpreterOption func(i *Interpreter)
+
+// WithMaxSteps limits the number of statements a single run may execute.
+func WithMaxSteps(steps int) InterpreterOption {
+	return func(i *Interpreter) {
+		i.limits.MaxSteps = steps
+	}
+}
+
+// WithMaxCallDepth limits how deep Lox function calls may nest, a call past
+// the limit raises a catchable "stack overflow" runtime error. The default is
+// DefaultMaxCallDepth.
+func WithMaxCallDepth(depth int) InterpreterOption {
+	return func(i *Interpreter) {
+		i.limits.MaxCallDepth = depth
+	}
+}
+
+// WithTimeout limits the wall-clock time of a single run.
+func WithTimeout(timeout time.Duration) InterpreterOption {
+	return func(i *Interpreter) {
+		i.limits.Timeout = timeout
+	}
+}
+
+// WithDeadline stops every run still executing at deadline.
+func WithDeadline(deadline time.Time) InterpreterOption {
+	return func(i *Interpreter) {
+		i.limits.Deadline = deadline
+	}
+}
+
+// WithContext stops execution once ctx is done.
+func WithContext(ctx context.Context) InterpreterOption {
+	return func(i *Interpreter) {
+		i.limits.Context = ctx
+	}
+}
+
+// Limits are the execution limits of a backend and the budgets spent by the
+// current run. Zero values mean no limit. They are checked on every loop
+// iteration and function call, the cheapest places that every long running
+// script has to pass through.
+type Limits struct {
+	MaxSteps     int
+	MaxCallDepth int
+	MaxMemory    int64
+	Timeout      time.Duration
+	Deadline     time.Time
+	Context      context.Context
+
+	steps       int
+	callDepth   int
+	allocated   int64
+	runDeadline time.Time
+}
+
+// Begin starts the budgets of a run: Interpret and Eval calls are limited separately.
+func (l *Limits) Begin() {
+	l.steps = 0
+	l.allocated = 0
+	l.runDeadline = l.Deadline
+	if l.Timeout > 0 {
+		deadline := time.Now().Add(l.Timeout)
+		if l.runDeadline.IsZero() || deadline.Before(l.runDeadline) {
+			l.runDeadline = deadline
+		}
+	}
+}
+
+// Step counts a statement, or an instruction of the vm.
+func (l *Limits) Step() {
+	l.steps += 1
+}
+
+// Check fails with a LimitError once the step budget, the context or the
+// deadline of the run is exhausted, token is where execution is stopped.
+func (l *Limits) Check(token *scan.Token) error {
+	if l.MaxSteps > 0 && l.steps > l.MaxSteps {
+		return newLimitError(ErrStepLimit, token)
+	}
+	if l.Context != nil {
+		if err := l.Context.Err(); err != nil {
+			return newLimitError(err, token)
+		}
+	}
+	if !l.runDeadline.IsZero() && time.Now().After(l.runDeadline) {
+		return newLimitError(ErrTimeout, token)
+	}
+	return nil
+}
+
+// EnterCall counts a Lox function call, also those made by natives, and
+// fails with a stack overflow instead of letting Go run out of stack.
+func (l *Limits) EnterCall(name *scan.Token) error {
+	if l.MaxCallDepth > 0 && l.callDepth >= l.MaxCallDepth {
+		return NewRuntimeError("stack overflow", name)
+	}
+	l.callDepth += 1
+	return nil
+}
+
+func (l *Limits) ExitCall() {
+	l.callDepth -= 1
+}
@@ -0,0 +1,91 @@
+package interpret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	token := scan.Token{Lexeme: "f"}
+
+	limits := Limits{MaxSteps: 2, MaxCallDepth: 1}
+	limits.Begin()
+	limits.Step()
+	limits.Step()
+	assert.NoError(t, limits.Check(&token))
+	limits.Step()
+	assert.True(t, errors.Is(limits.Check(&token), ErrStepLimit))
+
+	assert.NoError(t, limits.EnterCall(&token))
+	assert.ErrorContains(t, limits.EnterCall(&token), "stack overflow")
+	limits.ExitCall()
+	assert.NoError(t, limits.EnterCall(&token))
+
+	// a new run starts with fresh budgets
+	limits.Begin()
+	assert.NoError(t, limits.Check(&token))
+}
+
+func TestCollectionMethods_Allocate(t *testing.T) {
+	limits := Limits{MaxMemory: int64(ListAllocation(2))}
+	limits.Begin()
+
+	list := scan.NewLoxList([]*scan.LoxValue{scan.NewFloatLoxValue(1), scan.NewFloatLoxValue(2)})
+	slice, err := ListMethod(list, scan.Token{Lexeme: "slice"}, &limits)
+	assert.NoError(t, err)
+	fn, _ := slice.Callable()
+	_, err = fn.Call([]*scan.LoxValue{scan.NewFloatLoxValue(0), scan.NewNilLoxValue()})
+	assert.NoError(t, err)
+	_, err = fn.Call([]*scan.LoxValue{scan.NewFloatLoxValue(0), scan.NewNilLoxValue()})
+	assert.True(t, errors.Is(err, ErrMemoryLimit))
+
+	_, err = MapMethod(scan.NewLoxMap(), scan.Token{Lexeme: "clear"}, &limits)
+	assert.ErrorContains(t, err, "undefined map method 'clear'")
+}
+
+func TestStringMethod_AllocateBeforeBuilding(t *testing.T) {
+	type testCase struct {
+		method   string
+		args     []*scan.LoxValue
+		expected int
+	}
+
+	value := strings.Repeat("ab", 500)
+	tcs := []testCase{
+		{"replace", []*scan.LoxValue{scan.NewStringLoxValue("a"), scan.NewStringLoxValue("xyz")}, StringAllocation(value) + 500*2},
+		{"replace", []*scan.LoxValue{scan.NewStringLoxValue("ab"), scan.NewStringLoxValue("")}, StringAllocation("")},
+		{"upper", nil, StringAllocation(value)},
+		{"split", []*scan.LoxValue{scan.NewStringLoxValue("b")}, ListAllocation(501) + 501*stringSize + len(value)},
+		{"substring", []*scan.LoxValue{scan.NewFloatLoxValue(-10)}, StringAllocation("ababababab")},
+		{"substring", []*scan.LoxValue{scan.NewStringLoxValue("a")}, 0},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("string_method_allocate_test_case_%d", i),
+			func(t *testing.T) {
+				limits := Limits{MaxMemory: int64(tc.expected)}
+				limits.Begin()
+				method, err := StringMethod(value, scan.Token{Lexeme: tc.method}, &limits)
+				assert.NoError(t, err)
+				fn, _ := method.Callable()
+				fn.Call(tc.args)
+				assert.Equal(t, int64(tc.expected), limits.allocated)
+
+				// the quota runs out before the result is built
+				limits = Limits{MaxMemory: int64(tc.expected) - 1}
+				limits.Begin()
+				method, _ = StringMethod(value, scan.Token{Lexeme: tc.method}, &limits)
+				fn, _ = method.Callable()
+				if _, err := fn.Call(tc.args); tc.expected > 0 {
+					assert.ErrorIs(t, err, ErrMemoryLimit)
+				}
+			},
+		)
+	}
+}
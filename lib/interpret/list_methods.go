@@ -0,0 +1,94 @@
+package interpret
+
+import (
+	"fmt"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type listMethod struct {
+	arity int
+	call  func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error)
+}
+
+var listMethods = map[string]listMethod{
+	"len": {0, func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewFloatLoxValue(float64(list.Len())), nil
+	}},
+	"push": {1, func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		list.Push(args[0])
+		return scan.NewNilLoxValue(), nil
+	}},
+	"pop": {0, func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return list.Pop()
+	}},
+	"insert": {2, func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		index, err := args[0].Number()
+		if err != nil {
+			return nil, err
+		}
+		if err := list.Insert(index, args[1]); err != nil {
+			return nil, err
+		}
+		return scan.NewNilLoxValue(), nil
+	}},
+	"remove": {1, func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		index, err := args[0].Number()
+		if err != nil {
+			return nil, err
+		}
+		return list.Remove(index)
+	}},
+	// slice(start, end), nil end means up to the end of the list
+	"slice": {2, func(list *scan.LoxList, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		start, err := args[0].Number()
+		if err != nil {
+			return nil, err
+		}
+		end := float64(list.Len())
+		if !args[1].IsNil() {
+			if end, err = args[1].Number(); err != nil {
+				return nil, err
+			}
+		}
+		slice, err := list.Slice(start, end)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewListLoxValue(slice), nil
+	}},
+}
+
+// allocatingListMethods return a new collection, their results count
+// towards the memory limit.
+var allocatingListMethods = map[string]bool{
+	"slice": true,
+}
+
+// ListMethod returns the built-in method name bound to list, memory the
+// method allocates is accounted in limits.
+func ListMethod(list *scan.LoxList, name scan.Token, limits *Limits) (*scan.LoxValue, error) {
+	method, ok := listMethods[name.Lexeme]
+	if !ok {
+		return nil, NewRuntimeError(fmt.Sprintf("undefined list method '%s'", name.Lexeme), &name)
+	}
+	return scan.NewCallableLoxValue(
+		NewNativeFunction(name.Lexeme, method.arity, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			lengthBefore := list.Len()
+			result, err := method.call(list, args)
+			if err != nil {
+				return nil, err
+			}
+			allocated := 0
+			if grown := list.Len() - lengthBefore; grown > 0 {
+				allocated += grown * elementSize
+			}
+			if allocatingListMethods[name.Lexeme] {
+				allocated += resultAllocation(result)
+			}
+			if err := limits.Allocate(allocated, &name); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}),
+	), nil
+}
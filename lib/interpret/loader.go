@@ -0,0 +1,167 @@
+package interpret
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrModuleNotFound is returned by a ModuleLoader when no module matches an import.
+var ErrModuleNotFound = errors.New("module not found")
+
+// ModuleLoader finds the modules of import statements and reads their source.
+type ModuleLoader interface {
+	// Resolve returns the path of the module imported as name by the module
+	// or script at importer (the file name of its tokens, e.g. <string> for
+	// sources not read by a loader). The path identifies the module: it is
+	// the key of the module cache and the file name in error positions.
+	// Resolve returns an error wrapping ErrModuleNotFound for missing modules.
+	Resolve(importer, name string) (string, error)
+	// Load returns the source of the module at a path returned by Resolve.
+	Load(path string) (string, error)
+}
+
+// WithModuleLoader sets where imported modules come from, by default they
+// are files found by NewFileLoader().
+func WithModuleLoader(loader ModuleLoader) InterpreterOption {
+	return func(i *Interpreter) {
+		i.loader = loader
+	}
+}
+
+// WithModulePath loads modules from files, searching dirs after the
+// directory of the importing script, see NewFileLoader.
+func WithModulePath(dirs ...string) InterpreterOption {
+	return WithModuleLoader(NewFileLoader(dirs...))
+}
+
+// resolveCandidates tries the name next to the importer, then in every
+// directory of search, and returns the first candidate that exists.
+// Candidates are built by join, which also cleans them.
+func resolveCandidates(
+	importer, name string,
+	search []string,
+	join func(elem ...string) string,
+	isModule func(candidate string) bool,
+) (string, error) {
+	// an importer that is not a module itself (<string>, <stdin>) imports from the root
+	candidates := []string{join(name)}
+	if isModule(importer) {
+		candidates[0] = join(importer, "..", name)
+	}
+	for _, dir := range search {
+		candidates = append(candidates, join(dir, name))
+	}
+	for _, candidate := range candidates {
+		if isModule(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+}
+
+// FileLoader loads modules from the file system. Relative names are looked
+// up next to the importing file, then in the directories of Path; a script
+// not read from a file imports relative to the working directory. Resolved
+// paths are absolute, so a file imported by different names is one module.
+type FileLoader struct {
+	Path []string
+}
+
+func NewFileLoader(path ...string) *FileLoader {
+	return &FileLoader{Path: path}
+}
+
+func (l *FileLoader) Resolve(importer, name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(name) {
+		var err error
+		if path, err = resolveCandidates(importer, name, l.Path, filepath.Join, isFile); err != nil {
+			return "", err
+		}
+	} else if !isFile(name) {
+		return "", fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+	}
+	return filepath.Abs(path)
+}
+
+func (l *FileLoader) Load(path string) (string, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// FSLoader loads modules from an fs.FS, e.g. an embed.FS compiled into the
+// host. Names are slash separated and relative to the importing module, then
+// to the directories of Path; a leading slash makes a name relative to the
+// root of the FS.
+type FSLoader struct {
+	FS   fs.FS
+	Path []string
+}
+
+func NewFSLoader(fsys fs.FS, path ...string) *FSLoader {
+	return &FSLoader{FS: fsys, Path: path}
+}
+
+func (l *FSLoader) Resolve(importer, name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return resolveCandidates("", strings.TrimPrefix(name, "/"), nil, path.Join, l.isModule)
+	}
+	return resolveCandidates(importer, name, l.Path, path.Join, l.isModule)
+}
+
+func (l *FSLoader) Load(path string) (string, error) {
+	source, err := fs.ReadFile(l.FS, path)
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+func (l *FSLoader) isModule(path string) bool {
+	if !fs.ValidPath(path) {
+		return false
+	}
+	info, err := fs.Stat(l.FS, path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// MapLoader serves modules from memory, keys are slash separated module
+// paths and values their sources. Names are resolved as by FSLoader.
+type MapLoader map[string]string
+
+func NewMapLoader(modules map[string]string) MapLoader {
+	return MapLoader(modules)
+}
+
+func (l MapLoader) Resolve(importer, name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return resolveCandidates("", strings.TrimPrefix(name, "/"), nil, path.Join, l.isModule)
+	}
+	return resolveCandidates(importer, name, nil, path.Join, l.isModule)
+}
+
+func (l MapLoader) Load(path string) (string, error) {
+	source, ok := l[path]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrModuleNotFound, path)
+	}
+	return source, nil
+}
+
+func (l MapLoader) isModule(path string) bool {
+	_, ok := l[path]
+	return ok
+}
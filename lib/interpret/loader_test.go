@@ -0,0 +1,127 @@
+package interpret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleLoader_Resolve(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"app/main.lox":   ``,
+		"app/util.lox":   ``,
+		"lib/shared.lox": ``,
+	})
+	modules := map[string]string{
+		"app/main.lox":   ``,
+		"app/util.lox":   ``,
+		"lib/shared.lox": ``,
+	}
+
+	type testCase struct {
+		loader   ModuleLoader
+		importer string
+		name     string
+		expected string
+	}
+
+	tcs := []testCase{
+		{NewFileLoader(), filepath.Join(dir, "app/main.lox"), "util.lox", filepath.Join(dir, "app/util.lox")},
+		{NewFileLoader(), filepath.Join(dir, "app/main.lox"), "../lib/shared.lox", filepath.Join(dir, "lib/shared.lox")},
+		{NewFileLoader(), "<string>", filepath.Join(dir, "lib/shared.lox"), filepath.Join(dir, "lib/shared.lox")},
+		{NewFileLoader(filepath.Join(dir, "lib")), filepath.Join(dir, "app/main.lox"), "shared.lox", filepath.Join(dir, "lib/shared.lox")},
+		{NewFileLoader(), filepath.Join(dir, "app/main.lox"), "shared.lox", ""},
+		{NewFSLoader(fstest.MapFS(mapFiles(modules))), "app/main.lox", "util.lox", "app/util.lox"},
+		{NewFSLoader(fstest.MapFS(mapFiles(modules))), "app/main.lox", "/lib/shared.lox", "lib/shared.lox"},
+		{NewFSLoader(fstest.MapFS(mapFiles(modules)), "lib"), "app/main.lox", "shared.lox", "lib/shared.lox"},
+		{NewFSLoader(fstest.MapFS(mapFiles(modules))), "<string>", "app/main.lox", "app/main.lox"},
+		{NewFSLoader(fstest.MapFS(mapFiles(modules))), "app/main.lox", "../../outside.lox", ""},
+		{NewMapLoader(modules), "app/main.lox", "./util.lox", "app/util.lox"},
+		{NewMapLoader(modules), "app/util.lox", "../lib/shared.lox", "lib/shared.lox"},
+		{NewMapLoader(modules), "<string>", "lib/shared.lox", "lib/shared.lox"},
+		{NewMapLoader(modules), "<string>", "shared.lox", ""},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("module_loader_resolve_test_case_%d", i),
+			func(t *testing.T) {
+				path, err := tc.loader.Resolve(tc.importer, tc.name)
+				if tc.expected == "" {
+					assert.True(t, errors.Is(err, ErrModuleNotFound), err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, path)
+			},
+		)
+	}
+}
+
+func mapFiles(modules map[string]string) map[string]*fstest.MapFile {
+	files := make(map[string]*fstest.MapFile, len(modules))
+	for path, source := range modules {
+		files[path] = &fstest.MapFile{Data: []byte(source)}
+	}
+	return files
+}
+
+func TestInterpreter_ImportFileOnce(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"app/util.lox": `print "loaded";`,
+	})
+	main := fmt.Sprintf(`import "util.lox"; import "./util.lox"; import "%s";`, filepath.Join(dir, "app/util.lox"))
+	if err := os.WriteFile(filepath.Join(dir, "app/main.lox"), []byte(main), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the script is run by a relative path, the last import is absolute
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	buf := bytes.NewBufferString("")
+	assert.NoError(t, runModule(t, NewInterpreter(buf), filepath.Join("app", "main.lox")))
+	assert.Equal(t, "loaded\n", buf.String())
+}
+
+func TestInterpreter_ImportWithLoader(t *testing.T) {
+	loader := NewMapLoader(map[string]string{
+		"std/strings.lox": `import "/std/list.lox"; fun join(xs, sep) { return reduce(xs, fun_join(sep)); }
+fun fun_join(sep) { fun join(a, b) { return a + sep + b; } return join; }`,
+		"std/list.lox": `fun reduce(xs, f) { var acc = xs[0]; for (var i = 1; i < xs.len(); i = i + 1) acc = f(acc, xs[i]); return acc; }`,
+	})
+
+	buf := bytes.NewBufferString("")
+	interpreter := NewInterpreter(buf, WithModuleLoader(loader))
+	tokens, err := scan.NewFileScanner("<string>", `import s from "std/strings.lox"; print s.join(["a", "b", "c"], "-");`).ScanTokens()
+	assert.NoError(t, err)
+	stmts, err := parse.NewParser(tokens).Parse()
+	assert.NoError(t, err)
+	assert.NoError(t, NewResolver(interpreter).Resolve(stmts))
+	assert.NoError(t, interpreter.Interpret(stmts))
+	assert.Equal(t, "a-b-c\n", buf.String())
+
+	// errors point into the module they happen in
+	loader["std/broken.lox"] = "fun boom() { return nil + 1; }"
+	tokens, _ = scan.NewFileScanner("<string>", `import b from "std/broken.lox"; b.boom();`).ScanTokens()
+	stmts, _ = parse.NewParser(tokens).Parse()
+	assert.NoError(t, NewResolver(interpreter).Resolve(stmts))
+	err = interpreter.Interpret(stmts)
+	var runtimeErr *RuntimeError
+	if assert.ErrorAs(t, err, &runtimeErr) {
+		assert.Equal(t, "std/broken.lox", runtimeErr.Token().Position.File)
+	}
+}
@@ -0,0 +1,79 @@
+package interpret
+
+import (
+	"fmt"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type mapMethod struct {
+	arity int
+	call  func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error)
+}
+
+var mapMethods = map[string]mapMethod{
+	"len": {0, func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewFloatLoxValue(float64(loxMap.Len())), nil
+	}},
+	// keys and values return lists in insertion order, which is how maps are iterated
+	"keys": {0, func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewListLoxValue(scan.NewLoxList(loxMap.Keys())), nil
+	}},
+	"values": {0, func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewListLoxValue(scan.NewLoxList(loxMap.Values())), nil
+	}},
+	"has": {1, func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		ok, err := loxMap.Has(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewBooleanLoxValue(ok), nil
+	}},
+	"delete": {1, func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		ok, err := loxMap.Delete(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewBooleanLoxValue(ok), nil
+	}},
+	// get(key, fallback) returns fallback instead of failing on a missing key
+	"get": {2, func(loxMap *scan.LoxMap, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		value, ok, err := loxMap.Get(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return args[1], nil
+		}
+		return value, nil
+	}},
+}
+
+// allocatingMapMethods return a new collection, their results count
+// towards the memory limit.
+var allocatingMapMethods = map[string]bool{
+	"keys":   true,
+	"values": true,
+}
+
+// MapMethod returns the built-in method name bound to loxMap, memory the
+// method allocates is accounted in limits.
+func MapMethod(loxMap *scan.LoxMap, name scan.Token, limits *Limits) (*scan.LoxValue, error) {
+	method, ok := mapMethods[name.Lexeme]
+	if !ok {
+		return nil, NewRuntimeError(fmt.Sprintf("undefined map method '%s'", name.Lexeme), &name)
+	}
+	return scan.NewCallableLoxValue(
+		NewNativeFunction(name.Lexeme, method.arity, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			result, err := method.call(loxMap, args)
+			if err != nil {
+				return nil, err
+			}
+			if allocatingMapMethods[name.Lexeme] {
+				if err := limits.Allocate(resultAllocation(result), &name); err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}),
+	), nil
+}
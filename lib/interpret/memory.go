@@ -0,0 +1,68 @@
+package interpret
+
+import (
+	"errors"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// Approximate sizes in bytes of what scripts allocate. They don't need to be
+// exact, only to grow with what a script keeps creating.
+const (
+	valueSize       = 64
+	stringSize      = 16
+	EnvironmentSize = 96
+	InstanceSize    = 96
+	FieldSize       = 48
+	collectionSize  = 48
+	elementSize     = 8
+	MapEntrySize    = 64
+)
+
+// WithMaxMemory limits the approximate number of bytes a single run may
+// allocate for strings, environments, class instances and collections.
+// Past the limit execution stops with a LimitError wrapping ErrMemoryLimit.
+func WithMaxMemory(bytes int64) InterpreterOption {
+	return func(i *Interpreter) {
+		i.limits.MaxMemory = bytes
+	}
+}
+
+// Allocate accounts for bytes allocated by the script, token is where the
+// error is reported when the quota is exceeded.
+func (l *Limits) Allocate(bytes int, token *scan.Token) error {
+	l.allocated += int64(bytes)
+	if l.MaxMemory > 0 && l.allocated > l.MaxMemory {
+		return newLimitError(ErrMemoryLimit, token)
+	}
+	return nil
+}
+
+func StringAllocation(value string) int {
+	return stringSize + len(value)
+}
+
+func ListAllocation(length int) int {
+	return collectionSize + length*elementSize
+}
+
+func MapAllocation(length int) int {
+	return collectionSize + length*MapEntrySize
+}
+
+// resultAllocation is the allocation of a value freshly created by a native method.
+func resultAllocation(result *scan.LoxValue) int {
+	switch {
+	case result.IsString():
+		return StringAllocation(result.String())
+	case result.IsList():
+		list, _ := result.List()
+		return ListAllocation(list.Len())
+	case result.IsMap():
+		loxMap, _ := result.Map()
+		return MapAllocation(loxMap.Len())
+	}
+	return 0
+}
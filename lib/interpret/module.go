@@ -0,0 +1,207 @@
+package interpret
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// Module is a script loaded by an import statement. Its top-level names live
+// in a scope of its own, the names not starting with '_' are exported. A
+// named import binds the module itself, its exports are read as properties.
+type Module struct {
+	path  string
+	scope ModuleScope
+}
+
+// ModuleScope holds the top-level names of a module: the script environment
+// of the tree-walking interpreter, the globals of the bytecode VM.
+type ModuleScope interface {
+	// Names returns the names defined in the scope in sorted order.
+	Names() []string
+	Lookup(name string) (*scan.LoxValue, bool)
+}
+
+// NewModule creates the module loaded from path whose top-level names are in scope.
+func NewModule(path string, scope ModuleScope) *Module {
+	return &Module{path: path, scope: scope}
+}
+
+// Path returns the path the module was loaded from.
+func (m *Module) Path() string {
+	return m.path
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("[module] %s", m.path)
+}
+
+// Exports returns the exported names in sorted order.
+func (m *Module) Exports() []string {
+	exports := make([]string, 0)
+	for _, name := range m.scope.Names() {
+		if isExported(name) {
+			exports = append(exports, name)
+		}
+	}
+	return exports
+}
+
+func (m *Module) Get(name scan.Token) (*scan.LoxValue, error) {
+	if isExported(name.Lexeme) {
+		if value, ok := m.scope.Lookup(name.Lexeme); ok {
+			return value, nil
+		}
+	}
+	return nil, NewRuntimeError(fmt.Sprintf("undefined module export '%s'", name.Lexeme), &name)
+}
+
+func (m *Module) Set(name scan.Token, value *scan.LoxValue) error {
+	return NewRuntimeError("can't assign to a module export", &name)
+}
+
+// Fields returns the exports, so a module converts to a Go map.
+func (m *Module) Fields() map[string]*scan.LoxValue {
+	fields := make(map[string]*scan.LoxValue)
+	for _, name := range m.Exports() {
+		fields[name], _ = m.scope.Lookup(name)
+	}
+	return fields
+}
+
+func isExported(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
+
+func (i *Interpreter) VisitStmtImport(stmt *parse.StmtImport) (interface{}, error) {
+	module, err := i.importModule(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Name != nil {
+		i.environment.Define(stmt.Name.Lexeme, scan.NewClassInstanceLoxValue(module))
+		return nil, nil
+	}
+	for _, name := range module.Exports() {
+		value, _ := module.scope.Lookup(name)
+		i.environment.Define(name, value)
+	}
+	return nil, nil
+}
+
+// InterpretScript runs the statements of the script read from name like
+// Interpret. A script the module loader finds is a module while it runs:
+// importing it back is an import cycle, importing it afterwards gives the
+// script itself instead of running it again.
+func (i *Interpreter) InterpretScript(name string, stmts []parse.Statement) error {
+	if path, ok := ResolveScript(i.loader, name); ok {
+		if _, ok := i.modules[path]; !ok {
+			i.modules[path] = NewModule(path, i.script)
+		}
+		i.importing = append(i.importing, path)
+		defer func() {
+			i.importing = i.importing[:len(i.importing)-1]
+		}()
+	}
+	return i.Interpret(stmts)
+}
+
+// importModule returns the module from the cache or runs it in a new script
+// environment, every module runs once per interpreter.
+func (i *Interpreter) importModule(stmt *parse.StmtImport) (*Module, error) {
+	path, err := ResolveModule(i.loader, stmt.ModulePath(), &stmt.Path)
+	if err != nil {
+		return nil, err
+	}
+	// the running script is cached already, importing it is still a cycle
+	if err := CheckImportCycle(i.importing, path, &stmt.Path); err != nil {
+		return nil, err
+	}
+	if module, ok := i.modules[path]; ok {
+		return module, nil
+	}
+	stmts, err := LoadModule(i.loader, path, &stmt.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.limits.Allocate(EnvironmentSize, &stmt.Path); err != nil {
+		return nil, err
+	}
+
+	environment := NewEnvironment(i.globals)
+	prevEnv, prevScript := i.environment, i.script
+	i.environment, i.script = environment, environment
+	i.importing = append(i.importing, path)
+	// the module runs in a frame of its own, called at the import
+	i.callStack = append(i.callStack, callFrame{function: ScriptFrameName, callSite: stmt.Path})
+	defer func() {
+		i.environment, i.script = prevEnv, prevScript
+		i.importing = i.importing[:len(i.importing)-1]
+		i.popFrame()
+	}()
+
+	if err := NewResolver(i).Resolve(stmts); err != nil {
+		return nil, err
+	}
+	for _, moduleStmt := range stmts {
+		if _, err := i.execute(moduleStmt); err != nil {
+			return nil, i.attachTrace(err)
+		}
+	}
+	module := NewModule(path, environment)
+	i.modules[path] = module
+	return module, nil
+}
+
+// ResolveModule returns the path of the module imported as name by the file
+// of token, the module path of the import. Errors are reported at token.
+func ResolveModule(loader ModuleLoader, name string, token *scan.Token) (string, error) {
+	path, err := loader.Resolve(token.Position.File, name)
+	if errors.Is(err, ErrModuleNotFound) {
+		return "", NewRuntimeError(fmt.Sprintf("module %q not found", name), token)
+	} else if err != nil {
+		return "", ConvertToRuntimeError("can't resolve module", err, token)
+	}
+	return path, nil
+}
+
+// ResolveScript returns the module path of the script read from name, ok is
+// false when the loader doesn't find it, e.g. for <string>.
+func ResolveScript(loader ModuleLoader, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	// the script imports itself by its base name, next to itself
+	resolved, err := loader.Resolve(name, "./"+path.Base(filepath.ToSlash(name)))
+	return resolved, err == nil
+}
+
+// CheckImportCycle reports an import of path while the modules of the
+// importing chain are still running.
+func CheckImportCycle(importing []string, path string, token *scan.Token) error {
+	for index, running := range importing {
+		if running == path {
+			cycle := append(importing[index:len(importing):len(importing)], path)
+			return NewRuntimeError(fmt.Sprintf("import cycle: %s", strings.Join(cycle, " -> ")), token)
+		}
+	}
+	return nil
+}
+
+// LoadModule reads and parses the module at path, token is the import it is loaded for.
+func LoadModule(loader ModuleLoader, path string, token *scan.Token) ([]parse.Statement, error) {
+	source, err := loader.Load(path)
+	if err != nil {
+		return nil, ConvertToRuntimeError("can't read module", err, token)
+	}
+	tokens, err := scan.NewFileScanner(path, source).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	return parse.NewParser(tokens).Parse()
+}
@@ -0,0 +1,171 @@
+package interpret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeModules writes files (path → source) into a new directory and returns it.
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for path, source := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runModule runs the file at path the way a script read from disk is run.
+func runModule(t *testing.T, interpreter *Interpreter, path string) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := scan.NewFileScanner(path, string(source)).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewResolver(interpreter).Resolve(stmts); err != nil {
+		return err
+	}
+	return interpreter.Interpret(stmts)
+}
+
+func TestInterpreter_Import(t *testing.T) {
+	type testCase struct {
+		files    map[string]string
+		expected string
+		err      string
+	}
+
+	tcs := []testCase{
+		{
+			files: map[string]string{
+				"main.lox":     `import "lib/math.lox"; print square(3); print PI;`,
+				"lib/math.lox": `var PI = 3.14; fun square(x) { return x * x; }`,
+			},
+			expected: "9\n3.14\n",
+		}, {
+			files: map[string]string{
+				"main.lox":     `import m from "lib/math.lox"; print m.square(4); print m;`,
+				"lib/math.lox": `fun square(x) { return x * x; }`,
+			},
+			expected: "16\n[module] " + filepath.Join("%dir", "lib/math.lox") + "\n",
+		}, {
+			// the module keeps its own globals, late-bound names are looked up there
+			files: map[string]string{
+				"main.lox":    `var helper = "main"; import c from "counter.lox"; c.inc(); c.inc(); print c.count(); print helper;`,
+				"counter.lox": `var _n = 0; fun inc() { _n = _n + helper(); } fun count() { return _n; } fun helper() { return 1; }`,
+			},
+			expected: "2\nmain\n",
+		}, {
+			// a module runs once, every import shares it
+			files: map[string]string{
+				"main.lox":   `import a from "shared.lox"; import "user.lox"; a.set(5); print get();`,
+				"user.lox":   `import s from "shared.lox"; fun get() { return s.get(); }`,
+				"shared.lox": `print "loaded"; var _value = 0; fun set(v) { _value = v; } fun get() { return _value; }`,
+			},
+			expected: "loaded\n5\n",
+		}, {
+			// imports are relative to the importing module
+			files: map[string]string{
+				"main.lox":  `import "pkg/a.lox"; print a();`,
+				"pkg/a.lox": `import "b.lox"; fun a() { return "a" + b(); }`,
+				"pkg/b.lox": `fun b() { return "b"; }`,
+			},
+			expected: "ab\n",
+		}, {
+			files: map[string]string{
+				"main.lox": `import m from "m.lox"; print m._private;`,
+				"m.lox":    `var _private = 1;`,
+			},
+			err: "undefined module export '_private'",
+		}, {
+			files: map[string]string{
+				"main.lox": `import m from "m.lox"; m.x = 1;`,
+				"m.lox":    `var x = 0;`,
+			},
+			err: "can't assign to a module export",
+		}, {
+			files: map[string]string{
+				"main.lox": `import "a.lox";`,
+				"a.lox":    `import "b.lox";`,
+				"b.lox":    `import "a.lox";`,
+			},
+			err: "import cycle: %dir/a.lox -> %dir/b.lox -> %dir/a.lox",
+		}, {
+			files: map[string]string{
+				"main.lox": `import "missing.lox";`,
+			},
+			err: `module "missing.lox" not found`,
+		}, {
+			files: map[string]string{
+				"main.lox":   `import "broken.lox";`,
+				"broken.lox": `print 1 / 0;`,
+			},
+			err: "zero division error",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_import_test_case_%d", i),
+			func(t *testing.T) {
+				dir := writeModules(t, tc.files)
+				buf := bytes.NewBufferString("")
+				err := runModule(t, NewInterpreter(buf), filepath.Join(dir, "main.lox"))
+				if tc.err != "" {
+					if assert.Error(t, err) {
+						assert.Contains(t, err.Error(), strings.ReplaceAll(tc.err, "%dir", dir))
+					}
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, strings.ReplaceAll(tc.expected, "%dir", dir), buf.String())
+			},
+		)
+	}
+}
+
+func TestInterpreter_ImportModulePath(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"app/main.lox":       `import strings from "strings.lox"; print strings.shout("hi");`,
+		"vendor/strings.lox": `fun shout(s) { return s + "!"; }`,
+	})
+
+	err := runModule(t, NewInterpreter(bytes.NewBufferString("")), filepath.Join(dir, "app/main.lox"))
+	assert.ErrorContains(t, err, `module "strings.lox" not found`)
+
+	buf := bytes.NewBufferString("")
+	interpreter := NewInterpreter(buf, WithModulePath(filepath.Join(dir, "vendor")))
+	assert.NoError(t, runModule(t, interpreter, filepath.Join(dir, "app/main.lox")))
+	assert.Equal(t, "hi!\n", buf.String())
+}
+
+func TestResolver_ImportOutsideTopLevel(t *testing.T) {
+	for _, source := range []string{`{ import "m.lox"; }`, `fun f() { import "m.lox"; }`} {
+		tokens, err := scan.NewScanner(source).ScanTokens()
+		assert.NoError(t, err)
+		stmts, err := parse.NewParser(tokens).Parse()
+		assert.NoError(t, err)
+		err = NewResolver(NewInterpreter(bytes.NewBufferString(""))).Resolve(stmts)
+		assert.ErrorContains(t, err, "can't import outside of top-level code")
+	}
+}
@@ -0,0 +1,124 @@
+package interpret
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// Helpers for native functions to read typed arguments. Indexes are 0-based,
+// error messages count arguments from 1 the way script authors do.
+
+// CheckArgCount checks that a variadic native got between min and max
+// arguments, a negative max means no upper bound.
+func CheckArgCount(args []*scan.LoxValue, min, max int) error {
+	if len(args) < min {
+		return fmt.Errorf("expected at least %d arguments but got %d", min, len(args))
+	}
+	if max >= 0 && len(args) > max {
+		return fmt.Errorf("expected at most %d arguments but got %d", max, len(args))
+	}
+	return nil
+}
+
+func arg(args []*scan.LoxValue, index int) (*scan.LoxValue, error) {
+	if index < 0 || index >= len(args) {
+		return nil, fmt.Errorf("missing argument %d", index+1)
+	}
+	return args[index], nil
+}
+
+func argTypeError(index int, expected string, value *scan.LoxValue) error {
+	return fmt.Errorf("argument %d must be a %s, got %s", index+1, expected, value.TypeName())
+}
+
+// OptionalArg returns the argument at index or fallback when it was not passed.
+func OptionalArg(args []*scan.LoxValue, index int, fallback *scan.LoxValue) *scan.LoxValue {
+	if index < 0 || index >= len(args) {
+		return fallback
+	}
+	return args[index]
+}
+
+func NumberArg(args []*scan.LoxValue, index int) (float64, error) {
+	value, err := arg(args, index)
+	if err != nil {
+		return 0., err
+	}
+	if !value.IsNumber() {
+		return 0., argTypeError(index, "number", value)
+	}
+	return value.Number()
+}
+
+// IntArg reads a number argument that must have no fractional part.
+func IntArg(args []*scan.LoxValue, index int) (int, error) {
+	number, err := NumberArg(args, index)
+	if err != nil {
+		return 0, err
+	}
+	if number != math.Trunc(number) {
+		return 0, fmt.Errorf("argument %d must be an integer, got %v", index+1, number)
+	}
+	// converting numbers out of the int range is implementation-defined
+	if number < math.MinInt || number >= -math.MinInt {
+		return 0, fmt.Errorf("argument %d out of range, got %v", index+1, number)
+	}
+	return int(number), nil
+}
+
+func StringArg(args []*scan.LoxValue, index int) (string, error) {
+	value, err := arg(args, index)
+	if err != nil {
+		return "", err
+	}
+	if !value.IsString() {
+		return "", argTypeError(index, "string", value)
+	}
+	return value.String(), nil
+}
+
+func BoolArg(args []*scan.LoxValue, index int) (bool, error) {
+	value, err := arg(args, index)
+	if err != nil {
+		return false, err
+	}
+	if !value.IsBoolean() {
+		return false, argTypeError(index, "bool", value)
+	}
+	return value.Bool(), nil
+}
+
+func ListArg(args []*scan.LoxValue, index int) (*scan.LoxList, error) {
+	value, err := arg(args, index)
+	if err != nil {
+		return nil, err
+	}
+	if !value.IsList() {
+		return nil, argTypeError(index, "list", value)
+	}
+	return value.List()
+}
+
+func MapArg(args []*scan.LoxValue, index int) (*scan.LoxMap, error) {
+	value, err := arg(args, index)
+	if err != nil {
+		return nil, err
+	}
+	if !value.IsMap() {
+		return nil, argTypeError(index, "map", value)
+	}
+	return value.Map()
+}
+
+func CallableArg(args []*scan.LoxValue, index int) (scan.LoxCallable, error) {
+	value, err := arg(args, index)
+	if err != nil {
+		return nil, err
+	}
+	if !value.IsCallable() && !value.IsClass() {
+		return nil, argTypeError(index, "function", value)
+	}
+	return value.Callable()
+}
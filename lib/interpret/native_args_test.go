@@ -0,0 +1,71 @@
+package interpret
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNativeArgs(t *testing.T) {
+	list := scan.NewLoxList([]*scan.LoxValue{scan.NewFloatLoxValue(1)})
+	args := []*scan.LoxValue{
+		scan.NewFloatLoxValue(2.5),
+		scan.NewStringLoxValue("s"),
+		scan.NewBooleanLoxValue(true),
+		scan.NewListLoxValue(list),
+		scan.NewFloatLoxValue(3),
+	}
+	outOfRange := []*scan.LoxValue{scan.NewFloatLoxValue(math.Ldexp(1, 70)), scan.NewFloatLoxValue(math.Inf(-1))}
+
+	number, err := NumberArg(args, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, number)
+	str, err := StringArg(args, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "s", str)
+	boolean, err := BoolArg(args, 2)
+	assert.NoError(t, err)
+	assert.True(t, boolean)
+	listArg, err := ListArg(args, 3)
+	assert.NoError(t, err)
+	assert.Same(t, list, listArg)
+	integer, err := IntArg(args, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, integer)
+	assert.Equal(t, "nil", OptionalArg(args, 5, scan.NewNilLoxValue()).String())
+	assert.Equal(t, "s", OptionalArg(args, 1, scan.NewNilLoxValue()).String())
+
+	type testCase struct {
+		read     func() error
+		expected string
+	}
+
+	tcs := []testCase{
+		{func() error { _, err := NumberArg(args, 1); return err }, "argument 2 must be a number, got string"},
+		{func() error { _, err := IntArg(args, 0); return err }, "argument 1 must be an integer, got 2.5"},
+		{func() error { _, err := IntArg(outOfRange, 0); return err }, "argument 1 out of range, got 1.1805916207174113e+21"},
+		{func() error { _, err := IntArg(outOfRange, 1); return err }, "argument 2 out of range, got -Inf"},
+		{func() error { _, err := StringArg(args, 0); return err }, "argument 1 must be a string, got float"},
+		{func() error { _, err := BoolArg(args, 3); return err }, "argument 4 must be a bool, got list"},
+		{func() error { _, err := MapArg(args, 3); return err }, "argument 4 must be a map, got list"},
+		{func() error { _, err := CallableArg(args, 2); return err }, "argument 3 must be a function, got bool"},
+		{func() error { _, err := ListArg(args, 7); return err }, "missing argument 8"},
+		{func() error { return CheckArgCount(args, 0, 2) }, "expected at most 2 arguments but got 5"},
+		{func() error { return CheckArgCount(args, 6, -1) }, "expected at least 6 arguments but got 5"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("native_args_test_case_%d", i),
+			func(t *testing.T) {
+				err := tc.read()
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.expected, err.Error())
+				}
+			},
+		)
+	}
+}
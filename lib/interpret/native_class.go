@@ -0,0 +1,152 @@
+package interpret
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// NativeConstructor creates the Go value held by a new native class instance.
+type NativeConstructor func(args []*scan.LoxValue) (interface{}, error)
+
+// NativeMethod is the Go implementation of a native class method,
+// self is the Go value held by the instance the method is called on.
+type NativeMethod func(self interface{}, args []*scan.LoxValue) (*scan.LoxValue, error)
+
+type nativeMethod struct {
+	arity int
+	fn    NativeMethod
+}
+
+type nativeProperty struct {
+	get func(self interface{}) (*scan.LoxValue, error)
+	set func(self interface{}, value *scan.LoxValue) error
+}
+
+// NativeClass is a Lox class implemented in Go. Its instances hold a Go value,
+// methods and properties registered on the class are backed by Go code.
+type NativeClass struct {
+	name        string
+	arity       int
+	constructor NativeConstructor
+	methods     map[string]nativeMethod
+	properties  map[string]nativeProperty
+	// goType is the type of instance values of classes built by ReflectClass
+	goType reflect.Type
+}
+
+// NewNativeClass creates a class, arity is the number of constructor
+// arguments or scan.VariadicArity. A nil constructor makes a class that
+// can't be instantiated from scripts, only wrap Go values with Wrap.
+func NewNativeClass(name string, arity int, constructor NativeConstructor) *NativeClass {
+	return &NativeClass{
+		name:        name,
+		arity:       arity,
+		constructor: constructor,
+		methods:     make(map[string]nativeMethod),
+		properties:  make(map[string]nativeProperty),
+	}
+}
+
+// Method registers a method, arity is checked before every call unless it
+// is scan.VariadicArity.
+func (nc *NativeClass) Method(name string, arity int, fn NativeMethod) *NativeClass {
+	nc.methods[name] = nativeMethod{arity: arity, fn: fn}
+	return nc
+}
+
+// Property registers a property, a nil set makes it read-only.
+func (nc *NativeClass) Property(
+	name string,
+	get func(self interface{}) (*scan.LoxValue, error),
+	set func(self interface{}, value *scan.LoxValue) error,
+) *NativeClass {
+	nc.properties[name] = nativeProperty{get: get, set: set}
+	return nc
+}
+
+// Name returns the class name scripts see.
+func (nc *NativeClass) Name() string {
+	return nc.name
+}
+
+func (nc *NativeClass) String() string {
+	return fmt.Sprintf("[class] %s", nc.name)
+}
+
+func (nc *NativeClass) Arity() int {
+	return nc.arity
+}
+
+func (nc *NativeClass) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	if nc.constructor == nil {
+		return nil, fmt.Errorf("class %s can't be instantiated", nc.name)
+	}
+	value, err := nc.constructor(args)
+	if err != nil {
+		return nil, err
+	}
+	return scan.NewClassInstanceLoxValue(nc.Wrap(value)), nil
+}
+
+// Wrap makes an instance of the class holding value, so Go values can be
+// handed to scripts, e.g. as native function results or globals.
+func (nc *NativeClass) Wrap(value interface{}) *NativeInstance {
+	return &NativeInstance{
+		class: nc,
+		value: value,
+	}
+}
+
+// NativeInstance is an instance of a NativeClass.
+type NativeInstance struct {
+	class *NativeClass
+	value interface{}
+}
+
+// Value returns the Go value held by the instance.
+func (ni *NativeInstance) Value() interface{} {
+	return ni.value
+}
+
+// Class returns the class of the instance.
+func (ni *NativeInstance) Class() *NativeClass {
+	return ni.class
+}
+
+func (ni *NativeInstance) String() string {
+	return fmt.Sprintf("[class instance] %s", ni.class.name)
+}
+
+func (ni *NativeInstance) Get(name scan.Token) (*scan.LoxValue, error) {
+	if property, ok := ni.class.properties[name.Lexeme]; ok {
+		value, err := property.get(ni.value)
+		if err != nil {
+			return nil, ConvertToRuntimeError("native property error", err, &name)
+		}
+		return value, nil
+	}
+	if method, ok := ni.class.methods[name.Lexeme]; ok {
+		return scan.NewCallableLoxValue(
+			NewNativeFunction(name.Lexeme, method.arity, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+				return method.fn(ni.value, args)
+			}),
+		), nil
+	}
+	return nil, NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), &name)
+}
+
+func (ni *NativeInstance) Set(name scan.Token, value *scan.LoxValue) error {
+	property, ok := ni.class.properties[name.Lexeme]
+	if !ok {
+		return NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), &name)
+	}
+	if property.set == nil {
+		return NewRuntimeError(fmt.Sprintf("property '%s' is read-only", name.Lexeme), &name)
+	}
+	if err := property.set(ni.value, value); err != nil {
+		return ConvertToRuntimeError("native property error", err, &name)
+	}
+	return nil
+}
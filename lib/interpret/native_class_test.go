@@ -0,0 +1,192 @@
+package interpret
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRequest struct {
+	Method  string
+	URL     string
+	Retries int
+	// Priority has a narrow integer type
+	Priority uint8
+	headers  map[string]string
+}
+
+func newTestRequest(url string) (*testRequest, error) {
+	if url == "" {
+		return nil, fmt.Errorf("empty url")
+	}
+	return &testRequest{Method: "GET", URL: url, headers: make(map[string]string)}, nil
+}
+
+func (r *testRequest) SetHeader(name, value string) *testRequest {
+	r.headers[strings.ToLower(name)] = value
+	return r
+}
+
+func (r *testRequest) Header(name string) string {
+	return r.headers[strings.ToLower(name)]
+}
+
+func (r *testRequest) Headers() map[string]string {
+	return r.headers
+}
+
+func (r *testRequest) Send(expectedStatus ...int) (int, error) {
+	if r.Method != "GET" {
+		return 0, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	if len(expectedStatus) > 0 {
+		return expectedStatus[0], nil
+	}
+	return 200, nil
+}
+
+func TestInterpreter_NativeClass(t *testing.T) {
+	type counter struct {
+		value float64
+	}
+	class := NewNativeClass("Counter", 1, func(args []*scan.LoxValue) (interface{}, error) {
+		start, err := NumberArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &counter{value: start}, nil
+	}).Method("inc", 0, func(self interface{}, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		self.(*counter).value += 1
+		return scan.NewNilLoxValue(), nil
+	}).Property("value", func(self interface{}) (*scan.LoxValue, error) {
+		return scan.NewFloatLoxValue(self.(*counter).value), nil
+	}, nil)
+
+	type testCase struct {
+		source   string
+		expected string
+		err      string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `var c = Counter(10); c.inc(); c.inc(); print c.value; print c; print Counter;`,
+			expected: "12\n[class instance] Counter\n[class] Counter\n",
+		},
+		{source: `Counter("a");`, err: "native function error: argument 1 must be a number, got string"},
+		{source: `Counter(1).value = 5;`, err: "property 'value' is read-only"},
+		{source: `Counter(1).other = 5;`, err: "undefined property 'other'"},
+		{source: `print Counter(1).other;`, err: "undefined property 'other'"},
+		{source: `class Sub < Counter {}`, err: "superclass must be a class declared in Lox, got [class] Counter"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_native_class_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				interpreter := NewInterpreter(buf)
+				interpreter.DefineClass(class)
+				err := interpretSourceWith(t, interpreter, tc.source)
+				if tc.err != "" {
+					if assert.Error(t, err) {
+						assert.Equal(t, tc.err, err.(*RuntimeError).Message())
+					}
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, buf.String())
+			},
+		)
+	}
+}
+
+func TestInterpreter_ReflectClass(t *testing.T) {
+	class, err := ReflectClass("HttpRequest", newTestRequest)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	type testCase struct {
+		source   string
+		expected string
+		err      string
+	}
+
+	tcs := []testCase{
+		{
+			source: `var r = HttpRequest("http://example.com");
+r.setHeader("X-Id", "1").setHeader("Accept", "text/plain");
+print r.header("x-id");
+print r.headers();
+print r.url + " " + r.method;
+r.retries = 3;
+print r.retries;
+print r.send();
+print r.send(404);`,
+			expected: "1\n{\"accept\": \"text/plain\", \"x-id\": \"1\"}\nhttp://example.com GET\n3\n200\n404\n",
+		},
+		{
+			source:   `var r = HttpRequest("u"); r.method = "POST"; try { r.send(); } catch (e) { print e; }`,
+			expected: "native function error: method POST not allowed\n",
+		},
+		{source: `HttpRequest("");`, err: "native function error: empty url"},
+		{source: `HttpRequest(1);`, err: "native function error: argument 1: expected a string, got float"},
+		{source: `HttpRequest("u").retries = 1.5;`, err: "native property error: expected an integer, got 1.5"},
+		{source: `var r = HttpRequest("u"); r.priority = 255; print r.priority;`, expected: "255\n"},
+		{source: `HttpRequest("u").priority = 256;`, err: "native property error: 256 out of range for uint8"},
+		{source: `HttpRequest("u").priority = -1;`, err: "native property error: -1 out of range for uint8"},
+		{
+			source: `var big = 1; for (var i = 0; i < 70; i = i + 1) big = big * 2; HttpRequest("u").retries = big;`,
+			err:    "native property error: 1.1805916207174113e+21 out of range for int",
+		},
+		{source: `HttpRequest("u").header();`, err: "expected 1 arguments but got 0"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("interpreter_reflect_class_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				interpreter := NewInterpreter(buf)
+				interpreter.DefineClass(class)
+				err := interpretSourceWith(t, interpreter, tc.source)
+				if tc.err != "" {
+					if assert.Error(t, err) {
+						assert.Equal(t, tc.err, err.(*RuntimeError).Message())
+					}
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, buf.String())
+			},
+		)
+	}
+
+	_, err = ReflectClass("Bad", 1)
+	assert.EqualError(t, err, "constructor of Bad must be a function, got int")
+	_, err = ReflectClass("Bad", func() {})
+	assert.EqualError(t, err, "constructor of Bad must return a value and optionally an error")
+	assert.Equal(t, "httpClient", loxName("HTTPClient"))
+	assert.Equal(t, "url", loxName("URL"))
+	assert.Equal(t, "setHeader", loxName("SetHeader"))
+}
+
+func TestNativeClass_Wrap(t *testing.T) {
+	class, err := ReflectClass("HttpRequest", newTestRequest)
+	if !assert.NoError(t, err) {
+		return
+	}
+	request, _ := newTestRequest("http://host")
+
+	buf := bytes.NewBufferString("")
+	interpreter := NewInterpreter(buf)
+	interpreter.Globals().Define("request", scan.NewClassInstanceLoxValue(class.Wrap(request)))
+	assert.NoError(t, interpretSourceWith(t, interpreter, `request.setHeader("a", "b"); print request.url;`))
+	assert.Equal(t, "http://host\n", buf.String())
+	assert.Equal(t, "b", request.Header("a"))
+}
@@ -0,0 +1,366 @@
+package interpret
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"unicode"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	loxValueType = reflect.TypeOf((*scan.LoxValue)(nil))
+)
+
+// ReflectClass builds a native class from a Go constructor function such as
+// func(url string) *HttpRequest or func() (*HttpRequest, error).
+//
+// Exported methods of the constructed type become Lox methods and exported
+// fields of the struct it points to become properties, their names start with
+// a lower case letter in Lox: Header → header, URL → url. Arguments and results
+// are converted between Lox values and Go numbers, strings, bools, slices, maps,
+// *scan.LoxValue and interface{} (see scan.LoxValue.ToGo); a trailing error
+// result is reported as a runtime error.
+func ReflectClass(name string, constructor interface{}) (*NativeClass, error) {
+	ctor := reflect.ValueOf(constructor)
+	if ctor.Kind() != reflect.Func {
+		return nil, fmt.Errorf("constructor of %s must be a function, got %T", name, constructor)
+	}
+	ctorType := ctor.Type()
+	if ctorType.NumOut() == 0 || ctorType.NumOut() > 2 ||
+		(ctorType.NumOut() == 2 && ctorType.Out(1) != errorType) {
+		return nil, fmt.Errorf("constructor of %s must return a value and optionally an error", name)
+	}
+	instanceType := ctorType.Out(0)
+
+	class := NewNativeClass(name, reflectArity(ctorType, 0), func(args []*scan.LoxValue) (interface{}, error) {
+		result, err := callReflected(ctor, nil, args)
+		if err != nil {
+			return nil, err
+		}
+		return result.Interface(), nil
+	})
+	class.goType = instanceType
+
+	for idx := 0; idx < instanceType.NumMethod(); idx++ {
+		method := instanceType.Method(idx)
+		class.Method(loxName(method.Name), reflectArity(method.Type, 1), reflectMethod(class, method))
+	}
+
+	structType := instanceType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() == reflect.Struct {
+		for idx := 0; idx < structType.NumField(); idx++ {
+			field := structType.Field(idx)
+			if !field.IsExported() || field.Anonymous {
+				continue
+			}
+			getter, setter := reflectField(class, field, instanceType.Kind() == reflect.Pointer)
+			class.Property(loxName(field.Name), getter, setter)
+		}
+	}
+	return class, nil
+}
+
+// loxName lower cases the leading upper case run of a Go name, keeping the
+// start of the next word: HTTPClient → httpClient.
+func loxName(name string) string {
+	runes := []rune(name)
+	for idx := 0; idx < len(runes) && unicode.IsUpper(runes[idx]); idx++ {
+		if idx > 0 && idx+1 < len(runes) && unicode.IsLower(runes[idx+1]) {
+			break
+		}
+		runes[idx] = unicode.ToLower(runes[idx])
+	}
+	return string(runes)
+}
+
+// reflectArity is the Lox arity of fnType, skip is the number of leading
+// parameters filled in by the caller (the receiver of methods).
+func reflectArity(fnType reflect.Type, skip int) int {
+	if fnType.IsVariadic() {
+		return scan.VariadicArity
+	}
+	return fnType.NumIn() - skip
+}
+
+func reflectMethod(class *NativeClass, method reflect.Method) NativeMethod {
+	return func(self interface{}, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		result, err := callReflected(method.Func, []reflect.Value{reflect.ValueOf(self)}, args)
+		if err != nil {
+			return nil, err
+		}
+		return fromGoValue(class, result)
+	}
+}
+
+func reflectField(
+	class *NativeClass,
+	field reflect.StructField,
+	isPointer bool,
+) (func(self interface{}) (*scan.LoxValue, error), func(self interface{}, value *scan.LoxValue) error) {
+	structValue := func(self interface{}) reflect.Value {
+		value := reflect.ValueOf(self)
+		if isPointer {
+			return value.Elem()
+		}
+		return value
+	}
+	getter := func(self interface{}) (*scan.LoxValue, error) {
+		return fromGoValue(class, structValue(self).FieldByIndex(field.Index))
+	}
+	if !isPointer {
+		// fields of values held by copy can't be changed
+		return getter, nil
+	}
+	setter := func(self interface{}, value *scan.LoxValue) error {
+		goValue, err := toGoValue(value, field.Type)
+		if err != nil {
+			return err
+		}
+		structValue(self).FieldByIndex(field.Index).Set(goValue)
+		return nil
+	}
+	return getter, setter
+}
+
+// callReflected converts args to the parameter types of fn following the
+// leading values, calls it and splits off a trailing error result. The
+// returned value is invalid when fn has no other result.
+func callReflected(fn reflect.Value, leading []reflect.Value, args []*scan.LoxValue) (reflect.Value, error) {
+	fnType := fn.Type()
+	skip := len(leading)
+	fixed := fnType.NumIn() - skip
+	if fnType.IsVariadic() {
+		fixed -= 1
+		if err := CheckArgCount(args, fixed, -1); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	in := append(make([]reflect.Value, 0, skip+len(args)), leading...)
+	for idx, arg := range args {
+		var paramType reflect.Type
+		if idx >= fixed {
+			paramType = fnType.In(fnType.NumIn() - 1).Elem()
+		} else {
+			paramType = fnType.In(idx + skip)
+		}
+		value, err := toGoValue(arg, paramType)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("argument %d: %w", idx+1, err)
+		}
+		in = append(in, value)
+	}
+
+	out := fn.Call(in)
+	if len(out) > 0 && fnType.Out(len(out)-1) == errorType {
+		if errValue := out[len(out)-1]; !errValue.IsNil() {
+			return reflect.Value{}, errValue.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return reflect.Value{}, nil
+	}
+	return out[0], nil
+}
+
+// toGoValue converts a Lox value to a Go value of type t.
+func toGoValue(value *scan.LoxValue, t reflect.Type) (reflect.Value, error) {
+	if t == loxValueType {
+		return reflect.ValueOf(value), nil
+	}
+	if value.IsClassInstance() {
+		instance, _ := value.ClassInstance()
+		if native, ok := instance.(*NativeInstance); ok && native.value != nil {
+			goValue := reflect.ValueOf(native.value)
+			if goValue.Type().AssignableTo(t) {
+				return goValue, nil
+			}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if !value.IsNumber() {
+			return reflect.Value{}, fmt.Errorf("expected a number, got %s", value.TypeName())
+		}
+		number, _ := value.Number()
+		return reflect.ValueOf(number).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !value.IsNumber() {
+			return reflect.Value{}, fmt.Errorf("expected a number, got %s", value.TypeName())
+		}
+		number, _ := value.Number()
+		if number != math.Trunc(number) {
+			return reflect.Value{}, fmt.Errorf("expected an integer, got %v", number)
+		}
+		if !integerFits(number, t) {
+			return reflect.Value{}, fmt.Errorf("%v out of range for %s", number, t)
+		}
+		if t.Kind() >= reflect.Uint {
+			return reflect.ValueOf(uint64(number)).Convert(t), nil
+		}
+		return reflect.ValueOf(int64(number)).Convert(t), nil
+	case reflect.String:
+		if !value.IsString() {
+			return reflect.Value{}, fmt.Errorf("expected a string, got %s", value.TypeName())
+		}
+		return reflect.ValueOf(value.String()).Convert(t), nil
+	case reflect.Bool:
+		if !value.IsBoolean() {
+			return reflect.Value{}, fmt.Errorf("expected a bool, got %s", value.TypeName())
+		}
+		return reflect.ValueOf(value.Bool()).Convert(t), nil
+	case reflect.Slice:
+		if !value.IsList() {
+			return reflect.Value{}, fmt.Errorf("expected a list, got %s", value.TypeName())
+		}
+		list, _ := value.List()
+		slice := reflect.MakeSlice(t, 0, list.Len())
+		for _, element := range list.Elements() {
+			goElement, err := toGoValue(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice = reflect.Append(slice, goElement)
+		}
+		return slice, nil
+	case reflect.Map:
+		if !value.IsMap() {
+			return reflect.Value{}, fmt.Errorf("expected a map, got %s", value.TypeName())
+		}
+		loxMap, _ := value.Map()
+		goMap := reflect.MakeMapWithSize(t, loxMap.Len())
+		values := loxMap.Values()
+		for idx, key := range loxMap.Keys() {
+			goKey, err := toGoValue(key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			goValue, err := toGoValue(values[idx], t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			goMap.SetMapIndex(goKey, goValue)
+		}
+		return goMap, nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			goValue, err := value.ToGo()
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if goValue == nil {
+				return reflect.Zero(t), nil
+			}
+			return reflect.ValueOf(goValue), nil
+		}
+	case reflect.Pointer:
+		if value.IsNil() {
+			return reflect.Zero(t), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("can't convert %s to Go type %s", value.TypeName(), t)
+}
+
+// fromGoValue converts a Go value to a Lox value, values of the type class
+// was reflected from are wrapped as its instances.
+func fromGoValue(class *NativeClass, value reflect.Value) (*scan.LoxValue, error) {
+	if !value.IsValid() {
+		return scan.NewNilLoxValue(), nil
+	}
+	if value.Type() == loxValueType {
+		if value.IsNil() {
+			return scan.NewNilLoxValue(), nil
+		}
+		return value.Interface().(*scan.LoxValue), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return scan.NewFloatLoxValue(value.Float()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return scan.NewFloatLoxValue(float64(value.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return scan.NewFloatLoxValue(float64(value.Uint())), nil
+	case reflect.String:
+		return scan.NewStringLoxValue(value.String()), nil
+	case reflect.Bool:
+		return scan.NewBooleanLoxValue(value.Bool()), nil
+	case reflect.Interface:
+		if value.IsNil() {
+			return scan.NewNilLoxValue(), nil
+		}
+		return fromGoValue(class, value.Elem())
+	}
+
+	if class != nil && class.goType != nil && value.Type() == class.goType {
+		if value.Kind() == reflect.Pointer && value.IsNil() {
+			return scan.NewNilLoxValue(), nil
+		}
+		return scan.NewClassInstanceLoxValue(class.Wrap(value.Interface())), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return scan.NewNilLoxValue(), nil
+		}
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return scan.NewNilLoxValue(), nil
+		}
+		elements := make([]*scan.LoxValue, 0, value.Len())
+		for idx := 0; idx < value.Len(); idx++ {
+			element, err := fromGoValue(class, value.Index(idx))
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return scan.NewListLoxValue(scan.NewLoxList(elements)), nil
+	case reflect.Map:
+		if value.IsNil() {
+			return scan.NewNilLoxValue(), nil
+		}
+		// Go maps are unordered, sort the keys so scripts see a stable order
+		keys := value.MapKeys()
+		sort.Slice(keys, func(a, b int) bool {
+			return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+		})
+		loxMap := scan.NewLoxMap()
+		for _, goKey := range keys {
+			key, err := fromGoValue(class, goKey)
+			if err != nil {
+				return nil, err
+			}
+			element, err := fromGoValue(class, value.MapIndex(goKey))
+			if err != nil {
+				return nil, err
+			}
+			if err := loxMap.Set(key, element); err != nil {
+				return nil, err
+			}
+		}
+		return scan.NewMapLoxValue(loxMap), nil
+	}
+	return scan.FromGo(value.Interface())
+}
+
+// integerFits reports whether the integer number is in the range of the
+// integer type t, converting it otherwise would wrap around.
+func integerFits(number float64, t reflect.Type) bool {
+	bits := t.Bits()
+	if t.Kind() >= reflect.Uint {
+		return number >= 0 && number < math.Ldexp(1, bits)
+	}
+	return number >= -math.Ldexp(1, bits-1) && number < math.Ldexp(1, bits-1)
+}
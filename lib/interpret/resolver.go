@@ -22,28 +22,45 @@ const (
 	inSubClassType
 )
 
+// variable is a name declared in a resolver scope, slot is its index in the
+// environment the interpreter creates for the scope.
+type variable struct {
+	defined bool
+	slot    int
+}
+
 type Resolver struct {
-	scopes           []map[string]bool
+	scopes           []map[string]*variable
 	interpreter      *Interpreter
 	currentFuncType  functionType
 	currentClassType classType
+	// loopDepth counts loops enclosing the current statement inside the current function
+	loopDepth int
 }
 
 func NewResolver(interpreter *Interpreter) *Resolver {
 	return &Resolver{
 		interpreter:      interpreter,
-		scopes:           make([]map[string]bool, 0),
+		scopes:           make([]map[string]*variable, 0),
 		currentFuncType:  noneFunctionType,
 		currentClassType: noneClassType,
 	}
 }
 
+// Resolve keeps the top-level scope between calls, so a long-lived Resolver
+// can be fed a program piece by piece (e.g. line by line from a prompt).
 func (r *Resolver) Resolve(stmts []parse.Statement) error {
-	r.beginScope()
+	if len(r.scopes) == 0 {
+		r.beginScope()
+	}
 	if err := r.resolveStmts(stmts); err != nil {
+		// drop scopes left open by the failed statement
+		r.scopes = r.scopes[:1]
+		r.currentFuncType = noneFunctionType
+		r.currentClassType = noneClassType
+		r.loopDepth = 0
 		return err
 	}
-	r.endScope()
 	return nil
 }
 
@@ -67,7 +84,7 @@ func (r *Resolver) resolveExpr(expr parse.Expression) error {
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes = append(r.scopes, make(map[string]bool))
+	r.scopes = append(r.scopes, make(map[string]*variable))
 }
 
 func (r *Resolver) endScope() {
@@ -79,8 +96,8 @@ func (r *Resolver) endScope() {
 
 func (r *Resolver) resolveLocal(expr parse.Expression, name scan.Token) {
 	for i := len(r.scopes) - 1; i >= 0; i -= 1 {
-		if _, ok := r.scopes[i][name.Lexeme]; ok {
-			r.interpreter.resolve(expr, len(r.scopes)-1-i)
+		if variable, ok := r.scopes[i][name.Lexeme]; ok {
+			r.interpreter.resolve(expr, len(r.scopes)-1-i, variable.slot)
 			return
 		}
 	}
@@ -91,11 +108,16 @@ func (r *Resolver) declare(name scan.Token) error {
 		return nil
 	}
 	scope := r.scopes[len(r.scopes)-1]
-	if _, ok := scope[name.Lexeme]; ok {
-		return NewRuntimeError("already variable with this name in this scope", &name)
+	// top-level declarations behave like globals and may be redeclared
+	if variable, ok := scope[name.Lexeme]; ok {
+		if len(r.scopes) > 1 {
+			return NewResolveError("already variable with this name in this scope", &name)
+		}
+		variable.defined = false
+		return nil
 	}
 
-	scope[name.Lexeme] = false
+	scope[name.Lexeme] = &variable{slot: len(scope)}
 	return nil
 }
 
@@ -103,13 +125,12 @@ func (r *Resolver) define(name scan.Token) {
 	if len(r.scopes) == 0 {
 		return
 	}
-	scope := r.scopes[len(r.scopes)-1]
-	scope[name.Lexeme] = true
+	r.scopes[len(r.scopes)-1][name.Lexeme].defined = true
 }
 
 func (r *Resolver) resolveFunction(function *parse.StmtFunction, funcType functionType) error {
-	enclosingFuncType := r.currentFuncType
-	r.currentFuncType = funcType
+	enclosingFuncType, enclosingLoopDepth := r.currentFuncType, r.loopDepth
+	r.currentFuncType, r.loopDepth = funcType, 0
 
 	r.beginScope()
 	for _, param := range function.Params {
@@ -122,6 +143,6 @@ func (r *Resolver) resolveFunction(function *parse.StmtFunction, funcType functi
 		return err
 	}
 	r.endScope()
-	r.currentFuncType = enclosingFuncType
+	r.currentFuncType, r.loopDepth = enclosingFuncType, enclosingLoopDepth
 	return nil
 }
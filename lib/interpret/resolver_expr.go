@@ -26,8 +26,8 @@ func (r *Resolver) VisitUnaryExpr(expr *parse.UnaryExpression) (interface{}, err
 
 func (r *Resolver) VisitVariableExpr(expr *parse.VariableExpression) (interface{}, error) {
 	if len(r.scopes) > 0 {
-		if res, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && res == false {
-			return nil, NewRuntimeError(
+		if variable, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && !variable.defined {
+			return nil, NewResolveError(
 				"can't read local variable in its own initializer",
 				&expr.Name,
 			)
@@ -66,9 +66,9 @@ func (r *Resolver) VisitCallExpr(expr *parse.CallExpression) (interface{}, error
 
 func (r *Resolver) VisitSuperExpr(expr *parse.SuperExpression) (interface{}, error) {
 	if r.currentClassType == noneClassType {
-		return nil, NewRuntimeError("can't use 'super' outside of a class", &expr.Keyword)
+		return nil, NewResolveError("can't use 'super' outside of a class", &expr.Keyword)
 	} else if r.currentClassType != inSubClassType {
-		return nil, NewRuntimeError("can't use 'super' in a class with no superclass", &expr.Keyword)
+		return nil, NewResolveError("can't use 'super' in a class with no superclass", &expr.Keyword)
 	}
 	r.resolveLocal(expr, expr.Keyword)
 	return nil, nil
@@ -76,7 +76,7 @@ func (r *Resolver) VisitSuperExpr(expr *parse.SuperExpression) (interface{}, err
 
 func (r *Resolver) VisitThisExpr(expr *parse.ThisExpression) (interface{}, error) {
 	if r.currentClassType == noneClassType {
-		return nil, NewRuntimeError("can't use 'this' outside of a class", &expr.Keyword)
+		return nil, NewResolveError("can't use 'this' outside of a class", &expr.Keyword)
 	}
 	r.resolveLocal(expr, expr.Keyword)
 	return nil, nil
@@ -92,3 +92,41 @@ func (r *Resolver) VisitSetExpr(expr *parse.SetExpression) (interface{}, error)
 func (r *Resolver) VisitGetExpr(expr *parse.GetExpression) (interface{}, error) {
 	return nil, r.resolveExpr(expr.Object)
 }
+
+func (r *Resolver) VisitListExpr(expr *parse.ListExpression) (interface{}, error) {
+	for _, element := range expr.Elements {
+		if err := r.resolveExpr(element); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *parse.IndexExpression) (interface{}, error) {
+	if err := r.resolveExpr(expr.Object); err != nil {
+		return nil, err
+	}
+	return nil, r.resolveExpr(expr.Index)
+}
+
+func (r *Resolver) VisitIndexSetExpr(expr *parse.IndexSetExpression) (interface{}, error) {
+	if err := r.resolveExpr(expr.Value); err != nil {
+		return nil, err
+	}
+	if err := r.resolveExpr(expr.Object); err != nil {
+		return nil, err
+	}
+	return nil, r.resolveExpr(expr.Index)
+}
+
+func (r *Resolver) VisitMapExpr(expr *parse.MapExpression) (interface{}, error) {
+	for idx, key := range expr.Keys {
+		if err := r.resolveExpr(key); err != nil {
+			return nil, err
+		}
+		if err := r.resolveExpr(expr.Values[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
@@ -1,6 +1,10 @@
 package interpret
 
-import "github.com/hrumst/gox-lox/lib/parse"
+import (
+	"fmt"
+
+	"github.com/hrumst/gox-lox/lib/parse"
+)
 
 func (r *Resolver) VisitStmtExpression(stmt *parse.StmtExpression) (interface{}, error) {
 	return nil, r.resolveExpr(stmt.Expression)
@@ -49,10 +53,25 @@ func (r *Resolver) VisitStmtWhile(stmt *parse.StmtWhile) (interface{}, error) {
 	if err := r.resolveExpr(stmt.Condition); err != nil {
 		return nil, err
 	}
-	return nil, r.resolveStmt(stmt.Body)
+	r.loopDepth += 1
+	err := r.resolveStmt(stmt.Body)
+	r.loopDepth -= 1
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Increment != nil {
+		return nil, r.resolveExpr(stmt.Increment)
+	}
+	return nil, nil
 }
 
 func (r *Resolver) VisitStmtExecuteControl(stmt *parse.StmtExecuteControl) (interface{}, error) {
+	if r.loopDepth == 0 {
+		return nil, NewResolveError(
+			fmt.Sprintf("can't use '%s' outside of a loop", stmt.Control.Lexeme),
+			&stmt.Control,
+		)
+	}
 	return nil, nil
 }
 
@@ -66,11 +85,11 @@ func (r *Resolver) VisitStmtFunction(stmt *parse.StmtFunction) (interface{}, err
 
 func (r *Resolver) VisitStmtReturn(stmt *parse.StmtReturn) (interface{}, error) {
 	if r.currentFuncType == noneFunctionType {
-		return nil, NewRuntimeError("can't return from top-level code", &stmt.Keyword)
+		return nil, NewResolveError("can't return from top-level code", &stmt.Keyword)
 	}
 	if stmt.Value != nil {
 		if r.currentFuncType == inClassInitializerType {
-			return nil, NewRuntimeError("can't return a value from an initializer", &stmt.Keyword)
+			return nil, NewResolveError("can't return a value from an initializer", &stmt.Keyword)
 		}
 		return nil, r.resolveExpr(stmt.Value)
 	}
@@ -87,7 +106,7 @@ func (r *Resolver) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error) {
 	r.define(stmt.Name)
 
 	if stmt.SuperClass != nil && stmt.SuperClass.Name.Lexeme == stmt.Name.Lexeme {
-		return nil, NewRuntimeError("a class can't inherit from itself", &stmt.SuperClass.Name)
+		return nil, NewResolveError("a class can't inherit from itself", &stmt.SuperClass.Name)
 	}
 
 	if stmt.SuperClass != nil {
@@ -99,11 +118,11 @@ func (r *Resolver) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error) {
 
 	if stmt.SuperClass != nil {
 		r.beginScope()
-		r.scopes[len(r.scopes)-1]["super"] = true
+		r.scopes[len(r.scopes)-1]["super"] = &variable{defined: true}
 	}
 	r.beginScope()
 
-	r.scopes[len(r.scopes)-1]["this"] = true
+	r.scopes[len(r.scopes)-1]["this"] = &variable{defined: true}
 	for _, stmt := range stmt.Methods {
 		declarationType := inClassMethodType
 		stmtFunc := stmt.(*parse.StmtFunction)
@@ -123,3 +142,54 @@ func (r *Resolver) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error) {
 	r.currentClassType = enclosingClass
 	return nil, nil
 }
+
+func (r *Resolver) VisitStmtThrow(stmt *parse.StmtThrow) (interface{}, error) {
+	return nil, r.resolveExpr(stmt.Value)
+}
+
+func (r *Resolver) VisitStmtTry(stmt *parse.StmtTry) (interface{}, error) {
+	r.beginScope()
+	err := r.resolveStmts(stmt.TryBlock)
+	r.endScope()
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.CatchName != nil {
+		// the exception variable shares the scope with the catch body,
+		// the same way function parameters share it with the function body
+		r.beginScope()
+		err := r.declare(*stmt.CatchName)
+		if err == nil {
+			r.define(*stmt.CatchName)
+			err = r.resolveStmts(stmt.CatchBlock)
+		}
+		r.endScope()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.FinallyBlock != nil {
+		r.beginScope()
+		err := r.resolveStmts(stmt.FinallyBlock)
+		r.endScope()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitStmtImport(stmt *parse.StmtImport) (interface{}, error) {
+	if len(r.scopes) > 1 || r.currentFuncType != noneFunctionType {
+		return nil, NewResolveError("can't import outside of top-level code", &stmt.Keyword)
+	}
+	if stmt.Name != nil {
+		if err := r.declare(*stmt.Name); err != nil {
+			return nil, err
+		}
+		r.define(*stmt.Name)
+	}
+	return nil, nil
+}
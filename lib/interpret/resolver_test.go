@@ -2,6 +2,7 @@ package interpret
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/hrumst/gox-lox/lib/parse"
 	"github.com/hrumst/gox-lox/lib/scan"
 	"github.com/stretchr/testify/assert"
@@ -52,8 +53,8 @@ func TestResolver_Resolve(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		assert.Equal(t, interpreter.locals[testStmt1], 9)
-		assert.Equal(t, interpreter.locals[testStmt2], 0)
+		assert.Equal(t, interpreter.locals[testStmt1].depth, 9)
+		assert.Equal(t, interpreter.locals[testStmt2].depth, 0)
 	})
 
 	t.Run("returnError", func(t *testing.T) {
@@ -212,3 +213,35 @@ func TestResolver_Resolve(t *testing.T) {
 		assert.Errorf(t, err1, "can't use 'super' in a class with no superclass")
 	})
 }
+
+func TestResolver_ControlOutsideLoop(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`break;`, "can't use 'break' outside of a loop"},
+		{`if (true) continue;`, "can't use 'continue' outside of a loop"},
+		{`while (true) { fun f() { break; } }`, "can't use 'break' outside of a loop"},
+		{`fun f() { for (;;) { if (true) break; else continue; } } while (true) { { break; } }`, ""},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("resolver_control_outside_loop_test_case_%d", i),
+			func(t *testing.T) {
+				tokens, err := scan.NewScanner(tc.source).ScanTokens()
+				assert.NoError(t, err)
+				stmts, err := parse.NewParser(tokens).Parse()
+				assert.NoError(t, err)
+				err = NewResolver(NewInterpreter(bytes.NewBufferString(""))).Resolve(stmts)
+				if tc.expected == "" {
+					assert.NoError(t, err)
+					return
+				}
+				assert.ErrorContains(t, err, tc.expected)
+			},
+		)
+	}
+}
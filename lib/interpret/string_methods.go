@@ -0,0 +1,218 @@
+package interpret
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// String methods count in runes, not bytes, the way the scanner reads source.
+
+type stringMethod struct {
+	arity int
+	call  func(value string, args []*scan.LoxValue) (*scan.LoxValue, error)
+}
+
+var stringMethods = map[string]stringMethod{
+	"len": {0, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewFloatLoxValue(float64(utf8.RuneCountInString(value))), nil
+	}},
+	"upper": {0, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewStringLoxValue(strings.ToUpper(value)), nil
+	}},
+	"lower": {0, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewStringLoxValue(strings.ToLower(value)), nil
+	}},
+	"trim": {0, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		return scan.NewStringLoxValue(strings.TrimSpace(value)), nil
+	}},
+	// split(sep) returns a list of strings, an empty sep splits into characters
+	"split": {1, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		sep, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(value, sep)
+		elements := make([]*scan.LoxValue, len(parts))
+		for i, part := range parts {
+			elements[i] = scan.NewStringLoxValue(part)
+		}
+		return scan.NewListLoxValue(scan.NewLoxList(elements)), nil
+	}},
+	"contains": {1, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		substring, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewBooleanLoxValue(strings.Contains(value, substring)), nil
+	}},
+	"startsWith": {1, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		prefix, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewBooleanLoxValue(strings.HasPrefix(value, prefix)), nil
+	}},
+	// indexOf(substring) returns the index of the first occurrence or -1
+	"indexOf": {1, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		substring, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		position := strings.Index(value, substring)
+		if position < 0 {
+			return scan.NewFloatLoxValue(-1), nil
+		}
+		return scan.NewFloatLoxValue(float64(utf8.RuneCountInString(value[:position]))), nil
+	}},
+	// replace(old, new) replaces every occurrence of old
+	"replace": {2, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		old, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		replacement, err := StringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewStringLoxValue(strings.ReplaceAll(value, old, replacement)), nil
+	}},
+	// substring(start, end) has the bounds of list slice, without end or with
+	// a nil end it goes up to the end
+	"substring": {scan.VariadicArity, func(value string, args []*scan.LoxValue) (*scan.LoxValue, error) {
+		from, to, err := substringBounds(value, args)
+		if err != nil {
+			return nil, err
+		}
+		return scan.NewStringLoxValue(value[from:to]), nil
+	}},
+}
+
+// substringBounds returns the byte offsets of substring(start, end) of value.
+func substringBounds(value string, args []*scan.LoxValue) (int, int, error) {
+	if err := CheckArgCount(args, 1, 2); err != nil {
+		return 0, 0, err
+	}
+	start, err := IntArg(args, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	from, to := runeOffset(value, start), len(value)
+	if !OptionalArg(args, 1, scan.NewNilLoxValue()).IsNil() {
+		end, err := IntArg(args, 1)
+		if err != nil {
+			return 0, 0, err
+		}
+		to = runeOffset(value, end)
+	}
+	if from > to {
+		from = to
+	}
+	return from, to, nil
+}
+
+// runeOffset returns the byte offset of the rune at position, negative
+// positions count from the end. Positions outside of value are clamped to
+// its bounds. Only negative positions need the length of value, others walk
+// it up to the position.
+func runeOffset(value string, position int) int {
+	if position < 0 {
+		position += utf8.RuneCountInString(value)
+		if position < 0 {
+			return 0
+		}
+	}
+	offset := 0
+	for ; position > 0 && offset < len(value); position -= 1 {
+		_, size := utf8.DecodeRuneInString(value[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// stringMethodSizes estimate the allocation of the methods returning a new
+// string or list from the receiver and the arguments. The estimate counts
+// towards the memory limit before the method builds its result, so a large
+// result can't be built past the limit. Invalid arguments estimate 0, the
+// method reports them.
+var stringMethodSizes = map[string]func(value string, args []*scan.LoxValue) int{
+	"upper": func(value string, args []*scan.LoxValue) int {
+		return StringAllocation(value)
+	},
+	"lower": func(value string, args []*scan.LoxValue) int {
+		return StringAllocation(value)
+	},
+	"trim": func(value string, args []*scan.LoxValue) int {
+		return StringAllocation(strings.TrimSpace(value))
+	},
+	"split": func(value string, args []*scan.LoxValue) int {
+		sep, err := StringArg(args, 0)
+		if err != nil {
+			return 0
+		}
+		parts := strings.Count(value, sep) + 1
+		if sep == "" {
+			parts = utf8.RuneCountInString(value)
+		}
+		return ListAllocation(parts) + parts*stringSize + len(value)
+	},
+	"replace": func(value string, args []*scan.LoxValue) int {
+		old, err := StringArg(args, 0)
+		if err != nil {
+			return 0
+		}
+		replacement, err := StringArg(args, 1)
+		if err != nil {
+			return 0
+		}
+		return StringAllocation(value) + strings.Count(value, old)*(len(replacement)-len(old))
+	},
+	"substring": func(value string, args []*scan.LoxValue) int {
+		from, to, err := substringBounds(value, args)
+		if err != nil {
+			return 0
+		}
+		return stringSize + to - from
+	},
+}
+
+// StringMethod returns the built-in method name bound to value, memory the
+// method allocates is accounted in limits.
+func StringMethod(value string, name scan.Token, limits *Limits) (*scan.LoxValue, error) {
+	method, ok := stringMethods[name.Lexeme]
+	if !ok {
+		return nil, NewRuntimeError(fmt.Sprintf("undefined string method '%s'", name.Lexeme), &name)
+	}
+	return scan.NewCallableLoxValue(
+		NewNativeFunction(name.Lexeme, method.arity, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			if size, ok := stringMethodSizes[name.Lexeme]; ok {
+				if err := limits.Allocate(size(value, args), &name); err != nil {
+					return nil, err
+				}
+			}
+			return method.call(value, args)
+		}),
+	), nil
+}
+
+// StringIndex returns the character at index of value as a string, negative
+// indices count from the end like they do for lists.
+func StringIndex(value string, index float64) (*scan.LoxValue, error) {
+	if index != math.Trunc(index) {
+		return nil, fmt.Errorf("string index must be an integer, got %v", index)
+	}
+	position := int(index)
+	if position < 0 {
+		position += utf8.RuneCountInString(value)
+	}
+	if position >= 0 {
+		if offset := runeOffset(value, position); offset < len(value) {
+			char, _ := utf8.DecodeRuneInString(value[offset:])
+			return scan.NewStringLoxValue(string(char)), nil
+		}
+	}
+	return nil, fmt.Errorf("string index %v out of range", index)
+}
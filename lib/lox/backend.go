@@ -0,0 +1,150 @@
+package lox
+
+import (
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/hrumst/gox-lox/lib/vm"
+)
+
+// Backend selects how a Runtime executes parsed programs. Both backends share
+// the scanner and the parser and behave the same, the bytecode VM is faster.
+type Backend int
+
+const (
+	// TreeWalker resolves the syntax tree and interprets it directly.
+	TreeWalker Backend = iota
+	// VM compiles the syntax tree into bytecode for a stack based virtual machine.
+	VM
+)
+
+func (b Backend) String() string {
+	if b == VM {
+		return "vm"
+	}
+	return "tree"
+}
+
+// WithBackend sets the backend, TreeWalker by default.
+func WithBackend(backend Backend) Option {
+	return func(r *Runtime) {
+		r.backendKind = backend
+	}
+}
+
+// backend is what a Runtime needs from an execution engine.
+type backend interface {
+	// execute runs stmts read from the script name, empty when unknown
+	execute(name string, stmts []parse.Statement) error
+	evaluate(expr parse.Expression) (*scan.LoxValue, error)
+	get(name string) (*scan.LoxValue, bool)
+	define(name string, value *scan.LoxValue)
+	defineNative(name string, arity int, fn interpret.NativeFunc)
+	defineClass(class *interpret.NativeClass)
+}
+
+type treeWalker struct {
+	interpreter *interpret.Interpreter
+	resolver    *interpret.Resolver
+}
+
+func newTreeWalker(r *Runtime) *treeWalker {
+	options := []interpret.InterpreterOption{interpret.WithMaxCallDepth(r.limits.maxCallDepth)}
+	if r.limits.maxSteps > 0 {
+		options = append(options, interpret.WithMaxSteps(r.limits.maxSteps))
+	}
+	if r.limits.maxMemory > 0 {
+		options = append(options, interpret.WithMaxMemory(r.limits.maxMemory))
+	}
+	if r.limits.timeout > 0 {
+		options = append(options, interpret.WithTimeout(r.limits.timeout))
+	}
+	if r.limits.ctx != nil {
+		options = append(options, interpret.WithContext(r.limits.ctx))
+	}
+	options = append(options, interpret.WithModuleLoader(r.sourceRecorder()))
+	interpreter := interpret.NewInterpreter(r.stdout, options...)
+	return &treeWalker{
+		interpreter: interpreter,
+		resolver:    interpret.NewResolver(interpreter),
+	}
+}
+
+func (t *treeWalker) execute(name string, stmts []parse.Statement) error {
+	if err := t.resolver.Resolve(stmts); err != nil {
+		return err
+	}
+	return t.interpreter.InterpretScript(name, stmts)
+}
+
+func (t *treeWalker) evaluate(expr parse.Expression) (*scan.LoxValue, error) {
+	if err := t.resolver.Resolve([]parse.Statement{parse.NewStmtExpression(expr)}); err != nil {
+		return nil, err
+	}
+	return t.interpreter.Eval(expr)
+}
+
+func (t *treeWalker) get(name string) (*scan.LoxValue, bool) {
+	if value, ok := t.interpreter.Environment().Lookup(name); ok {
+		return value, true
+	}
+	return t.interpreter.Globals().Lookup(name)
+}
+
+func (t *treeWalker) define(name string, value *scan.LoxValue) {
+	t.interpreter.Globals().Define(name, value)
+}
+
+func (t *treeWalker) defineNative(name string, arity int, fn interpret.NativeFunc) {
+	t.interpreter.DefineNative(name, arity, fn)
+}
+
+func (t *treeWalker) defineClass(class *interpret.NativeClass) {
+	t.interpreter.DefineClass(class)
+}
+
+type bytecodeVM struct {
+	vm *vm.VM
+}
+
+func newBytecodeVM(r *Runtime) *bytecodeVM {
+	options := []vm.Option{vm.WithMaxCallDepth(r.limits.maxCallDepth)}
+	if r.limits.maxSteps > 0 {
+		options = append(options, vm.WithMaxSteps(r.limits.maxSteps))
+	}
+	if r.limits.maxMemory > 0 {
+		options = append(options, vm.WithMaxMemory(r.limits.maxMemory))
+	}
+	if r.limits.timeout > 0 {
+		options = append(options, vm.WithTimeout(r.limits.timeout))
+	}
+	if r.limits.ctx != nil {
+		options = append(options, vm.WithContext(r.limits.ctx))
+	}
+	options = append(options, vm.WithModuleLoader(r.sourceRecorder()))
+	return &bytecodeVM{vm: vm.New(r.stdout, options...)}
+}
+
+func (b *bytecodeVM) execute(name string, stmts []parse.Statement) error {
+	return b.vm.InterpretScript(name, stmts)
+}
+
+func (b *bytecodeVM) evaluate(expr parse.Expression) (*scan.LoxValue, error) {
+	return b.vm.Eval(expr)
+}
+
+func (b *bytecodeVM) get(name string) (*scan.LoxValue, bool) {
+	return b.vm.Get(name)
+}
+
+func (b *bytecodeVM) define(name string, value *scan.LoxValue) {
+	b.vm.Define(name, value)
+}
+
+func (b *bytecodeVM) defineNative(name string, arity int, fn interpret.NativeFunc) {
+	b.vm.DefineNative(name, arity, fn)
+}
+
+func (b *bytecodeVM) defineClass(class *interpret.NativeClass) {
+	b.vm.DefineClass(class)
+}
@@ -0,0 +1,510 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBackends runs the same programs on every backend, they must print the
+// same output and fail with the same error.
+func TestBackends(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+		// err is the first line of the error, empty when the run succeeds
+		err string
+	}
+
+	tcs := []testCase{
+		{
+			source:   `print 1 + 2 * 3; print (1 + 2) * 3; print -4 / 2; print "a" + "b"; print "n" + 1;`,
+			expected: "7\n9\n-2\nab\nn1\n",
+		}, {
+			source:   `print 1 == 1; print 1 != 1; print "a" == "a"; print nil == nil; print 1 == "1"; print [] == [];`,
+			expected: "true\nfalse\ntrue\ntrue\nfalse\nfalse\n",
+		}, {
+			source:   `print 1 < 2; print 2 <= 1; print 3 > 2; print 3 >= 4; print !true; print !nil;`,
+			expected: "true\nfalse\ntrue\nfalse\nfalse\ntrue\n",
+		}, {
+			source:   `print nil or "default"; print 0 or 2; print 1 and 2; print false and 1; print 2 or x;`,
+			expected: "default\n2\n2\nfalse\n2\n",
+		}, {
+			source: `var a = "global";
+{
+  var a = "outer";
+  {
+    var a = "inner";
+    print a;
+  }
+  print a;
+}
+print a;
+a = "assigned";
+print a;`,
+			expected: "inner\nouter\nglobal\nassigned\n",
+		}, {
+			source:   `var i = 0; while (i < 3) { print i; i = i + 1; }`,
+			expected: "0\n1\n2\n",
+		}, {
+			source: `for (var i = 0; i < 10; i = i + 1) {
+  if (i == 1) continue;
+  if (i == 4) break;
+  var double = i * 2;
+  print double;
+}`,
+			expected: "0\n4\n6\n",
+		}, {
+			source: `var fns = [];
+for (var i = 0; i < 3; i = i + 1) {
+  var j = i;
+  fun show() { print j; }
+  fns.push(show);
+}
+fns[0](); fns[2]();`,
+			expected: "0\n2\n",
+		}, {
+			source: `fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); }
+print fib(15);`,
+			expected: "610\n",
+		}, {
+			source: `fun makeCounter() {
+  var count = 0;
+  fun increment() { count = count + 1; return count; }
+  return increment;
+}
+var first = makeCounter();
+var second = makeCounter();
+first(); first();
+print first();
+print second();`,
+			expected: "3\n1\n",
+		}, {
+			source: `fun outer() {
+  var x = "before";
+  fun middle() {
+    fun inner() { x = "after"; }
+    inner();
+  }
+  middle();
+  return x;
+}
+print outer();`,
+			expected: "after\n",
+		}, {
+			source: `fun isEven(n) { if (n == 0) return true; return isOdd(n - 1); }
+fun isOdd(n) { if (n == 0) return false; return isEven(n - 1); }
+print isEven(10);`,
+			expected: "true\n",
+		}, {
+			source: `fun find(xs, x) {
+  var i = 0;
+  while (true) {
+    if (xs[i] == x) return i;
+    i = i + 1;
+  }
+}
+print find([5, 6, 7], 7);`,
+			expected: "2\n",
+		}, {
+			source: `class Counter {
+  init(start) { this.count = start; }
+  add(n) { this.count = this.count + n; return this; }
+}
+var counter = Counter(1).add(2).add(3);
+print counter.count;
+print counter;
+print Counter;
+var add = counter.add;
+add(4);
+print counter.count;`,
+			expected: "6\n[class instance] Counter\n[class] Counter\n10\n",
+		}, {
+			source: `class Animal {
+  init(name) { this.name = name; }
+  speak() { return this.name + " makes a sound"; }
+}
+class Dog < Animal {
+  init(name) { super.init(name); this.tricks = 0; }
+  speak() { return super.speak() + ", woof"; }
+  describe() { var speak = super.speak; return speak(); }
+}
+var dog = Dog("rex");
+print dog.speak();
+print dog.describe();
+print dog.tricks;`,
+			expected: "rex makes a sound, woof\nrex makes a sound\n0\n",
+		}, {
+			source: `class A {
+  init() { this.callback = nil; }
+  method() { return "method"; }
+}
+var a = A();
+fun field() { return "field"; }
+a.callback = field;
+print a.callback();
+a.method = field;
+print a.method();
+print a.init() == a;`,
+			expected: "field\nfield\ntrue\n",
+		}, {
+			source: `class Node {
+  init(value) {
+    this.value = value;
+    fun get() { return this.value; }
+    this.get = get;
+  }
+}
+var node = Node(42);
+print node.get();`,
+			expected: "42\n",
+		}, {
+			source: `var xs = [1, "two", [3]];
+xs.push(4);
+xs[0] = xs[0] + 10;
+print xs;
+print xs.len();
+print xs.pop();
+print xs.slice(1, nil);
+var m = {"a": 1, 2: "b"};
+m["c"] = 3;
+print m;
+print m.keys();
+print m.has("a");
+print len(m);`,
+			expected: "[11, \"two\", [3], 4]\n4\n4\n[\"two\", [3]]\n{\"a\": 1, 2: \"b\", \"c\": 3}\n[\"a\", 2, \"c\"]\ntrue\n3\n",
+		}, {
+			source: `var s = "  Grüße, Welt ";
+var t = s.trim();
+print t.len();
+print t[2] + t[-1];
+print t.upper();
+print t.split(", ");
+print t.indexOf("W");
+print t.substring(0, 5).replace("ü", "ue");
+print t.contains("ß") and t.startsWith("Gr");
+var up = "abc".upper;
+print up();`,
+			expected: "11\nüt\nGRÜßE, WELT\n[\"Grüße\", \"Welt\"]\n7\nGrueße\ntrue\nABC\n",
+		}, {
+			source:   `try { print 1 / 0; } catch (e) { print "caught: " + e; }`,
+			expected: "caught: evaluate expression error: zero division error\n",
+		}, {
+			source:   `try { throw "boom"; print "unreachable"; } catch (e) { print e; } finally { print "finally"; }`,
+			expected: "boom\nfinally\n",
+		}, {
+			source: `class A {}
+try { A().missing; } catch (e) { print e; }
+try { [].pop(); } catch (e) { print e; }`,
+			expected: "undefined property 'missing'\nnative function error: pop from empty list\n",
+		}, {
+			source: `fun inner() { throw "deep"; }
+fun outer() { inner(); print "unreachable"; }
+try { outer(); } catch (e) { print e; }
+print "after";`,
+			expected: "deep\nafter\n",
+		}, {
+			source: `fun f() {
+  try { return "try"; } finally { print "finally"; }
+}
+print f();`,
+			expected: "finally\ntry\n",
+		}, {
+			source: `fun f() {
+  try { throw "x"; } finally { return "finally"; }
+}
+print f();`,
+			expected: "finally\n",
+		}, {
+			source: `for (var i = 0; i < 5; i = i + 1) {
+  try { if (i == 2) break; throw i; } catch (e) { print e; } finally { print "f"; }
+}`,
+			expected: "0\nf\n1\nf\nf\n",
+		}, {
+			source: `for (var i = 0; i < 3; i = i + 1) {
+  try { if (i == 1) continue; print i; } finally { print "f" + i; }
+}`,
+			expected: "0\nf0\nf1\n2\nf2\n",
+		}, {
+			source:   `try { try { throw 1; } finally { print "inner"; } } catch (e) { print e + 1; }`,
+			expected: "inner\n2\n",
+		}, {
+			source:   `try { throw 1; } catch (e) { try { throw e + 1; } catch (e) { print e; } print e; }`,
+			expected: "2\n1\n",
+		}, {
+			source: `try {
+  try { throw "first"; } catch (e) { var local = e + "!"; throw local; } finally { print "cleanup"; }
+} catch (e) { print e; }`,
+			expected: "cleanup\nfirst!\n",
+		}, {
+			source: `fun f() { throw [1, "a"]; }
+try { f(); } catch (e) { throw e; } finally { print "finally"; }`,
+			expected: "finally\n",
+			err:      `uncaught exception: [1, "a"]`,
+		}, {
+			source:   `fun down(n) { if (n == 0) return 0; return 1 + down(n - 1); } print down(3000);`,
+			expected: "3000\n",
+		}, {
+			source:   `fun forever() { forever(); } try { forever(); } catch (e) { print e; } print "alive";`,
+			expected: "stack overflow\nalive\n",
+		}, {
+			source:   `print "start"; print nil + 1;`,
+			expected: "start\n",
+			err:      "evaluate expression error: nil is not a number",
+		},
+		{source: `print -"a";`, err: "evaluate expression error: string is not a number"},
+		{source: `print missing;`, err: "undefined variable"},
+		{source: `missing = 1;`, err: "undefined variable"},
+		{source: `"text"();`, err: "can only call functions or classes: string is not a function"},
+		{source: `fun f(a) {} f();`, err: "expected 1 arguments but got 0"},
+		{source: `class A { init(a, b) {} } A(1);`, err: "expected 2 arguments but got 1"},
+		{source: `class A { m() {} } A().m(1);`, err: "expected 0 arguments but got 1"},
+		{source: `var x = 1; x.field = 2;`, err: "only instances have fields"},
+		{source: `var x = 1; print x.field;`, err: "only instances have properties"},
+		{source: `var x = 1; x.method();`, err: "only instances have properties"},
+		{source: `class A {} A().method();`, err: "undefined property 'method'"},
+		{source: `var NotClass = 1; class A < NotClass {}`, err: "superclass must be a class. error: float is not a function"},
+		{source: `fun f() {} class A < f {}`, err: "superclass must be a class declared in Lox, got [function] f"},
+		{source: `var xs = [1]; print xs[1];`, err: "invalid list index: list index 1 out of range"},
+		{source: `var s = 1; print s[0];`, err: "only lists, maps and strings can be indexed: float is not a list"},
+		{source: `print "ab"[2];`, err: "invalid string index: string index 2 out of range"},
+		{source: `print "ab"["0"];`, err: "invalid string index: string is not a number"},
+		{source: `var s = "ab"; s[0] = "c";`, err: "only lists and maps support index assignment: string is not a list"},
+		{source: `"ab".reverse();`, err: "undefined string method 'reverse'"},
+		{source: `"ab".split(1);`, err: "native function error: argument 1 must be a string, got float"},
+		{source: `var s = 1; s[0] = 1;`, err: "only lists and maps support index assignment: float is not a list"},
+		{source: `var m = {"a": 1}; print m["b"];`, err: "undefined map key b"},
+		{source: `var m = {[]: 1};`, err: "invalid map key: list can't be a map key"},
+		{source: `[].sort();`, err: "undefined list method 'sort'"},
+		{source: `var m = {}; m.clear();`, err: "undefined map method 'clear'"},
+		{source: `throw "boom";`, err: "uncaught exception: boom"},
+		{source: `return 1;`, err: "can't return from top-level code"},
+		{source: `break;`, err: "can't use 'break' outside of a loop"},
+		{source: `fun f() { while (true) { fun g() { continue; } } }`, err: "can't use 'continue' outside of a loop"},
+		{source: `{ var a = 1; var a = 2; }`, err: "already variable with this name in this scope"},
+		{source: `{ var a = a; }`, err: "can't read local variable in its own initializer"},
+		{source: `print this;`, err: "can't use 'this' outside of a class"},
+		{source: `class A { init() { return 1; } }`, err: "can't return a value from an initializer"},
+		{source: `class A < A {}`, err: "a class can't inherit from itself"},
+		{source: `class A { m() { super.m(); } }`, err: "can't use 'super' in a class with no superclass"},
+		{source: `fun f() { super.m(); }`, err: "can't use 'super' outside of a class"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("backends_test_case_%d", i),
+			func(t *testing.T) {
+				for _, backend := range backends {
+					stdout := bytes.NewBufferString("")
+					err := New(WithBackend(backend), WithStdout(stdout)).RunString(tc.source)
+					assert.Equal(t, tc.expected, stdout.String(), backend.String())
+					if tc.err == "" {
+						assert.NoError(t, err, backend.String())
+					} else if assert.Error(t, err, backend.String()) {
+						assert.Equal(t, tc.err, strings.SplitN(err.Error(), "\n", 2)[0], backend.String())
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestBackends_ErrorTrace(t *testing.T) {
+	source := `class Point {
+  init(x) { this.x = x; }
+  norm() { return this.x / 0; }
+}
+fun measure(p) {
+  return p.norm();
+}
+print "start";
+measure(Point(1));`
+
+	for _, backend := range backends {
+		err := New(WithBackend(backend), WithStdout(bytes.NewBufferString(""))).RunString(source)
+		assert.EqualError(
+			t,
+			err,
+			"evaluate expression error: zero division error\n"+
+				"at <string>:3:26, token: /\n"+
+				"stack trace:\n"+
+				"  [<string>:3:26] in norm()\n"+
+				"  [<string>:6:17] in measure()\n"+
+				"  [<string>:9:17] in script",
+			backend.String(),
+		)
+	}
+}
+
+func TestBackends_NativeCallbacks(t *testing.T) {
+	for _, backend := range backends {
+		stdout := bytes.NewBufferString("")
+		runtime := New(WithBackend(backend), WithStdout(stdout), WithMaxCallDepth(20))
+		runtime.DefineNative("apply", 2, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+			fn, err := interpret.CallableArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return fn.Call(args[1:])
+		})
+
+		assert.NoError(t, runtime.RunString(`
+class Box { init(v) { this.v = v; } get() { return this.v; } }
+fun twice(n) { return apply(fun_double, apply(fun_double, n)); }
+fun fun_double(n) { return n * 2; }
+print twice(5);
+print apply(Box, 7).v;
+fun recurse(n) { return apply(recurse, n + 1); }
+try { recurse(0); } catch (e) { print e; }
+fun fun_fail(x) { throw "failed"; }
+try { apply(fun_fail, nil); } catch (e) { print "caught " + e; }
+print "done";`), backend.String())
+		assert.Equal(t, "20\n7\nstack overflow\ncaught failed\ndone\n", stdout.String(), backend.String())
+	}
+}
+
+func TestBackends_Limits(t *testing.T) {
+	type testCase struct {
+		options  []Option
+		source   string
+		expected error
+	}
+
+	tcs := []testCase{
+		{[]Option{WithMaxSteps(100)}, `while (true) {}`, interpret.ErrStepLimit},
+		{[]Option{WithMaxSteps(100)}, `fun f() { f(); } f();`, interpret.ErrStepLimit},
+		{[]Option{WithMaxSteps(100)}, `try { while (true) {} } catch (e) { print e; }`, interpret.ErrStepLimit},
+		{[]Option{WithTimeout(10 * time.Millisecond)}, `while (true) {}`, interpret.ErrTimeout},
+		{[]Option{WithMaxMemory(64 * 1024)}, `var s = "x"; while (true) { s = s + s; }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxMemory(64 * 1024)}, `var xs = []; while (true) { xs.push(1); }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxMemory(64 * 1024)}, `var m = {}; var i = 0; while (true) { m[i] = i; i = i + 1; }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxMemory(64 * 1024)}, `class A {} var as = []; while (true) { as.push(A()); }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxMemory(64 * 1024)}, `class A {} var a = A(); var i = 0; while (true) { a.f = i; i = i + 1; var b = A(); b.f = i; }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxMemory(64 * 1024)}, `fun f() { var a = [1, 2, 3]; } while (true) { f(); }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxMemory(64 * 1024)}, `var s = "some text"; while (true) { s.upper(); }`, interpret.ErrMemoryLimit},
+		{[]Option{WithMaxSteps(10000), WithMaxMemory(64 * 1024)}, `var i = 0; while (i < 10) { i = i + 1; }`, nil},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("backends_limits_test_case_%d", i),
+			func(t *testing.T) {
+				for _, backend := range backends {
+					stdout := bytes.NewBufferString("")
+					options := append([]Option{WithBackend(backend), WithStdout(stdout)}, tc.options...)
+					err := New(options...).RunString(tc.source)
+					if tc.expected == nil {
+						assert.NoError(t, err, backend.String())
+						continue
+					}
+					assert.ErrorIs(t, err, tc.expected, backend.String())
+					var limitErr *interpret.LimitError
+					assert.ErrorAs(t, err, &limitErr, backend.String())
+					assert.Empty(t, stdout.String(), backend.String())
+				}
+			},
+		)
+	}
+}
+
+func TestBackends_LimitsNotCatchable(t *testing.T) {
+	type testCase struct {
+		// options are created for every run, a context times out once
+		options func() ([]Option, context.CancelFunc)
+		// loop runs until the limit runs out
+		loop     string
+		expected error
+	}
+
+	noCancel := func() {}
+	tcs := []testCase{
+		{
+			func() ([]Option, context.CancelFunc) { return []Option{WithMaxSteps(1000)}, noCancel },
+			`while (true) {}`,
+			interpret.ErrStepLimit,
+		}, {
+			func() ([]Option, context.CancelFunc) { return []Option{WithTimeout(10 * time.Millisecond)}, noCancel },
+			`while (true) {}`,
+			interpret.ErrTimeout,
+		}, {
+			func() ([]Option, context.CancelFunc) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				return []Option{WithContext(ctx)}, cancel
+			},
+			`while (true) {}`,
+			context.DeadlineExceeded,
+		}, {
+			func() ([]Option, context.CancelFunc) { return []Option{WithMaxMemory(64 * 1024)}, noCancel },
+			`var s = "x"; while (true) { s = s + s; }`,
+			interpret.ErrMemoryLimit,
+		}, {
+			func() ([]Option, context.CancelFunc) { return []Option{WithMaxMemory(64 * 1024)}, noCancel },
+			`var xs = []; while (true) { xs.push("x" + xs.len()); }`,
+			interpret.ErrMemoryLimit,
+		},
+	}
+	// the limit runs out inside of an operand, an argument or a callback
+	sources := []string{
+		`fun f() { %s } try { var x = "a" + f(); } catch (e) { print e; }`,
+		`fun f() { %s } try { var x = -f(); } catch (e) { print e; }`,
+		`fun f() { %s } try { print [f()]; } catch (e) { print e; }`,
+		`fun f() { %s } try { call(f); } catch (e) { print e; }`,
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("backends_limits_not_catchable_test_case_%d", i),
+			func(t *testing.T) {
+				for _, backend := range backends {
+					for _, source := range sources {
+						source := fmt.Sprintf(source, tc.loop)
+						stdout := bytes.NewBufferString("")
+						limits, cancel := tc.options()
+						options := append([]Option{WithBackend(backend), WithStdout(stdout)}, limits...)
+						runtime := New(options...)
+						runtime.DefineNative("call", 1, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+							fn, err := interpret.CallableArg(args, 0)
+							if err != nil {
+								return nil, err
+							}
+							return fn.Call(nil)
+						})
+						err := runtime.RunString(source)
+						cancel()
+						assert.ErrorIs(t, err, tc.expected, backend.String(), source)
+						var limitErr *interpret.LimitError
+						assert.ErrorAs(t, err, &limitErr, backend.String(), source)
+						assert.Empty(t, stdout.String(), backend.String(), source)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestBackends_StackOverflow(t *testing.T) {
+	for _, backend := range backends {
+		stdout := bytes.NewBufferString("")
+		err := New(WithBackend(backend), WithStdout(stdout), WithMaxCallDepth(10)).RunString(`
+fun down(n) { if (n == 0) return 0; return 1 + down(n - 1); }
+print down(9);
+class A { init(n) { if (n > 0) A(n - 1); } }
+try { A(20); } catch (e) { print "class " + e; }
+down(10);`)
+		assert.Equal(t, "9\nclass stack overflow\n", stdout.String(), backend.String())
+		if assert.Error(t, err, backend.String()) {
+			runtimeErr := err.(*interpret.RuntimeError)
+			assert.Equal(t, "stack overflow", runtimeErr.Message(), backend.String())
+			assert.Len(t, runtimeErr.Trace(), 12, backend.String())
+			assert.Equal(t, "[<string>:2:5] in down()", runtimeErr.Trace()[0].String(), backend.String())
+			assert.Equal(t, "[<string>:6:8] in script", runtimeErr.Trace()[11].String(), backend.String())
+		}
+	}
+}
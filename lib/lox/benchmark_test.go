@@ -0,0 +1,54 @@
+package lox
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkProgram runs a program of testdata/bench through the whole
+// pipeline (scanning, parsing, resolving or compiling, executing) on every
+// backend, each iteration in a fresh Runtime.
+func benchmarkProgram(b *testing.B, name string) {
+	source, err := os.ReadFile(filepath.Join("testdata", "bench", name))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, backend := range backends {
+		b.Run(backend.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n += 1 {
+				runtime := New(WithBackend(backend), WithStdout(io.Discard), WithStderr(io.Discard))
+				if err := runtime.RunSource(name, string(source)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkProgram(b, "fib.lox")
+}
+
+func BenchmarkBinaryTrees(b *testing.B) {
+	benchmarkProgram(b, "binary_trees.lox")
+}
+
+func BenchmarkMethodCall(b *testing.B) {
+	benchmarkProgram(b, "method_call.lox")
+}
+
+func BenchmarkStringEquality(b *testing.B) {
+	benchmarkProgram(b, "string_equality.lox")
+}
+
+func BenchmarkInstantiation(b *testing.B) {
+	benchmarkProgram(b, "instantiation.lox")
+}
+
+func BenchmarkZoo(b *testing.B) {
+	benchmarkProgram(b, "zoo.lox")
+}
@@ -0,0 +1,117 @@
+package lox
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/diagnostics"
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceDir holds .lox scripts annotated with their expected outcome,
+// in the style of the Crafting Interpreters test suite:
+//
+//	print 1 + 2; // expect: 3
+//	print nil + 1; // expect runtime error: <message>
+//	var a = ; // expect error: <message>
+//
+// "expect:" lines give the stdout in order. A script may expect at most one
+// error: "expect runtime error:" for an error raised while running, "expect
+// error:" for a scan, parse or resolve error. Either is reported at the line
+// of the annotation. Scripts import their helpers from modules directories,
+// which are not run themselves.
+const conformanceDir = "testdata/conformance"
+
+const (
+	expectOutput       = "// expect: "
+	expectRuntimeError = "// expect runtime error: "
+	expectError        = "// expect error: "
+)
+
+type expectation struct {
+	output []string
+	// errorLine is 0 when the script must run without errors
+	errorLine    int
+	errorMessage string
+	runtimeError bool
+}
+
+func parseExpectation(source string) expectation {
+	var expected expectation
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for line := 1; scanner.Scan(); line += 1 {
+		text := scanner.Text()
+		if index := strings.Index(text, expectOutput); index >= 0 {
+			expected.output = append(expected.output, text[index+len(expectOutput):])
+		} else if index := strings.Index(text, expectRuntimeError); index >= 0 {
+			expected.runtimeError = true
+			expected.errorMessage = text[index+len(expectRuntimeError):]
+			expected.errorLine = line
+		} else if index := strings.Index(text, expectError); index >= 0 {
+			expected.errorMessage = text[index+len(expectError):]
+			expected.errorLine = line
+		}
+	}
+	return expected
+}
+
+func checkExpectation(t *testing.T, expected expectation, stdout string, err error) {
+	output := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if stdout == "" {
+		output = nil
+	}
+	assert.Equal(t, expected.output, output, "stdout")
+
+	if expected.errorLine == 0 {
+		assert.NoError(t, err)
+		return
+	}
+	if !assert.Error(t, err) {
+		return
+	}
+	diagnostic := diagnostics.FromError(err)[0]
+	if expected.runtimeError {
+		assert.Equal(t, diagnostics.RuntimeKind, diagnostic.Kind, err.Error())
+	} else {
+		// the stage is not part of the annotation, any static error matches
+		staticKinds := []diagnostics.Kind{diagnostics.ScanKind, diagnostics.ParseKind, diagnostics.ResolveKind}
+		assert.Contains(t, staticKinds, diagnostic.Kind, err.Error())
+	}
+	assert.Equal(t, expected.errorMessage, diagnostic.Message)
+	assert.Equal(t, expected.errorLine, diagnostic.Position.Line, "error line")
+}
+
+func TestConformance(t *testing.T) {
+	err := filepath.WalkDir(conformanceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && entry.Name() == "modules" {
+			return fs.SkipDir
+		}
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".lox" {
+			return err
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		expected := parseExpectation(string(source))
+		name, _ := filepath.Rel(conformanceDir, path)
+
+		t.Run(name, func(t *testing.T) {
+			for _, backend := range backends {
+				t.Run(backend.String(), func(t *testing.T) {
+					stdout := bytes.NewBufferString("")
+					runtime := New(WithBackend(backend), WithStdout(stdout), WithStderr(bytes.NewBufferString("")))
+					err := runtime.RunSource(path, string(source))
+					checkExpectation(t, expected, stdout.String(), err)
+				})
+			}
+		})
+		return nil
+	})
+	assert.NoError(t, err)
+}
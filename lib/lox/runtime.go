@@ -0,0 +1,273 @@
+// Package lox is the embedding API: a Runtime runs Lox sources through the
+// whole scan → parse → resolve → interpret pipeline, or compiles them for
+// the bytecode VM, and keeps its globals between runs.
+package lox
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/diagnostics"
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+const (
+	stringSourceName = "<string>"
+	evalSourceName   = "<eval>"
+)
+
+type Option func(r *Runtime)
+
+// WithStdout sets the writer print statements write to, os.Stdout by default.
+func WithStdout(writer io.Writer) Option {
+	return func(r *Runtime) {
+		r.stdout = writer
+	}
+}
+
+// WithStderr sets the writer ReportError writes to, os.Stderr by default.
+func WithStderr(writer io.Writer) Option {
+	return func(r *Runtime) {
+		r.stderr = writer
+	}
+}
+
+// WithColor enables ANSI colors in reports written by ReportError.
+func WithColor(color bool) Option {
+	return func(r *Runtime) {
+		r.color = color
+	}
+}
+
+// WithMaxSteps limits the number of steps each run may execute: statements
+// of the tree-walking interpreter, instructions of the VM.
+func WithMaxSteps(steps int) Option {
+	return func(r *Runtime) {
+		r.limits.maxSteps = steps
+	}
+}
+
+// WithMaxCallDepth limits how deep function calls may nest, see interpret.WithMaxCallDepth.
+func WithMaxCallDepth(depth int) Option {
+	return func(r *Runtime) {
+		r.limits.maxCallDepth = depth
+	}
+}
+
+// WithMaxMemory limits the approximate bytes each run may allocate, see interpret.WithMaxMemory.
+func WithMaxMemory(bytes int64) Option {
+	return func(r *Runtime) {
+		r.limits.maxMemory = bytes
+	}
+}
+
+// WithTimeout limits the wall-clock time of each run.
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Runtime) {
+		r.limits.timeout = timeout
+	}
+}
+
+// WithContext stops execution once ctx is done.
+func WithContext(ctx context.Context) Option {
+	return func(r *Runtime) {
+		r.limits.ctx = ctx
+	}
+}
+
+// WithModulePath sets the directories searched for imported modules after
+// the directory of the importing script.
+func WithModulePath(dirs ...string) Option {
+	return WithModuleLoader(interpret.NewFileLoader(dirs...))
+}
+
+// WithModuleLoader sets where imported modules come from, e.g. an
+// interpret.NewFSLoader over an embed.FS. By default they are files found
+// by interpret.NewFileLoader().
+func WithModuleLoader(loader interpret.ModuleLoader) Option {
+	return func(r *Runtime) {
+		r.moduleLoader = loader
+	}
+}
+
+// limits are the execution limits of the backend, zero values mean no limit.
+type limits struct {
+	maxSteps     int
+	maxCallDepth int
+	maxMemory    int64
+	timeout      time.Duration
+	ctx          context.Context
+}
+
+// Runtime is a Lox session. Variables, functions and classes declared by one
+// run are visible to the following runs and to Eval.
+type Runtime struct {
+	stdout      io.Writer
+	stderr      io.Writer
+	color       bool
+	backendKind Backend
+	backend     backend
+	// limits are applied to the backend on every Reset
+	limits       limits
+	moduleLoader interpret.ModuleLoader
+	// sources keeps every source run so errors can be reported with excerpts
+	sources map[string]string
+}
+
+func New(options ...Option) *Runtime {
+	runtime := &Runtime{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		limits: limits{maxCallDepth: interpret.DefaultMaxCallDepth},
+	}
+	for _, option := range options {
+		option(runtime)
+	}
+	runtime.Reset()
+	return runtime
+}
+
+// Reset drops all state of the session: declared globals, natives and sources.
+func (r *Runtime) Reset() {
+	if r.backendKind == VM {
+		r.backend = newBytecodeVM(r)
+	} else {
+		r.backend = newTreeWalker(r)
+	}
+	r.sources = make(map[string]string)
+}
+
+// Interpreter returns the interpreter of the session, nil unless the backend is TreeWalker.
+func (r *Runtime) Interpreter() *interpret.Interpreter {
+	if walker, ok := r.backend.(*treeWalker); ok {
+		return walker.interpreter
+	}
+	return nil
+}
+
+// Backend returns the backend the session runs on.
+func (r *Runtime) Backend() Backend {
+	return r.backendKind
+}
+
+// Stdout returns the writer print statements write to.
+func (r *Runtime) Stdout() io.Writer {
+	return r.stdout
+}
+
+// Stderr returns the writer errors are reported to.
+func (r *Runtime) Stderr() io.Writer {
+	return r.stderr
+}
+
+// RunString runs source, errors refer to it as <string>.
+func (r *Runtime) RunString(source string) error {
+	return r.RunSource(stringSourceName, source)
+}
+
+// RunFile reads and runs the script at path.
+func (r *Runtime) RunFile(path string) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.RunSource(path, string(source))
+}
+
+// RunSource runs source, name is used as file name in error positions.
+func (r *Runtime) RunSource(name, source string) error {
+	r.sources[name] = source
+	tokens, err := scan.NewFileScanner(name, source).ScanTokens()
+	if err != nil {
+		return err
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		return err
+	}
+	return r.backend.execute(name, stmts)
+}
+
+// Execute resolves and runs already parsed statements on the backend.
+func (r *Runtime) Execute(stmts []parse.Statement) error {
+	return r.backend.execute("", stmts)
+}
+
+// Eval evaluates a single expression, e.g. "fib(10) + 1", and returns its value.
+func (r *Runtime) Eval(expr string) (*scan.LoxValue, error) {
+	r.sources[evalSourceName] = expr
+	tokens, err := scan.NewFileScanner(evalSourceName, expr).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parse.NewParser(tokens).ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return r.Evaluate(parsed)
+}
+
+// Evaluate resolves and evaluates an already parsed expression.
+func (r *Runtime) Evaluate(expr parse.Expression) (*scan.LoxValue, error) {
+	return r.backend.evaluate(expr)
+}
+
+// Get returns the value of a variable declared at the top level of a run
+// or defined by the host, ok is false when there is no such variable.
+func (r *Runtime) Get(name string) (value *scan.LoxValue, ok bool) {
+	return r.backend.get(name)
+}
+
+// Define sets a global visible to every following run.
+func (r *Runtime) Define(name string, value *scan.LoxValue) {
+	r.backend.define(name, value)
+}
+
+// DefineNative exposes a Go function to scripts, see interpret.Interpreter.DefineNative.
+func (r *Runtime) DefineNative(name string, arity int, fn interpret.NativeFunc) {
+	r.backend.defineNative(name, arity, fn)
+}
+
+// DefineClass exposes a native class to scripts.
+func (r *Runtime) DefineClass(class *interpret.NativeClass) {
+	r.backend.defineClass(class)
+}
+
+// recordingLoader keeps the source of every module it loads in the sources
+// of the runtime, so errors raised by modules are reported with excerpts.
+type recordingLoader struct {
+	interpret.ModuleLoader
+	runtime *Runtime
+}
+
+func (l recordingLoader) Load(path string) (string, error) {
+	source, err := l.ModuleLoader.Load(path)
+	if err == nil {
+		l.runtime.sources[path] = source
+	}
+	return source, err
+}
+
+// sourceRecorder returns the module loader of the backends: the loader set
+// by WithModuleLoader, NewFileLoader by default, recording module sources.
+func (r *Runtime) sourceRecorder() interpret.ModuleLoader {
+	loader := r.moduleLoader
+	if loader == nil {
+		loader = interpret.NewFileLoader()
+	}
+	return recordingLoader{ModuleLoader: loader, runtime: r}
+}
+
+// ReportError writes err to the stderr writer, with source excerpts for
+// errors raised by sources run in this session.
+func (r *Runtime) ReportError(err error) {
+	renderer := diagnostics.NewRenderer(r.color)
+	for name, source := range r.sources {
+		renderer.AddSource(name, source)
+	}
+	renderer.RenderError(r.stderr, err)
+}
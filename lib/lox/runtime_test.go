@@ -0,0 +1,289 @@
+package lox
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/modules
+var embeddedModules embed.FS
+
+// backends are the backends every runtime test runs on.
+var backends = []Backend{TreeWalker, VM}
+
+func TestRuntime_PersistentGlobals(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			stdout := bytes.NewBufferString("")
+			runtime := New(WithBackend(backend), WithStdout(stdout))
+
+			assert.NoError(t, runtime.RunString(`var count = 1; fun inc(n) { count = count + n; return count; }`))
+			assert.NoError(t, runtime.RunString(`inc(2); print count;`))
+			assert.Equal(t, "3\n", stdout.String())
+
+			value, err := runtime.Eval(`inc(10) * 2`)
+			assert.NoError(t, err)
+			assert.Equal(t, "26", value.String())
+
+			count, ok := runtime.Get("count")
+			assert.True(t, ok)
+			assert.Equal(t, "13", count.String())
+			_, ok = runtime.Get("missing")
+			assert.False(t, ok)
+
+			runtime.Reset()
+			_, err = runtime.Eval(`count`)
+			assert.EqualError(t, err, "undefined variable\nat <eval>:1:1, token: count")
+		})
+	}
+}
+
+func TestRuntime_Host(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			stdout := bytes.NewBufferString("")
+			runtime := New(WithBackend(backend), WithStdout(stdout))
+			runtime.Define("version", scan.NewStringLoxValue("1.0"))
+			runtime.DefineNative("twice", 1, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+				n, err := interpret.NumberArg(args, 0)
+				if err != nil {
+					return nil, err
+				}
+				return scan.NewFloatLoxValue(2 * n), nil
+			})
+
+			assert.NoError(t, runtime.RunString(`print version; print twice(21);`))
+			assert.Equal(t, "1.0\n42\n", stdout.String())
+		})
+	}
+}
+
+func TestRuntime_RunFile(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script.lox")
+			assert.NoError(t, os.WriteFile(path, []byte("var greeting = \"hi\";\nprint greeting;\n"), 0o644))
+
+			stdout := bytes.NewBufferString("")
+			runtime := New(WithBackend(backend), WithStdout(stdout))
+			assert.NoError(t, runtime.RunFile(path))
+			assert.Equal(t, "hi\n", stdout.String())
+
+			err := runtime.RunFile(filepath.Join(t.TempDir(), "missing.lox"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestRuntime_ReportError(t *testing.T) {
+	type testCase struct {
+		run      func(runtime *Runtime) error
+		expected string
+	}
+
+	tcs := []testCase{
+		{
+			run: func(runtime *Runtime) error {
+				return runtime.RunString("print 1 +;")
+			},
+			expected: "error[E0203]: parse error: unexpected token type\n" +
+				" --> <string>:1:10\n" +
+				"  |\n" +
+				"1 | print 1 +;\n" +
+				"  |          ^\n" +
+				"  = hint: an expression is expected here\n",
+		},
+		{
+			run: func(runtime *Runtime) error {
+				_, err := runtime.Eval("1 / 0")
+				return err
+			},
+			expected: "error[E0402]: runtime error: evaluate expression error: zero division error\n" +
+				" --> <eval>:1:3\n" +
+				"  |\n" +
+				"1 | 1 / 0\n" +
+				"  |   ^\n" +
+				"  = hint: check the divisor before dividing\n",
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("runtime_report_error_test_case_%d", i),
+			func(t *testing.T) {
+				stderr := bytes.NewBufferString("")
+				for _, backend := range backends {
+					stderr.Reset()
+					runtime := New(WithBackend(backend), WithStdout(bytes.NewBufferString("")), WithStderr(stderr))
+					err := tc.run(runtime)
+					if assert.Error(t, err, backend.String()) {
+						runtime.ReportError(err)
+						assert.Equal(t, tc.expected, stderr.String(), backend.String())
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestRuntime_GoValues(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			runtime := New(WithBackend(backend), WithStdout(bytes.NewBufferString("")))
+			assert.NoError(t, runtime.RunString(`
+		class Point { init(x, y) { this.x = x; this.y = y; this.tags = ["a"]; } }
+		fun add(a, b) { return a + b; }
+		fun fail() { return 1 / 0; }
+		`))
+
+			point, err := runtime.Eval(`Point(1, 2)`)
+			assert.NoError(t, err)
+			fields, err := point.ToGo()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"x": 1., "y": 2., "tags": []interface{}{"a"}}, fields)
+
+			add, _ := runtime.Get("add")
+			fn, err := add.ToGo()
+			assert.NoError(t, err)
+			sum, err := fn.(scan.GoFunc)(40, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, 42., sum)
+			_, err = fn.(scan.GoFunc)(1)
+			assert.EqualError(t, err, "expected 2 arguments but got 1")
+
+			failing, _ := runtime.Get("fail")
+			fn, _ = failing.ToGo()
+			_, err = fn.(scan.GoFunc)()
+			assert.ErrorContains(t, err, "zero division error")
+
+			point, err = runtime.Eval(`Point`)
+			assert.NoError(t, err)
+			fn, _ = point.ToGo()
+			created, err := fn.(scan.GoFunc)(3, 4)
+			assert.NoError(t, err)
+			assert.Equal(t, 3., created.(map[string]interface{})["x"])
+
+			data, err := scan.FromGo(map[string]interface{}{"items": []int{1, 2, 3}})
+			assert.NoError(t, err)
+			runtime.Define("data", data)
+			total, err := runtime.Eval(`data["items"][0] + data["items"][2]`)
+			assert.NoError(t, err)
+			assert.Equal(t, "4", total.String())
+		})
+	}
+}
+
+func TestRuntime_Limits(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			stderr := bytes.NewBufferString("")
+			runtime := New(WithBackend(backend), WithStdout(bytes.NewBufferString("")), WithStderr(stderr), WithMaxSteps(100), WithTimeout(time.Second))
+			assert.NoError(t, runtime.RunString(`fun spin() { while (true) {} }`))
+
+			err := runtime.RunString(`spin();`)
+			assert.ErrorIs(t, err, interpret.ErrStepLimit)
+			runtime.ReportError(err)
+			assert.Equal(t, "error[E0501]: execution limit: step limit exceeded\n"+
+				" = hint: the script ran more statements than allowed, look for endless loops\n", stderr.String())
+
+			_, err = runtime.Eval(`spin()`)
+			assert.ErrorIs(t, err, interpret.ErrStepLimit)
+
+			// limits survive Reset
+			runtime.Reset()
+			assert.ErrorIs(t, runtime.RunString(`while (true) {}`), interpret.ErrStepLimit)
+		})
+	}
+}
+
+func TestRuntime_Import(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "greet.lox"), []byte(`fun greet(name) { return "hi " + name; }`), 0o644))
+
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			stdout := bytes.NewBufferString("")
+			runtime := New(WithBackend(backend), WithStdout(stdout), WithModulePath(dir))
+			assert.NoError(t, runtime.RunString(`import g from "greet.lox"; print g.greet("lox");`))
+			assert.Equal(t, "hi lox\n", stdout.String())
+
+			greeting, err := runtime.Eval(`g.greet("go")`)
+			assert.NoError(t, err)
+			assert.Equal(t, "hi go", greeting.String())
+
+			assert.NoError(t, runtime.RunString(`import "greet.lox"; print greet("vm");`))
+			assert.Equal(t, "hi lox\nhi vm\n", stdout.String())
+		})
+	}
+}
+
+func TestRuntime_ImportCycleThroughScript(t *testing.T) {
+	// the working directory is reported without symlinks
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.lox"), []byte("print \"main runs\";\nvar name = \"main\";\nimport \"a.lox\";\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.lox"), []byte("print \"a runs\";\nimport \"main.lox\";\n"), 0o644))
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the script is run by a relative path, modules are resolved to absolute ones
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	mainPath, aPath := filepath.Join(dir, "main.lox"), filepath.Join(dir, "a.lox")
+
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			stdout, stderr := bytes.NewBufferString(""), bytes.NewBufferString("")
+			runtime := New(WithBackend(backend), WithStdout(stdout), WithStderr(stderr))
+			err := runtime.RunFile("main.lox")
+			assert.Equal(t, "main runs\na runs\n", stdout.String())
+			assert.ErrorContains(t, err, fmt.Sprintf("import cycle: %s -> %s -> %s", mainPath, aPath, mainPath))
+
+			runtime.ReportError(err)
+			assert.Contains(t, stderr.String(), fmt.Sprintf(" --> %s:2:8\n  |\n2 | import \"main.lox\";\n", aPath))
+			assert.Contains(t, stderr.String(), fmt.Sprintf("[%s:2:8] in script\n      [main.lox:3:8] in script\n", aPath))
+
+			// once it has run, importing the script gives its globals
+			stdout.Reset()
+			assert.NoError(t, runtime.RunString(fmt.Sprintf("import m from %q; print m.name;", mainPath)))
+			assert.Equal(t, "main\n", stdout.String())
+		})
+	}
+}
+
+func TestRuntime_ImportWithLoader(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.String(), func(t *testing.T) {
+			stdout := bytes.NewBufferString("")
+			runtime := New(
+				WithBackend(backend),
+				WithStdout(stdout),
+				WithModuleLoader(interpret.NewFSLoader(embeddedModules, "testdata/modules")),
+			)
+			assert.NoError(t, runtime.RunString(`import d from "geo/distance.lox"; print d.dist(d.Point(1, 2), d.Point(4, 0));`))
+			assert.Equal(t, "5\n", stdout.String())
+
+			stdout.Reset()
+			runtime = New(WithBackend(backend), WithStdout(stdout), WithModuleLoader(interpret.NewMapLoader(map[string]string{
+				"config.lox": `var name = "memory";`,
+			})))
+			assert.NoError(t, runtime.RunString(`import "config.lox"; print name;`))
+			assert.Equal(t, "memory\n", stdout.String())
+			assert.ErrorContains(t, runtime.RunString(`import "missing.lox";`), `module "missing.lox" not found`)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package loxtest runs unit tests written in Lox: test_* functions of
+// *_test.lox files, each in a fresh Runtime with assertion natives defined.
+package loxtest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/lox"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// DefineAssertions exposes the assertion natives to scripts run by runtime:
+//
+//	assert(condition, message?)
+//	assertEqual(expected, actual, message?)
+//	fail(message?)
+//
+// assert fails unless condition is true, assertEqual unless the values are
+// equal (lists and maps are compared by contents) and fail always does. A
+// failed assertion is a runtime error raised at the call, so it stops the
+// test unless caught.
+func DefineAssertions(runtime *lox.Runtime) {
+	runtime.DefineNative("assert", scan.VariadicArity, assertNative)
+	runtime.DefineNative("assertEqual", scan.VariadicArity, assertEqualNative)
+	runtime.DefineNative("fail", scan.VariadicArity, failNative)
+}
+
+func assertNative(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	if err := interpret.CheckArgCount(args, 1, 2); err != nil {
+		return nil, err
+	}
+	if args[0].Bool() {
+		return scan.NewNilLoxValue(), nil
+	}
+	return nil, assertionError(args, 1, "assertion failed")
+}
+
+func assertEqualNative(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	if err := interpret.CheckArgCount(args, 2, 3); err != nil {
+		return nil, err
+	}
+	expected, actual := args[0], args[1]
+	if equal(expected, actual) {
+		return scan.NewNilLoxValue(), nil
+	}
+	return nil, assertionError(
+		args, 2,
+		fmt.Sprintf("expected %s but got %s", formatValue(expected), formatValue(actual)),
+	)
+}
+
+func failNative(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	if err := interpret.CheckArgCount(args, 0, 1); err != nil {
+		return nil, err
+	}
+	return nil, assertionError(args, 0, "test failed")
+}
+
+// assertionError prefixes reason with the optional message argument at index.
+func assertionError(args []*scan.LoxValue, index int, reason string) error {
+	message := interpret.OptionalArg(args, index, scan.NewNilLoxValue())
+	if message.IsNil() {
+		return errors.New(reason)
+	}
+	if index == 0 {
+		return errors.New(message.String())
+	}
+	return fmt.Errorf("%s: %s", message.String(), reason)
+}
+
+// collectionPair is a pair of lists or maps being compared by equal.
+type collectionPair struct {
+	a, b interface{}
+}
+
+// equal compares lists and maps element by element, other values the way
+// == does.
+func equal(a, b *scan.LoxValue) bool {
+	return equalValues(a, b, make(map[collectionPair]bool))
+}
+
+// equalValues compares collections by contents. A pair of collections
+// already being compared is taken as equal, so values referring to
+// themselves compare without recursing forever.
+func equalValues(a, b *scan.LoxValue, comparing map[collectionPair]bool) bool {
+	switch {
+	case a.IsList() && b.IsList():
+		aList, _ := a.List()
+		bList, _ := b.List()
+		pair := collectionPair{aList, bList}
+		if aList == bList || comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+
+		aElements, bElements := aList.Elements(), bList.Elements()
+		if len(aElements) != len(bElements) {
+			return false
+		}
+		for i := range aElements {
+			if !equalValues(aElements[i], bElements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case a.IsMap() && b.IsMap():
+		aMap, _ := a.Map()
+		bMap, _ := b.Map()
+		pair := collectionPair{aMap, bMap}
+		if aMap == bMap || comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+
+		keys := aMap.Keys()
+		if len(keys) != len(bMap.Keys()) {
+			return false
+		}
+		for _, key := range keys {
+			aValue, _, _ := aMap.Get(key)
+			bValue, ok, _ := bMap.Get(key)
+			if !ok || !equalValues(aValue, bValue, comparing) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equal(b)
+}
+
+// formatValue quotes strings, so "1" and 1 are told apart in failures.
+func formatValue(value *scan.LoxValue) string {
+	if value.IsString() {
+		return fmt.Sprintf("%q", value.String())
+	}
+	return value.String()
+}
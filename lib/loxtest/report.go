@@ -0,0 +1,181 @@
+package loxtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/diagnostics"
+)
+
+// Report collects the results of a test run.
+type Report struct {
+	Results []Result
+}
+
+// Passed returns the number of passed tests.
+func (r *Report) Passed() int {
+	passed := 0
+	for _, result := range r.Results {
+		if result.Passed() {
+			passed += 1
+		}
+	}
+	return passed
+}
+
+// Failed returns the number of failed tests, files that couldn't be loaded included.
+func (r *Report) Failed() int {
+	return len(r.Results) - r.Passed()
+}
+
+// Ok reports whether every test passed.
+func (r *Report) Ok() bool {
+	return r.Failed() == 0
+}
+
+// WriteText writes a go test like report: failed tests with the location
+// of the error and the output of the test, then the summary. Verbose adds
+// passed tests and their output.
+func (r *Report) WriteText(w io.Writer, verbose bool) error {
+	var sb strings.Builder
+	for _, result := range r.Results {
+		if result.Passed() && !verbose {
+			continue
+		}
+		status := "PASS"
+		if !result.Passed() {
+			status = "FAIL"
+		}
+		name := result.File
+		if result.Name != "" {
+			name = fmt.Sprintf("%s %s", result.File, result.Name)
+		}
+		fmt.Fprintf(&sb, "--- %s: %s (%.2fs)\n", status, name, result.Duration.Seconds())
+		if !result.Passed() {
+			for _, line := range failureLines(result.Err) {
+				fmt.Fprintf(&sb, "    %s\n", line)
+			}
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(result.Output, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(&sb, "    | %s\n", line)
+			}
+		}
+	}
+
+	status := "ok"
+	if !r.Ok() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&sb, "%s: %d passed, %d failed, %d total\n", status, r.Passed(), r.Failed(), len(r.Results))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// failureLines describes the error: the position and the message, then the
+// Lox stack trace of a runtime error.
+func failureLines(err error) []string {
+	lines := make([]string, 0)
+	for _, diagnostic := range diagnostics.FromError(err) {
+		if diagnostic.HasPosition {
+			lines = append(lines, fmt.Sprintf("%s: %s", diagnostic.Position, diagnostic.Message))
+		} else {
+			lines = append(lines, diagnostic.Message)
+		}
+		for _, frame := range diagnostic.Trace {
+			lines = append(lines, "  "+frame)
+		}
+	}
+	return lines
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+	duration time.Duration
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, one test suite per file.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{}
+	var total time.Duration
+	for _, result := range r.Results {
+		if len(suites.Suites) == 0 || suites.Suites[len(suites.Suites)-1].Name != result.File {
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: result.File})
+		}
+		suite := &suites.Suites[len(suites.Suites)-1]
+
+		name := result.Name
+		if name == "" {
+			name = "load"
+		}
+		testCase := junitTestCase{
+			Name:      name,
+			Classname: result.File,
+			Time:      junitTime(result.Duration),
+			SystemOut: result.Output,
+		}
+		if !result.Passed() {
+			lines := failureLines(result.Err)
+			testCase.Failure = &junitFailure{
+				Message: lines[0],
+				Type:    string(diagnostics.FromError(result.Err)[0].Kind),
+				Text:    strings.Join(lines, "\n"),
+			}
+			suite.Failures += 1
+			suites.Failures += 1
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests += 1
+		suite.duration += result.Duration
+		suites.Tests += 1
+		total += result.Duration
+	}
+	suites.Time = junitTime(total)
+	for i := range suites.Suites {
+		suites.Suites[i].Time = junitTime(suites.Suites[i].duration)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
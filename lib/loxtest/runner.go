@@ -0,0 +1,164 @@
+package loxtest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/lox"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+const (
+	// FileSuffix marks the files holding tests.
+	FileSuffix = "_test.lox"
+	// FuncPrefix marks the top-level functions that are tests.
+	FuncPrefix = "test_"
+)
+
+// ErrNoTests fails a test file without tests, e.g. one whose test
+// functions all take parameters, so it isn't skipped unnoticed.
+var ErrNoTests = errors.New("no tests: tests are top-level test_* functions without parameters")
+
+// Result is the outcome of a single test. A file that can't be read or
+// parsed, or that has no tests, gives one Result with an empty Name.
+type Result struct {
+	File     string
+	Name     string
+	Duration time.Duration
+	// Output is what the test printed
+	Output string
+	Err    error
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Runner runs test files, each test in its own Runtime.
+type Runner struct {
+	options []lox.Option
+}
+
+// NewRunner creates a Runner, options (backend, limits) apply to the Runtime
+// of every test.
+func NewRunner(options ...lox.Option) *Runner {
+	return &Runner{options: options}
+}
+
+// FindFiles returns the test files among paths: directories are searched
+// recursively for *_test.lox files, files are taken as they are.
+func FindFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && strings.HasSuffix(path, FileSuffix) {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Run runs the tests of every file found in paths.
+func (r *Runner) Run(paths []string) (*Report, error) {
+	files, err := FindFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	for _, file := range files {
+		report.Results = append(report.Results, r.RunFile(file)...)
+	}
+	return report, nil
+}
+
+// RunFile runs the test_* functions of the file in declaration order.
+func (r *Runner) RunFile(path string) []Result {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return []Result{{File: path, Err: err}}
+	}
+	return r.RunSource(path, string(source))
+}
+
+// RunSource runs the test_* functions declared at the top level of source.
+// The top-level code runs again before every test, in a fresh Runtime. A
+// source without tests fails with the error of its top-level code or with
+// ErrNoTests.
+func (r *Runner) RunSource(name, source string) []Result {
+	tests, err := findTests(name, source)
+	if err != nil {
+		return []Result{{File: name, Err: err}}
+	}
+	if len(tests) == 0 {
+		// the top-level code still runs once, its error says more than ErrNoTests
+		result := r.runTest(name, source, "")
+		if result.Err == nil {
+			result.Err = ErrNoTests
+		}
+		return []Result{result}
+	}
+	results := make([]Result, 0, len(tests))
+	for _, test := range tests {
+		results = append(results, r.runTest(name, source, test))
+	}
+	return results
+}
+
+func (r *Runner) runTest(file, source, test string) Result {
+	stdout := bytes.NewBufferString("")
+	options := append(r.options[:len(r.options):len(r.options)], lox.WithStdout(stdout), lox.WithStderr(io.Discard))
+	runtime := lox.New(options...)
+	DefineAssertions(runtime)
+
+	start := time.Now()
+	err := runtime.RunSource(file, source)
+	if err == nil && test != "" {
+		_, err = runtime.Eval(test + "()")
+	}
+	return Result{
+		File:     file,
+		Name:     test,
+		Duration: time.Since(start),
+		Output:   stdout.String(),
+		Err:      err,
+	}
+}
+
+func findTests(name, source string) ([]string, error) {
+	tokens, err := scan.NewFileScanner(name, source).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		return nil, err
+	}
+	tests := make([]string, 0)
+	for _, stmt := range stmts {
+		function, ok := stmt.(*parse.StmtFunction)
+		if ok && strings.HasPrefix(function.Name.Lexeme, FuncPrefix) && len(function.Params) == 0 {
+			tests = append(tests, function.Name.Lexeme)
+		}
+	}
+	return tests, nil
+}
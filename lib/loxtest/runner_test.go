@@ -0,0 +1,173 @@
+package loxtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/lox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_Run(t *testing.T) {
+	for _, backend := range []lox.Backend{lox.TreeWalker, lox.VM} {
+		t.Run(backend.String(), func(t *testing.T) {
+			report, err := NewRunner(lox.WithBackend(backend)).Run([]string{"testdata"})
+			assert.NoError(t, err)
+
+			type outcome struct {
+				file, name, output, err string
+			}
+			outcomes := make([]outcome, 0)
+			for _, result := range report.Results {
+				errText := ""
+				if result.Err != nil {
+					errText = failureLines(result.Err)[0]
+				}
+				outcomes = append(outcomes, outcome{filepath.Base(result.File), result.Name, result.Output, errText})
+			}
+			assert.Equal(t, []outcome{
+				{"math_test.lox", "test_add", "", ""},
+				{"math_test.lox", "test_wrong", "computing\n", "testdata/math_test.lox:13:34: native function error: add: expected 5 but got 4"},
+				{"math_test.lox", "test_divide", "", "testdata/math_test.lox:17:12: evaluate expression error: zero division error"},
+				{"state_test.lox", "test_first", "", ""},
+				{"state_test.lox", "test_second", "", ""},
+				{"state_test.lox", "test_caught", "", ""},
+			}, outcomes)
+			assert.Equal(t, 4, report.Passed())
+			assert.Equal(t, 2, report.Failed())
+			assert.False(t, report.Ok())
+		})
+	}
+}
+
+func TestRunner_RunSource(t *testing.T) {
+	runner := NewRunner(lox.WithMaxSteps(1000))
+
+	results := runner.RunSource("broken_test.lox", "fun test_broken( {")
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "", results[0].Name)
+		assert.Equal(t, "broken_test.lox:1:18: expect parameter name", failureLines(results[0].Err)[0])
+	}
+
+	results = runner.RunSource("limits_test.lox", "fun test_spin() { while (true) {} } fun test_args(a) {}")
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "test_spin", results[0].Name)
+		assert.True(t, errors.Is(results[0].Err, interpret.ErrStepLimit))
+	}
+
+	results = runner.RunSource("top_level_test.lox", "print 1 / 0; fun test_never() {}")
+	if assert.Len(t, results, 1) {
+		assert.ErrorContains(t, results[0].Err, "zero division error")
+	}
+
+	// files without tests fail instead of being skipped
+	results = runner.RunSource("params_test.lox", `print "loaded"; fun test_args(a) {}`)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "", results[0].Name)
+		assert.Equal(t, "loaded\n", results[0].Output)
+		assert.True(t, errors.Is(results[0].Err, ErrNoTests))
+	}
+	results = runner.RunSource("runtime_error_test.lox", "var x = missing;")
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "runtime_error_test.lox:1:9: undefined variable", failureLines(results[0].Err)[0])
+	}
+	results = runner.RunSource("resolve_error_test.lox", "return 1;")
+	if assert.Len(t, results, 1) {
+		assert.ErrorContains(t, results[0].Err, "can't return from top-level code")
+	}
+
+	report := &Report{Results: runner.RunSource("empty_test.lox", "")}
+	assert.False(t, report.Ok())
+	buf := bytes.NewBufferString("")
+	assert.NoError(t, report.WriteText(buf, false))
+	assert.Equal(t, "--- FAIL: empty_test.lox (0.00s)\n"+
+		"    no tests: tests are top-level test_* functions without parameters\n"+
+		"FAIL: 0 passed, 1 failed, 1 total\n", buf.String())
+}
+
+func TestAssertions(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`assert(true); assert(1 == 1, "equal");`, ""},
+		{`assert(false);`, "native function error: assertion failed"},
+		{`assert(nil, "value");`, "native function error: value: assertion failed"},
+		{`assert();`, "native function error: expected at least 1 arguments but got 0"},
+		{`assertEqual("a", "a"); assertEqual([1, [2]], [1, [2]]); assertEqual({"a": [1]}, {"a": [1]});`, ""},
+		{`assertEqual(1, "1");`, `native function error: expected 1 but got "1"`},
+		{`assertEqual([1, 2], [2, 1], "order");`, "native function error: order: expected [1, 2] but got [2, 1]"},
+		{`assertEqual({"a": 1}, {"a": 1, "b": 2});`, `native function error: expected {"a": 1} but got {"a": 1, "b": 2}`},
+		{`var a = []; a.push(a); var b = []; b.push(b); assertEqual(a, b); assertEqual(a, a);`, ""},
+		{`var a = {}; a["self"] = a; var b = {}; b["self"] = b; assertEqual(a, b); assertEqual(a, a);`, ""},
+		{`var a = [1]; a.push(a); var b = [2]; b.push(b); assertEqual(a, b);`, "native function error: expected [1, [...]] but got [2, [...]]"},
+		{`fail();`, "native function error: test failed"},
+		{`fail("todo");`, "native function error: todo"},
+		{`try { fail("caught"); } catch (e) { print e; }`, ""},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("assertions_test_case_%d", i),
+			func(t *testing.T) {
+				runtime := lox.New(lox.WithStdout(bytes.NewBufferString("")))
+				DefineAssertions(runtime)
+				err := runtime.RunString(tc.source)
+				if tc.expected == "" {
+					assert.NoError(t, err)
+				} else if assert.Error(t, err) {
+					assert.Equal(t, tc.expected, err.(*interpret.RuntimeError).Message())
+				}
+			},
+		)
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := &Report{Results: []Result{
+		{File: "a_test.lox", Name: "test_ok", Output: "hello\n"},
+		{File: "a_test.lox", Name: "test_fail", Err: errors.New("boom")},
+		{File: "b_test.lox", Name: "test_ok"},
+	}}
+
+	buf := bytes.NewBufferString("")
+	assert.NoError(t, report.WriteText(buf, false))
+	assert.Equal(t, `--- FAIL: a_test.lox test_fail (0.00s)
+    boom
+FAIL: 2 passed, 1 failed, 3 total
+`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, report.WriteText(buf, true))
+	assert.Equal(t, `--- PASS: a_test.lox test_ok (0.00s)
+    | hello
+--- FAIL: a_test.lox test_fail (0.00s)
+    boom
+--- PASS: b_test.lox test_ok (0.00s)
+FAIL: 2 passed, 1 failed, 3 total
+`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, report.WriteJUnit(buf))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" time="0.000">
+  <testsuite name="a_test.lox" tests="2" failures="1" time="0.000">
+    <testcase name="test_ok" classname="a_test.lox" time="0.000">
+      <system-out>hello&#xA;</system-out>
+    </testcase>
+    <testcase name="test_fail" classname="a_test.lox" time="0.000">
+      <failure message="boom" type="error">boom</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b_test.lox" tests="1" failures="0" time="0.000">
+    <testcase name="test_ok" classname="b_test.lox" time="0.000"></testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
@@ -3,6 +3,7 @@ package parse
 import (
 	"fmt"
 	"github.com/hrumst/gox-lox/lib/scan"
+	"strings"
 )
 
 type ParseError struct {
@@ -20,16 +21,43 @@ func NewParseError(token scan.Token, err error) *ParseError {
 func (pe *ParseError) Error() string {
 	if pe.token.Type == scan.EOF {
 		return fmt.Sprintf(
-			"%d at end %s",
-			pe.token.Line,
+			"%s at end %s",
+			pe.token.Position,
 			pe.err.Error(),
 		)
 	}
 	return fmt.Sprintf(
-		"%d at '%s'(%s). %s",
-		pe.token.Line,
+		"%s at '%s'(%s). %s",
+		pe.token.Position,
 		pe.token.Lexeme,
 		pe.token.Type,
 		pe.err.Error(),
 	)
 }
+
+func (pe *ParseError) Token() scan.Token {
+	return pe.token
+}
+
+func (pe *ParseError) Unwrap() error {
+	return pe.err
+}
+
+// ParseErrors lists every syntax error found in a single Parse run, in source order.
+type ParseErrors []*ParseError
+
+func (pe ParseErrors) Error() string {
+	messages := make([]string, 0, len(pe))
+	for _, err := range pe {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (pe ParseErrors) Unwrap() []error {
+	errs := make([]error, 0, len(pe))
+	for _, err := range pe {
+		errs = append(errs, err)
+	}
+	return errs
+}
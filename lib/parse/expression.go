@@ -15,6 +15,10 @@ type ExpressionVisitor interface {
 	VisitSetExpr(expr *SetExpression) (interface{}, error)
 	VisitThisExpr(expr *ThisExpression) (interface{}, error)
 	VisitSuperExpr(expr *SuperExpression) (interface{}, error)
+	VisitListExpr(expr *ListExpression) (interface{}, error)
+	VisitIndexExpr(expr *IndexExpression) (interface{}, error)
+	VisitIndexSetExpr(expr *IndexSetExpression) (interface{}, error)
+	VisitMapExpr(expr *MapExpression) (interface{}, error)
 }
 
 type Expression interface {
@@ -214,3 +218,75 @@ func NewSuperExpression(keyword scan.Token, method scan.Token) *SuperExpression
 func (se *SuperExpression) Accept(visitor ExpressionVisitor) (interface{}, error) {
 	return visitor.VisitSuperExpr(se)
 }
+
+type ListExpression struct {
+	Bracket  scan.Token
+	Elements []Expression
+}
+
+func NewListExpression(bracket scan.Token, elements []Expression) *ListExpression {
+	return &ListExpression{
+		Bracket:  bracket,
+		Elements: elements,
+	}
+}
+
+func (le *ListExpression) Accept(visitor ExpressionVisitor) (interface{}, error) {
+	return visitor.VisitListExpr(le)
+}
+
+type IndexExpression struct {
+	Object  Expression
+	Bracket scan.Token
+	Index   Expression
+}
+
+func NewIndexExpression(object Expression, bracket scan.Token, index Expression) *IndexExpression {
+	return &IndexExpression{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+	}
+}
+
+func (ie *IndexExpression) Accept(visitor ExpressionVisitor) (interface{}, error) {
+	return visitor.VisitIndexExpr(ie)
+}
+
+type IndexSetExpression struct {
+	Object  Expression
+	Bracket scan.Token
+	Index   Expression
+	Value   Expression
+}
+
+func NewIndexSetExpression(object Expression, bracket scan.Token, index Expression, value Expression) *IndexSetExpression {
+	return &IndexSetExpression{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+		Value:   value,
+	}
+}
+
+func (ie *IndexSetExpression) Accept(visitor ExpressionVisitor) (interface{}, error) {
+	return visitor.VisitIndexSetExpr(ie)
+}
+
+type MapExpression struct {
+	Brace  scan.Token
+	Keys   []Expression
+	Values []Expression
+}
+
+func NewMapExpression(brace scan.Token, keys []Expression, values []Expression) *MapExpression {
+	return &MapExpression{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+	}
+}
+
+func (me *MapExpression) Accept(visitor ExpressionVisitor) (interface{}, error) {
+	return visitor.VisitMapExpr(me)
+}
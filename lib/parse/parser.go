@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"errors"
 	"fmt"
 	"github.com/hrumst/gox-lox/lib/scan"
 )
@@ -8,6 +9,7 @@ import (
 type Parser struct {
 	tokens  []scan.Token
 	current int
+	errors  ParseErrors
 }
 
 // todo add comma separated expressions, add ternar Operator
@@ -31,6 +33,8 @@ func (p *Parser) assignment() (Expression, error) {
 			return NewAssignExpression(varExpr.Name, value), nil
 		} else if getExpr, ok := expr.(*GetExpression); ok {
 			return NewSetExpression(getExpr.Object, getExpr.Name, value), nil
+		} else if indexExpr, ok := expr.(*IndexExpression); ok {
+			return NewIndexSetExpression(indexExpr.Object, indexExpr.Bracket, indexExpr.Index, value), nil
 		}
 		return nil, NewParseError(equals, fmt.Errorf("invalid assignment target"))
 	}
@@ -217,6 +221,16 @@ func (p *Parser) call() (Expression, error) {
 			if err != nil {
 				return nil, err
 			}
+		} else if p.match(scan.LEFT_BRACKET) {
+			bracket := p.previous()
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.consume(scan.RIGHT_BRACKET, "expect ']' after index"); err != nil {
+				return nil, err
+			}
+			expr = NewIndexExpression(expr, bracket, index)
 		} else {
 			break
 		}
@@ -248,7 +262,7 @@ func (p *Parser) finishCall(callee Expression) (Expression, error) {
 	return NewCallExpression(callee, closeParen, arguments), nil
 }
 
-// primary → NUMBER | STRING | "true" | "false" | "nil" | "(" expression ")" ;
+// primary → NUMBER | STRING | "true" | "false" | "nil" | "(" expression ")" | "[" arguments? "]" ;
 func (p *Parser) primary() (Expression, error) {
 	if p.match(scan.FALSE) {
 		return NewLiteralExpression(scan.NewLiteral(scan.NewBooleanLoxValue(false))), nil
@@ -281,11 +295,68 @@ func (p *Parser) primary() (Expression, error) {
 			return nil, err
 		}
 		return NewGroupingExpression(expr), nil
+	} else if p.match(scan.LEFT_BRACKET) {
+		return p.list()
+	} else if p.match(scan.LEFT_BRACE) {
+		return p.mapLiteral()
 	}
 
 	return nil, NewParseError(p.peek(), fmt.Errorf("unexpected token type"))
 }
 
+func (p *Parser) list() (Expression, error) {
+	bracket := p.previous()
+	elements := make([]Expression, 0)
+	if !p.check(scan.RIGHT_BRACKET) {
+		for {
+			element, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+			if !p.match(scan.COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(scan.RIGHT_BRACKET, "expect ']' after list elements"); err != nil {
+		return nil, err
+	}
+	return NewListExpression(bracket, elements), nil
+}
+
+// mapLiteral parses { key: value, ... }, a '{' only starts a map where an
+// expression is expected, in statement position it is still a block.
+func (p *Parser) mapLiteral() (Expression, error) {
+	brace := p.previous()
+	keys := make([]Expression, 0)
+	values := make([]Expression, 0)
+	if !p.check(scan.RIGHT_BRACE) {
+		for {
+			key, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.consume(scan.COLON, "expect ':' after map key"); err != nil {
+				return nil, err
+			}
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+			if !p.match(scan.COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(scan.RIGHT_BRACE, "expect '}' after map entries"); err != nil {
+		return nil, err
+	}
+	return NewMapExpression(brace, keys, values), nil
+}
+
 func (p *Parser) consume(tokenType scan.TokenType, message string) (scan.Token, error) {
 	if p.check(tokenType) {
 		return p.advance(), nil
@@ -334,6 +405,10 @@ func (p *Parser) statement() (Statement, error) {
 		return NewStmtBlock(stmts), nil
 	} else if p.match(scan.CONTINUE) || p.match(scan.BREAK) {
 		return p.breakContinueStatement()
+	} else if p.match(scan.THROW) {
+		return p.throwStatement()
+	} else if p.match(scan.TRY) {
+		return p.tryStatement()
 	}
 	return p.expressionStmt()
 }
@@ -346,6 +421,69 @@ func (p *Parser) breakContinueStatement() (Statement, error) {
 	return stmt, nil
 }
 
+func (p *Parser) throwStatement() (Statement, error) {
+	keyword := p.previous()
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(scan.SEMICOLON, "expect ';' after thrown value"); err != nil {
+		return nil, err
+	}
+	return NewStmtThrow(keyword, value), nil
+}
+
+// tryStatement → "try" block ( "catch" "(" IDENTIFIER ")" block )? ( "finally" block )?
+// with at least one of the catch and finally clauses.
+func (p *Parser) tryStatement() (Statement, error) {
+	keyword := p.previous()
+	if _, err := p.consume(scan.LEFT_BRACE, "expect '{' after 'try'"); err != nil {
+		return nil, err
+	}
+	tryBlock, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		catchName  *scan.Token
+		catchBlock []Statement
+	)
+	if p.match(scan.CATCH) {
+		if _, err := p.consume(scan.LEFT_PAREN, "expect '(' after 'catch'"); err != nil {
+			return nil, err
+		}
+		name, err := p.consume(scan.IDENTIFIER, "expect exception variable name")
+		if err != nil {
+			return nil, err
+		}
+		catchName = &name
+		if _, err := p.consume(scan.RIGHT_PAREN, "expect ')' after exception variable name"); err != nil {
+			return nil, err
+		}
+		if _, err := p.consume(scan.LEFT_BRACE, "expect '{' after catch clause"); err != nil {
+			return nil, err
+		}
+		if catchBlock, err = p.block(); err != nil {
+			return nil, err
+		}
+	}
+
+	var finallyBlock []Statement
+	if p.match(scan.FINALLY) {
+		if _, err := p.consume(scan.LEFT_BRACE, "expect '{' after 'finally'"); err != nil {
+			return nil, err
+		}
+		if finallyBlock, err = p.block(); err != nil {
+			return nil, err
+		}
+	} else if catchName == nil {
+		return nil, NewParseError(keyword, fmt.Errorf("expect 'catch' or 'finally' after try block"))
+	}
+
+	return NewStmtTry(keyword, tryBlock, catchName, catchBlock, finallyBlock), nil
+}
+
 func (p *Parser) whileStatement() (Statement, error) {
 	if _, err := p.consume(scan.LEFT_PAREN, "expect '(' after 'while'"); err != nil {
 		return nil, err
@@ -413,15 +551,6 @@ func (p *Parser) forStatement() (Statement, error) {
 		return nil, err
 	}
 
-	if increment != nil {
-		body = NewStmtBlock(
-			[]Statement{
-				body,
-				NewStmtExpression(increment),
-			},
-		)
-	}
-
 	if condition == nil {
 		condition = NewLiteralExpression(
 			scan.NewLiteral(
@@ -429,7 +558,9 @@ func (p *Parser) forStatement() (Statement, error) {
 			),
 		)
 	}
-	body = NewStmtWhile(condition, body)
+	loop := NewStmtWhile(condition, body)
+	loop.Increment = increment
+	body = loop
 
 	if initializer != nil {
 		body = NewStmtBlock(
@@ -476,7 +607,9 @@ func (p *Parser) block() ([]Statement, error) {
 		if err != nil {
 			return nil, err
 		}
-		stmts = append(stmts, stmt)
+		if stmt != nil {
+			stmts = append(stmts, stmt)
+		}
 	}
 	if _, err := p.consume(scan.RIGHT_BRACE, "expect '}' after block"); err != nil {
 		return nil, err
@@ -504,17 +637,58 @@ func (p *Parser) varDeclaration() (Statement, error) {
 	return NewStmtVar(name, initializer), nil
 }
 
+// declaration recovers from a syntax error by recording it and skipping
+// to the next statement boundary, then returns a nil statement.
 func (p *Parser) declaration() (Statement, error) {
+	stmt, err := p.declarationStmt()
+	if err != nil {
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			return nil, err
+		}
+		p.errors = append(p.errors, parseErr)
+		p.synchronize()
+		return nil, nil
+	}
+	return stmt, nil
+}
+
+func (p *Parser) declarationStmt() (Statement, error) {
 	if p.match(scan.CLASS) {
 		return p.classDeclaration()
 	} else if p.match(scan.FUN) {
 		return p.function("function")
 	} else if p.match(scan.VAR) {
 		return p.varDeclaration()
+	} else if p.match(scan.IMPORT) {
+		return p.importDeclaration()
 	}
 	return p.statement()
 }
 
+// importDeclaration → "import" ( IDENTIFIER "from" )? STRING ";"
+// "from" is not a keyword, it is recognized only here.
+func (p *Parser) importDeclaration() (Statement, error) {
+	keyword := p.previous()
+	var name *scan.Token
+	if p.match(scan.IDENTIFIER) {
+		moduleName := p.previous()
+		name = &moduleName
+		if !p.check(scan.IDENTIFIER) || p.peek().Lexeme != "from" {
+			return nil, NewParseError(p.peek(), fmt.Errorf("expect 'from' after module name"))
+		}
+		p.advance()
+	}
+	path, err := p.consume(scan.STRING, "expect module path string")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(scan.SEMICOLON, "expect ';' after import"); err != nil {
+		return nil, err
+	}
+	return NewStmtImport(keyword, path, name), nil
+}
+
 func (p *Parser) function(kind string) (Statement, error) {
 	name, err := p.consume(scan.IDENTIFIER, fmt.Sprintf("exect %s name", kind))
 	if err != nil {
@@ -555,6 +729,8 @@ func (p *Parser) function(kind string) (Statement, error) {
 	return NewStmtFunction(name, parameters, body), nil
 }
 
+// Parse parses the whole token stream. On syntax errors it keeps going from
+// the next statement and returns all of them together as ParseErrors.
 func (p *Parser) Parse() ([]Statement, error) {
 	stmts := make([]Statement, 0)
 	for !p.isAtEnd() {
@@ -562,12 +738,29 @@ func (p *Parser) Parse() ([]Statement, error) {
 		if err != nil {
 			return nil, err
 		}
-		stmts = append(stmts, stmt)
+		if stmt != nil {
+			stmts = append(stmts, stmt)
+		}
 	}
 
+	if len(p.errors) > 0 {
+		return nil, p.errors
+	}
 	return stmts, nil
 }
 
+// ParseExpression parses tokens as a single expression with no trailing statement terminator.
+func (p *Parser) ParseExpression() (Expression, error) {
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isAtEnd() {
+		return nil, NewParseError(p.peek(), fmt.Errorf("expect end of expression"))
+	}
+	return expr, nil
+}
+
 func (p *Parser) synchronize() {
 	p.advance()
 
@@ -576,7 +769,7 @@ func (p *Parser) synchronize() {
 			return
 		}
 		switch p.peek().Type {
-		case scan.CLASS, scan.FUN, scan.VAR, scan.FOR, scan.IF, scan.WHILE, scan.PRINT, scan.RETURN:
+		case scan.CLASS, scan.FUN, scan.VAR, scan.FOR, scan.IF, scan.WHILE, scan.PRINT, scan.RETURN, scan.THROW, scan.TRY, scan.IMPORT:
 			return
 		}
 		p.advance()
@@ -1,7 +1,163 @@
 package parse
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
 
 func TestParser(t *testing.T) {
 	t.Skip()
 }
+
+func TestParser_ParseReportsAllErrors(t *testing.T) {
+	source := `var a = ;
+print a
+var b = 2;
+print (1 + 2;
+{
+	var c = 1 +;
+	print c;
+}
+print b;
+class { }
+`
+	tokens, err := scan.NewScanner(source).ScanTokens()
+	assert.NoError(t, err)
+
+	stmts, err := NewParser(tokens).Parse()
+	assert.Nil(t, stmts)
+
+	parseErrs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("expected ParseErrors, got %T", err)
+	}
+
+	type errorPosition struct {
+		position string
+		lexeme   string
+	}
+	positions := make([]errorPosition, 0, len(parseErrs))
+	for _, parseErr := range parseErrs {
+		positions = append(positions, errorPosition{parseErr.Token().Position.String(), parseErr.Token().Lexeme})
+	}
+	assert.Equal(
+		t,
+		[]errorPosition{
+			{"1:9", ";"},
+			{"3:1", "var"},
+			{"4:13", ";"},
+			{"6:13", ";"},
+			{"10:7", "{"},
+		},
+		positions,
+	)
+}
+
+func TestParser_ParseValidAfterRecovery(t *testing.T) {
+	tokens, err := scan.NewScanner("var a = ;\nprint 1;\nvar b = 2;").ScanTokens()
+	assert.NoError(t, err)
+
+	// Parse drops the statements once there are errors, declaration shows
+	// what is parsed after the recovery
+	parser := NewParser(tokens)
+	stmts := make([]Statement, 0)
+	for !parser.isAtEnd() {
+		stmt, err := parser.declaration()
+		assert.NoError(t, err)
+		if stmt != nil {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	if assert.Len(t, parser.errors, 1) {
+		assert.Equal(t, "1:9", parser.errors[0].Token().Position.String())
+	}
+	if assert.Len(t, stmts, 2) {
+		assert.IsType(t, &StmtPrint{}, stmts[0])
+		if assert.IsType(t, &StmtVar{}, stmts[1]) {
+			assert.Equal(t, "b", stmts[1].(*StmtVar).Name.Lexeme)
+		}
+	}
+}
+
+func TestParser_ParseForIncrement(t *testing.T) {
+	tokens, err := scan.NewScanner(`for (var i = 0; i < 3; i = i + 1) print i;`).ScanTokens()
+	assert.NoError(t, err)
+
+	stmts, err := NewParser(tokens).Parse()
+	assert.NoError(t, err)
+	// the initializer gets a block of its own, the increment stays out of the body
+	block := stmts[0].(*StmtBlock)
+	loop := block.Stmts[1].(*StmtWhile)
+	assert.IsType(t, &StmtPrint{}, loop.Body)
+	assert.IsType(t, &AssignExpression{}, loop.Increment)
+}
+
+func TestParser_ParseTryErrors(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`try { print 1; }`, "expect 'catch' or 'finally' after try block"},
+		{`try { } catch e { }`, "expect '(' after 'catch'"},
+		{`try { } catch () { }`, "expect exception variable name"},
+		{`try print 1;`, "expect '{' after 'try'"},
+		{`throw;`, "unexpected token type"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("parser_try_errors_test_case_%d", i),
+			func(t *testing.T) {
+				tokens, err := scan.NewScanner(tc.source).ScanTokens()
+				assert.NoError(t, err)
+				_, err = NewParser(tokens).Parse()
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expected)
+				}
+			},
+		)
+	}
+}
+
+func TestParser_ParseImport(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`import "lib/math.lox";`, ""},
+		{`import math from "lib/math.lox"; var from = 1;`, ""},
+		{`import;`, "expect module path string"},
+		{`import math "lib/math.lox";`, "expect 'from' after module name"},
+		{`import "lib/math.lox"`, "expect ';' after import"},
+		{`import math from lib;`, "expect module path string"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("parser_import_test_case_%d", i),
+			func(t *testing.T) {
+				tokens, err := scan.NewScanner(tc.source).ScanTokens()
+				assert.NoError(t, err)
+				stmts, err := NewParser(tokens).Parse()
+				if tc.expected != "" {
+					if assert.Error(t, err) {
+						assert.Contains(t, err.Error(), tc.expected)
+					}
+					return
+				}
+				if assert.NoError(t, err) {
+					stmt := stmts[0].(*StmtImport)
+					assert.Equal(t, "lib/math.lox", stmt.ModulePath())
+				}
+			},
+		)
+	}
+}
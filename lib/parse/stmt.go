@@ -13,6 +13,9 @@ type StatementInterpreter interface {
 	VisitStmtFunction(stmt *StmtFunction) (interface{}, error)
 	VisitStmtReturn(stmt *StmtReturn) (interface{}, error)
 	VisitStmtClass(stmt *StmtClass) (interface{}, error)
+	VisitStmtThrow(stmt *StmtThrow) (interface{}, error)
+	VisitStmtTry(stmt *StmtTry) (interface{}, error)
+	VisitStmtImport(stmt *StmtImport) (interface{}, error)
 }
 
 type Statement interface {
@@ -95,9 +98,13 @@ func (s *StmtIf) Accept(interpreter StatementInterpreter) (interface{}, error) {
 	return interpreter.VisitStmtIf(s)
 }
 
+// StmtWhile is a while loop or a desugared for loop. Increment is the
+// increment clause of a for loop, it runs after every iteration, also one
+// left with continue.
 type StmtWhile struct {
 	Condition Expression
 	Body      Statement
+	Increment Expression
 }
 
 func NewStmtWhile(
@@ -176,3 +183,74 @@ func NewStmtClass(name scan.Token, superClass *VariableExpression, methods []Sta
 func (s *StmtClass) Accept(interpreter StatementInterpreter) (interface{}, error) {
 	return interpreter.VisitStmtClass(s)
 }
+
+type StmtThrow struct {
+	Keyword scan.Token
+	Value   Expression
+}
+
+func NewStmtThrow(keyword scan.Token, value Expression) *StmtThrow {
+	return &StmtThrow{
+		Keyword: keyword,
+		Value:   value,
+	}
+}
+
+func (s *StmtThrow) Accept(interpreter StatementInterpreter) (interface{}, error) {
+	return interpreter.VisitStmtThrow(s)
+}
+
+// StmtTry is a try statement, CatchName is nil when there is no catch clause
+// and FinallyBlock is nil when there is no finally clause.
+type StmtTry struct {
+	Keyword      scan.Token
+	TryBlock     []Statement
+	CatchName    *scan.Token
+	CatchBlock   []Statement
+	FinallyBlock []Statement
+}
+
+func NewStmtTry(
+	keyword scan.Token,
+	tryBlock []Statement,
+	catchName *scan.Token,
+	catchBlock []Statement,
+	finallyBlock []Statement,
+) *StmtTry {
+	return &StmtTry{
+		Keyword:      keyword,
+		TryBlock:     tryBlock,
+		CatchName:    catchName,
+		CatchBlock:   catchBlock,
+		FinallyBlock: finallyBlock,
+	}
+}
+
+func (s *StmtTry) Accept(interpreter StatementInterpreter) (interface{}, error) {
+	return interpreter.VisitStmtTry(s)
+}
+
+// StmtImport is an import statement, Name is nil for the form that brings
+// the exported names of the module into scope one by one.
+type StmtImport struct {
+	Keyword scan.Token
+	Path    scan.Token
+	Name    *scan.Token
+}
+
+func NewStmtImport(keyword scan.Token, path scan.Token, name *scan.Token) *StmtImport {
+	return &StmtImport{
+		Keyword: keyword,
+		Path:    path,
+		Name:    name,
+	}
+}
+
+// ModulePath returns the path of the imported module as written in the source.
+func (s *StmtImport) ModulePath() string {
+	return s.Path.Literal.Value.String()
+}
+
+func (s *StmtImport) Accept(interpreter StatementInterpreter) (interface{}, error) {
+	return interpreter.VisitStmtImport(s)
+}
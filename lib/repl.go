@@ -0,0 +1,255 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hrumst/gox-lox/lib/diagnostics"
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/lox"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+const (
+	replPrompt         = "> "
+	replContinuePrompt = "... "
+	replSourceName     = "<repl>"
+)
+
+const replHelp = `commands:
+  :env           print the current environment chain and globals
+  :ast <code>    print the parse tree of code
+  :tokens <code> print the tokens of code
+  :load <file>   run a script inside the session
+  :reset         drop all session state
+  :help          print this help
+  :quit          exit the prompt`
+
+// Repl is an interactive session: the runtime lives as long as the session, so declarations made on one line are visible on the next.
+type Repl struct {
+	input     io.Reader
+	writer    io.Writer
+	errWriter io.Writer
+	color     bool
+	runtime   *lox.Runtime
+}
+
+// NewRepl creates a session, color enables ANSI colors in error reports.
+func NewRepl(input io.Reader, writer, errWriter io.Writer, color bool) *Repl {
+	return &Repl{
+		input:     input,
+		writer:    writer,
+		errWriter: errWriter,
+		color:     color,
+		runtime:   lox.New(lox.WithStdout(writer), lox.WithStderr(errWriter), lox.WithColor(color)),
+	}
+}
+
+// Run reads input until EOF or a quit command. Errors are reported and
+// don't end the session; only a failure to read input is returned.
+func (r *Repl) Run() error {
+	scanner := bufio.NewScanner(r.input)
+	fmt.Fprintln(r.writer, "Please enter script (type 'q' or 'quit' to exit):")
+
+	var source strings.Builder
+	fmt.Fprint(r.writer, replPrompt)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if source.Len() == 0 && (line == "q" || line == "quit" || line == ":q" || line == ":quit") {
+			return nil
+		}
+		if source.Len() == 0 && strings.HasPrefix(line, ":") {
+			if err := r.runCommand(line); err != nil {
+				fmt.Fprintln(r.errWriter, err.Error())
+			}
+			fmt.Fprint(r.writer, replPrompt)
+			continue
+		}
+
+		source.WriteString(line)
+		source.WriteString("\n")
+		if isIncompleteInput(source.String()) {
+			fmt.Fprint(r.writer, replContinuePrompt)
+			continue
+		}
+
+		if err := r.runLine(source.String()); err != nil {
+			r.reportError(replSourceName, source.String(), err)
+		}
+		source.Reset()
+		fmt.Fprint(r.writer, replPrompt)
+	}
+	return scanner.Err()
+}
+
+func (r *Repl) reportError(name, source string, err error) {
+	renderer := diagnostics.NewRenderer(r.color)
+	renderer.AddSource(name, source)
+	renderer.RenderError(r.errWriter, err)
+}
+
+func (r *Repl) runCommand(line string) error {
+	command, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch command {
+	case ":env":
+		return r.printEnvironment()
+	case ":ast":
+		return r.printAst(arg)
+	case ":tokens":
+		return r.printTokens(arg)
+	case ":load":
+		source, err := os.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		if err := r.runtime.RunSource(arg, string(source)); err != nil {
+			r.reportError(arg, string(source), err)
+		}
+		return nil
+	case ":reset":
+		r.runtime.Reset()
+		return nil
+	case ":help":
+		_, err := fmt.Fprintln(r.writer, replHelp)
+		return err
+	}
+	return fmt.Errorf("unknown command %s, type :help for the list of commands", command)
+}
+
+func (r *Repl) printEnvironment() error {
+	depth := 0
+	interpreter := r.runtime.Interpreter()
+	for env := interpreter.Environment(); env != interpreter.Globals(); env = env.Enclosing() {
+		fmt.Fprintf(r.writer, "scope %d:\n", depth)
+		r.printScope(env)
+		depth += 1
+	}
+	fmt.Fprintln(r.writer, "globals:")
+	r.printScope(interpreter.Globals())
+	return nil
+}
+
+func (r *Repl) printScope(env *interpret.Environment) {
+	for _, name := range env.Names() {
+		value, _ := env.Lookup(name)
+		if value == nil {
+			fmt.Fprintf(r.writer, "  %s = <uninitialized>\n", name)
+			continue
+		}
+		fmt.Fprintf(r.writer, "  %s = %s\n", name, value.String())
+	}
+}
+
+func (r *Repl) printAst(source string) error {
+	tokens, err := Run(source)
+	if err != nil {
+		return err
+	}
+
+	printer := interpret.NewAstPrinter(false)
+	var tree string
+	if stmts, err := parse.NewParser(tokens).Parse(); err == nil {
+		tree, err = printer.PrintStmts(stmts)
+		if err != nil {
+			return err
+		}
+	} else {
+		expr, exprErr := parse.NewParser(tokens).ParseExpression()
+		if exprErr != nil {
+			return err
+		}
+		if tree, err = printer.Print(expr); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(r.writer, tree)
+	return err
+}
+
+func (r *Repl) printTokens(source string) error {
+	tokens, err := Run(source)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		fmt.Fprintln(r.writer, token)
+	}
+	return nil
+}
+
+func (r *Repl) runLine(source string) error {
+	tokens, err := scan.NewFileScanner(replSourceName, source).ScanTokens()
+	if err != nil {
+		return err
+	}
+
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		// a bare expression may be entered without the trailing ';'
+		expr, exprErr := parse.NewParser(tokens).ParseExpression()
+		if exprErr != nil {
+			return err
+		}
+		return r.printExpression(expr)
+	}
+
+	if len(stmts) == 1 {
+		if stmt, ok := stmts[0].(*parse.StmtExpression); ok && !isAssignment(stmt.Expression) {
+			return r.printExpression(stmt.Expression)
+		}
+	}
+
+	return r.runtime.Execute(stmts)
+}
+
+// printExpression evaluates expr and prints its value. A call returning nil
+// prints nothing, such calls are usually run for their effects, e.g. f();
+func (r *Repl) printExpression(expr parse.Expression) error {
+	value, err := r.runtime.Evaluate(expr)
+	if err != nil {
+		return err
+	}
+	if _, ok := expr.(*parse.CallExpression); ok && value.IsNil() {
+		return nil
+	}
+	_, err = fmt.Fprintln(r.writer, value.String())
+	return err
+}
+
+func isAssignment(expr parse.Expression) bool {
+	switch expr.(type) {
+	case *parse.AssignExpression, *parse.SetExpression:
+		return true
+	}
+	return false
+}
+
+// isIncompleteInput reports whether source has unclosed braces, parens or brackets,
+// so the prompt should keep reading lines before running it.
+func isIncompleteInput(source string) bool {
+	tokens, err := Run(source)
+	if err != nil {
+		return false
+	}
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case scan.LEFT_BRACE, scan.LEFT_PAREN, scan.LEFT_BRACKET:
+			depth += 1
+		case scan.RIGHT_BRACE, scan.RIGHT_PAREN, scan.RIGHT_BRACKET:
+			depth -= 1
+		}
+	}
+	return depth > 0
+}
+
+func RunPrompt(color bool) {
+	if err := NewRepl(os.Stdin, os.Stdout, os.Stderr, color).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "error on reading input:", err)
+	}
+}
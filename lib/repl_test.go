@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepl_Run(t *testing.T) {
+	input := strings.Join(
+		[]string{
+			`var x = 1;`,
+			`x + 1`,
+			`fun double(a) {`,
+			`  return a * 2;`,
+			`}`,
+			`double(x);`,
+			`fun greet() { print "hi"; }`,
+			`greet();`,
+			`greet()`,
+			`nil`,
+			`print y;`,
+			`x = 5;`,
+			`var x = x;`,
+			`var x = 7;`,
+			`x`,
+			`q`,
+			`print "unreachable";`,
+		},
+		"\n",
+	)
+
+	out, errOut := bytes.NewBufferString(""), bytes.NewBufferString("")
+	assert.NoError(t, NewRepl(strings.NewReader(input), out, errOut, false).Run())
+
+	var printed []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.TrimLeft(line, "> .")
+		if line != "" {
+			printed = append(printed, line)
+		}
+	}
+	assert.Equal(
+		t,
+		[]string{"Please enter script (type 'q' or 'quit' to exit):", "2", "2", "hi", "hi", "nil", "7"},
+		printed,
+	)
+	assert.Contains(t, errOut.String(), "undefined variable")
+	assert.Contains(t, errOut.String(), "can't read local variable in its own initializer")
+}
+
+func TestIsIncompleteInput(t *testing.T) {
+	assert.True(t, isIncompleteInput("fun f() {\n"))
+	assert.True(t, isIncompleteInput("print (1 +\n"))
+	assert.False(t, isIncompleteInput("{ print 1; }\n"))
+	assert.False(t, isIncompleteInput("}\n"))
+}
+
+func TestRepl_Commands(t *testing.T) {
+	input := strings.Join(
+		[]string{
+			`var x = 1;`,
+			`fun f() {}`,
+			`:env`,
+			`:ast print -1 + x;`,
+			`:ast a.b = c or d`,
+			`:tokens x;`,
+			`:reset`,
+			`:env`,
+			`:unknown`,
+		},
+		"\n",
+	)
+
+	out, errOut := bytes.NewBufferString(""), bytes.NewBufferString("")
+	assert.NoError(t, NewRepl(strings.NewReader(input), out, errOut, false).Run())
+
+	assert.Contains(t, out.String(), "scope 0:\n  f = [function] f\n  x = 1\nglobals:\n  clock = [function] clock\n")
+	assert.Contains(t, out.String(), "(print (+ (- 1) x))\n")
+	assert.Contains(t, out.String(), "(= a b (or c d))\n")
+	assert.Contains(t, out.String(), "IDENTIFIER x <nil>\nSEMICOLON ; <nil>\nEOF  <nil>\n")
+	assert.Contains(t, out.String(), "scope 0:\nglobals:\n")
+	assert.Contains(t, errOut.String(), "unknown command :unknown")
+}
@@ -1,47 +1,57 @@
 package lib
 
 import (
-	"bufio"
-	"fmt"
+	"errors"
+	"io"
 	"os"
 
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/lox"
+	"github.com/hrumst/gox-lox/lib/parse"
 	"github.com/hrumst/gox-lox/lib/scan"
 )
 
+// exit codes follow sysexits.h
+const (
+	ExitOK       = 0
+	ExitUsage    = 64
+	ExitDataErr  = 65
+	ExitNoInput  = 66
+	ExitSoftware = 70
+	ExitIOErr    = 74
+)
+
 func Run(source string) ([]scan.Token, error) {
 	return scan.NewScanner(source).ScanTokens()
 }
 
-func runPrintTokens(source string) error {
-	tokens, err := Run(source)
-	if err != nil {
-		return err
-	}
-	for _, token := range tokens {
-		fmt.Println(token)
-	}
-	return nil
+// RunSource executes source through the whole scan → parse → resolve → interpret pipeline
+// in a fresh runtime. The name is used as file name in error positions.
+func RunSource(name, source string, writer io.Writer) error {
+	return lox.New(lox.WithStdout(writer)).RunSource(name, source)
 }
 
-func RunPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Println("Please enter script (type 'q' or 'quit' to exit):")
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "q" || line == "quit" {
-			break
-		}
-		runPrintTokens(line)
-	}
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintln(os.Stderr, "error on reading input:", err)
-	}
+func RunFile(path string, writer io.Writer) error {
+	return lox.New(lox.WithStdout(writer)).RunFile(path)
 }
 
-func RunFile(path string) {
-	source, err := os.ReadFile(path)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error on reading input:", err)
+// ExitCode maps an error returned by RunSource or RunFile to a process exit code.
+func ExitCode(err error) int {
+	var (
+		scanErr    *scan.ScanError
+		parseErr   *parse.ParseError
+		parseErrs  parse.ParseErrors
+		resolveErr *interpret.ResolveError
+		pathErr    *os.PathError
+	)
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &scanErr), errors.As(err, &parseErr), errors.As(err, &parseErrs), errors.As(err, &resolveErr):
+		return ExitDataErr
+	case errors.As(err, &pathErr):
+		return ExitNoInput
+	default:
+		return ExitSoftware
 	}
-	runPrintTokens(string(source))
 }
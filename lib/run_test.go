@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSource(t *testing.T) {
+	type testCase struct {
+		source       string
+		expected     string
+		expectedCode int
+	}
+
+	tcs := []testCase{
+		{
+			source:       `var a = 1; { var b = a + 1; print b; }`,
+			expected:     "2\n",
+			expectedCode: ExitOK,
+		}, {
+			source:       `print "unterminated;`,
+			expectedCode: ExitDataErr,
+		}, {
+			source:       `print 1 +;`,
+			expectedCode: ExitDataErr,
+		}, {
+			source:       `return 1;`,
+			expectedCode: ExitDataErr,
+		}, {
+			source:       `print "before"; print 1 / 0;`,
+			expected:     "before\n",
+			expectedCode: ExitSoftware,
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("run_source_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				err := RunSource("test.lox", tc.source, buf)
+				assert.Equal(t, tc.expectedCode, ExitCode(err))
+				assert.Equal(t, tc.expected, buf.String())
+			},
+		)
+	}
+}
+
+func TestRunFile_NoInput(t *testing.T) {
+	err := RunFile("does/not/exist.lox", bytes.NewBufferString(""))
+	assert.Equal(t, ExitNoInput, ExitCode(err))
+}
@@ -1,5 +1,9 @@
 package scan
 
+// VariadicArity is the arity of callables accepting any number of arguments,
+// such callables check the argument count themselves.
+const VariadicArity = -1
+
 type LoxCallable interface {
 	String() string
 	Arity() int
@@ -11,5 +15,5 @@ type LoxClass LoxCallable
 type LoxClassInstance interface {
 	String() string
 	Get(name Token) (*LoxValue, error)
-	Set(name Token, value *LoxValue)
+	Set(name Token, value *LoxValue) error
 }
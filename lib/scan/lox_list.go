@@ -0,0 +1,126 @@
+package scan
+
+import (
+	"fmt"
+	"math"
+)
+
+// LoxList is a mutable list, values referring to it share the elements.
+type LoxList struct {
+	elements []*LoxValue
+}
+
+func NewLoxList(elements []*LoxValue) *LoxList {
+	return &LoxList{
+		elements: elements,
+	}
+}
+
+func (ll *LoxList) Len() int {
+	return len(ll.elements)
+}
+
+// Elements returns the underlying elements, changes to the slice are visible in the list.
+func (ll *LoxList) Elements() []*LoxValue {
+	return ll.elements
+}
+
+// index converts a Lox index to a slice index, negative indices count from the end.
+func (ll *LoxList) index(index float64, length int) (int, error) {
+	if index != math.Trunc(index) {
+		return 0, fmt.Errorf("list index must be an integer, got %v", index)
+	}
+	position := int(index)
+	if position < 0 {
+		position += length
+	}
+	if position < 0 || position >= length {
+		return 0, fmt.Errorf("list index %v out of range", index)
+	}
+	return position, nil
+}
+
+func (ll *LoxList) Get(index float64) (*LoxValue, error) {
+	position, err := ll.index(index, len(ll.elements))
+	if err != nil {
+		return nil, err
+	}
+	return ll.elements[position], nil
+}
+
+func (ll *LoxList) Set(index float64, value *LoxValue) error {
+	position, err := ll.index(index, len(ll.elements))
+	if err != nil {
+		return err
+	}
+	ll.elements[position] = value
+	return nil
+}
+
+func (ll *LoxList) Push(value *LoxValue) {
+	ll.elements = append(ll.elements, value)
+}
+
+func (ll *LoxList) Pop() (*LoxValue, error) {
+	if len(ll.elements) == 0 {
+		return nil, fmt.Errorf("pop from empty list")
+	}
+	return ll.Remove(-1)
+}
+
+// Insert puts value before the element at index, index equal to the length appends.
+func (ll *LoxList) Insert(index float64, value *LoxValue) error {
+	if index != math.Trunc(index) {
+		return fmt.Errorf("list index must be an integer, got %v", index)
+	}
+	// unlike other operations -1 means before the last element
+	position := int(index)
+	if position < 0 {
+		position += len(ll.elements)
+	}
+	if position < 0 || position > len(ll.elements) {
+		return fmt.Errorf("list index %v out of range", index)
+	}
+	ll.elements = append(ll.elements, nil)
+	copy(ll.elements[position+1:], ll.elements[position:])
+	ll.elements[position] = value
+	return nil
+}
+
+func (ll *LoxList) Remove(index float64) (*LoxValue, error) {
+	position, err := ll.index(index, len(ll.elements))
+	if err != nil {
+		return nil, err
+	}
+	value := ll.elements[position]
+	ll.elements = append(ll.elements[:position], ll.elements[position+1:]...)
+	return value, nil
+}
+
+// Slice returns a new list of elements from start up to but not including end.
+// Negative bounds count from the end, bounds outside of the list are clamped.
+func (ll *LoxList) Slice(start, end float64) (*LoxList, error) {
+	if start != math.Trunc(start) || end != math.Trunc(end) {
+		return nil, fmt.Errorf("slice bounds must be integers")
+	}
+	from, to := ll.clamp(int(start)), ll.clamp(int(end))
+	if from > to {
+		from = to
+	}
+	elements := make([]*LoxValue, to-from)
+	copy(elements, ll.elements[from:to])
+	return NewLoxList(elements), nil
+}
+
+func (ll *LoxList) clamp(position int) int {
+	if position < 0 {
+		position += len(ll.elements)
+	}
+	if position < 0 {
+		return 0
+	}
+	if position > len(ll.elements) {
+		return len(ll.elements)
+	}
+	return position
+}
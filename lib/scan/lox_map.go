@@ -0,0 +1,148 @@
+package scan
+
+import (
+	"fmt"
+	"math"
+)
+
+// mapKey is the hashable form of a map key. Only strings, numbers,
+// booleans and nil can be keys; two keys are equal when their Lox values are.
+type mapKey struct {
+	valueType   int
+	stringValue string
+	floatValue  float64
+	boolValue   bool
+}
+
+type mapEntry struct {
+	key, value *LoxValue
+	// index is the position of the entry in the order of its map
+	index int
+}
+
+// LoxMap is a mutable hash map that keeps keys in insertion order. Deleted
+// entries leave a nil in order until more than half of it is nil, so a
+// delete doesn't have to shift the entries after it.
+type LoxMap struct {
+	entries map[mapKey]*mapEntry
+	order   []*mapEntry
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{
+		entries: make(map[mapKey]*mapEntry),
+		order:   make([]*mapEntry, 0),
+	}
+}
+
+func (l *LoxValue) mapKey() (mapKey, error) {
+	switch l.valueType {
+	case loxValueStringType:
+		return mapKey{valueType: l.valueType, stringValue: l.stringValue}, nil
+	case loxValueFloatType:
+		if math.IsNaN(l.floatValue) {
+			return mapKey{}, fmt.Errorf("NaN can't be a map key")
+		}
+		// +0 and -0 are the same key as they are equal numbers
+		return mapKey{valueType: l.valueType, floatValue: l.floatValue + 0}, nil
+	case loxValueBoolType:
+		return mapKey{valueType: l.valueType, boolValue: l.boolValue}, nil
+	case loxValueNilType:
+		return mapKey{valueType: l.valueType}, nil
+	}
+	return mapKey{}, fmt.Errorf("%s can't be a map key", l.TypeName())
+}
+
+func (lm *LoxMap) Len() int {
+	return len(lm.entries)
+}
+
+// Get returns the value for key, ok is false when the key is absent.
+func (lm *LoxMap) Get(key *LoxValue) (*LoxValue, bool, error) {
+	hashKey, err := key.mapKey()
+	if err != nil {
+		return nil, false, err
+	}
+	entry, ok := lm.entries[hashKey]
+	if !ok {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (lm *LoxMap) Set(key, value *LoxValue) error {
+	hashKey, err := key.mapKey()
+	if err != nil {
+		return err
+	}
+	if entry, ok := lm.entries[hashKey]; ok {
+		entry.value = value
+		return nil
+	}
+	entry := &mapEntry{key: key, value: value, index: len(lm.order)}
+	lm.entries[hashKey] = entry
+	lm.order = append(lm.order, entry)
+	return nil
+}
+
+func (lm *LoxMap) Has(key *LoxValue) (bool, error) {
+	_, ok, err := lm.Get(key)
+	return ok, err
+}
+
+// Delete removes key and reports whether it was present.
+func (lm *LoxMap) Delete(key *LoxValue) (bool, error) {
+	hashKey, err := key.mapKey()
+	if err != nil {
+		return false, err
+	}
+	entry, ok := lm.entries[hashKey]
+	if !ok {
+		return false, nil
+	}
+	delete(lm.entries, hashKey)
+	lm.order[entry.index] = nil
+	if len(lm.entries) < len(lm.order)/2 {
+		lm.compact()
+	}
+	return true, nil
+}
+
+// compact drops the deleted entries from order.
+func (lm *LoxMap) compact() {
+	order := make([]*mapEntry, 0, len(lm.entries))
+	for _, entry := range lm.order {
+		if entry != nil {
+			entry.index = len(order)
+			order = append(order, entry)
+		}
+	}
+	lm.order = order
+}
+
+// each calls fn for every entry in insertion order.
+func (lm *LoxMap) each(fn func(entry *mapEntry)) {
+	for _, entry := range lm.order {
+		if entry != nil {
+			fn(entry)
+		}
+	}
+}
+
+// Keys returns keys in insertion order.
+func (lm *LoxMap) Keys() []*LoxValue {
+	keys := make([]*LoxValue, 0, lm.Len())
+	lm.each(func(entry *mapEntry) {
+		keys = append(keys, entry.key)
+	})
+	return keys
+}
+
+// Values returns values in the insertion order of their keys.
+func (lm *LoxMap) Values() []*LoxValue {
+	values := make([]*LoxValue, 0, lm.Len())
+	lm.each(func(entry *mapEntry) {
+		values = append(values, entry.value)
+	})
+	return values
+}
@@ -0,0 +1,64 @@
+package scan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoxMap_Delete(t *testing.T) {
+	type testCase struct {
+		set      []float64
+		delete   []float64
+		expected string
+	}
+
+	tcs := []testCase{
+		{[]float64{1, 2, 3}, []float64{2}, "{1: 1, 3: 3}"},
+		{[]float64{1, 2, 3}, []float64{1, 4}, "{2: 2, 3: 3}"},
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}, "{}"},
+		{[]float64{1, 2, 3, 4, 5}, []float64{1, 2, 3}, "{4: 4, 5: 5}"},
+		{[]float64{1, 2, 3, 4, 5, 1, 2}, []float64{1, 2, 3, 4}, "{5: 5}"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("lox_map_delete_test_case_%d", i),
+			func(t *testing.T) {
+				loxMap := NewLoxMap()
+				for _, key := range tc.set {
+					assert.NoError(t, loxMap.Set(NewFloatLoxValue(key), NewFloatLoxValue(key)))
+				}
+				for _, key := range tc.delete {
+					_, err := loxMap.Delete(NewFloatLoxValue(key))
+					assert.NoError(t, err)
+				}
+				assert.Equal(t, tc.expected, NewMapLoxValue(loxMap).String())
+				assert.Equal(t, len(loxMap.Keys()), loxMap.Len())
+			},
+		)
+	}
+}
+
+func TestLoxMap_DeleteThenSet(t *testing.T) {
+	loxMap := NewLoxMap()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, loxMap.Set(NewFloatLoxValue(float64(i)), NewNilLoxValue()))
+	}
+	for i := 0; i < 8; i++ {
+		deleted, err := loxMap.Delete(NewFloatLoxValue(float64(i)))
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+	}
+	assert.NoError(t, loxMap.Set(NewFloatLoxValue(0), NewNilLoxValue()))
+
+	assert.Equal(t, "{8: nil, 9: nil, 0: nil}", NewMapLoxValue(loxMap).String())
+	// deleted entries are compacted away once they are the majority
+	assert.Less(t, len(loxMap.order), 10)
+	for index, entry := range loxMap.order {
+		if entry != nil {
+			assert.Equal(t, index, entry.index)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package scan
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -13,6 +14,8 @@ const (
 	loxCallableType
 	loxClassType
 	loxClassInstanceType
+	loxListType
+	loxMapType
 )
 
 type NilObject struct{}
@@ -26,6 +29,8 @@ type LoxValue struct {
 	callableObject LoxCallable
 	classObject    LoxClass
 	classInstance  LoxClassInstance
+	listObject     *LoxList
+	mapObject      *LoxMap
 }
 
 func (l *LoxValue) IsNumber() bool {
@@ -56,6 +61,84 @@ func (l *LoxValue) IsClassInstance() bool {
 	return l.valueType == loxClassInstanceType
 }
 
+func (l *LoxValue) IsList() bool {
+	return l.valueType == loxListType
+}
+
+func (l *LoxValue) List() (*LoxList, error) {
+	if l.valueType == loxListType {
+		return l.listObject, nil
+	}
+	return nil, fmt.Errorf("%s is not a list", l.TypeName())
+}
+
+func (l *LoxValue) IsMap() bool {
+	return l.valueType == loxMapType
+}
+
+func (l *LoxValue) Map() (*LoxMap, error) {
+	if l.valueType == loxMapType {
+		return l.mapObject, nil
+	}
+	return nil, fmt.Errorf("%s is not a map", l.TypeName())
+}
+
+// TypeName is the name of the value type used in error messages.
+func (l *LoxValue) TypeName() string {
+	switch l.valueType {
+	case loxValueFloatType:
+		return "float"
+	case loxValueStringType:
+		return "string"
+	case loxValueBoolType:
+		return "bool"
+	case loxValueNilType:
+		return "nil"
+	case loxCallableType:
+		return "function"
+	case loxClassType, loxClassInstanceType:
+		return "class"
+	case loxListType:
+		return "list"
+	case loxMapType:
+		return "map"
+	}
+
+	// unreachable
+	panic("use not implemented value type")
+}
+
+// Equal compares numbers, strings and booleans by value, every other
+// value type (lists, maps, functions, classes, instances) by identity.
+func (l *LoxValue) Equal(other *LoxValue) bool {
+	if l.valueType != other.valueType {
+		return false
+	}
+	switch l.valueType {
+	case loxValueStringType:
+		return l.stringValue == other.stringValue
+	case loxValueFloatType:
+		return l.floatValue == other.floatValue
+	case loxValueBoolType:
+		return l.boolValue == other.boolValue
+	case loxValueNilType:
+		return true
+	case loxCallableType:
+		return l.callableObject == other.callableObject
+	case loxClassType:
+		return l.classObject == other.classObject
+	case loxClassInstanceType:
+		return l.classInstance == other.classInstance
+	case loxListType:
+		return l.listObject == other.listObject
+	case loxMapType:
+		return l.mapObject == other.mapObject
+	}
+
+	// unreachable
+	panic("use not implemented value type")
+}
+
 func (l *LoxValue) ClassInstance() (LoxClassInstance, error) {
 	switch l.valueType {
 	case loxClassInstanceType:
@@ -72,6 +155,10 @@ func (l *LoxValue) ClassInstance() (LoxClassInstance, error) {
 		return nil, fmt.Errorf("nil is not a class instance")
 	case loxClassType:
 		return nil, fmt.Errorf("class is not a class instance")
+	case loxListType:
+		return nil, fmt.Errorf("list is not a class instance")
+	case loxMapType:
+		return nil, fmt.Errorf("map is not a class instance")
 	}
 
 	// unreachable
@@ -94,6 +181,10 @@ func (l *LoxValue) Callable() (LoxCallable, error) {
 		return nil, fmt.Errorf("nil is not a function")
 	case loxClassInstanceType:
 		return nil, fmt.Errorf("class is not a function")
+	case loxListType:
+		return nil, fmt.Errorf("list is not a function")
+	case loxMapType:
+		return nil, fmt.Errorf("map is not a function")
 	}
 
 	// unreachable
@@ -114,6 +205,10 @@ func (l *LoxValue) Number() (float64, error) {
 		return 0., fmt.Errorf("function is not a number")
 	case loxClassInstanceType, loxClassType:
 		return 0., fmt.Errorf("class is not a number")
+	case loxListType:
+		return 0., fmt.Errorf("list is not a number")
+	case loxMapType:
+		return 0., fmt.Errorf("map is not a number")
 	}
 
 	// unreachable
@@ -121,7 +216,49 @@ func (l *LoxValue) Number() (float64, error) {
 }
 
 func (l *LoxValue) String() string {
+	return l.format(make(map[interface{}]bool))
+}
+
+// format renders nested values, strings inside collections are quoted and
+// collections already being printed are shown as [...] or {...} to stop on cycles.
+func (l *LoxValue) format(printing map[interface{}]bool) string {
 	switch l.valueType {
+	case loxListType:
+		if printing[l.listObject] {
+			return "[...]"
+		}
+		printing[l.listObject] = true
+		defer delete(printing, l.listObject)
+
+		var sb strings.Builder
+		sb.WriteString("[")
+		for i, element := range l.listObject.elements {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(element.formatElement(printing))
+		}
+		sb.WriteString("]")
+		return sb.String()
+	case loxMapType:
+		if printing[l.mapObject] {
+			return "{...}"
+		}
+		printing[l.mapObject] = true
+		defer delete(printing, l.mapObject)
+
+		var sb strings.Builder
+		sb.WriteString("{")
+		l.mapObject.each(func(entry *mapEntry) {
+			if sb.Len() > 1 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(entry.key.formatElement(printing))
+			sb.WriteString(": ")
+			sb.WriteString(entry.value.formatElement(printing))
+		})
+		sb.WriteString("}")
+		return sb.String()
 	case loxValueStringType:
 		return l.stringValue
 	case loxValueBoolType:
@@ -142,6 +279,13 @@ func (l *LoxValue) String() string {
 	panic("use not implemented value type")
 }
 
+func (l *LoxValue) formatElement(printing map[interface{}]bool) string {
+	if l.valueType == loxValueStringType {
+		return strconv.Quote(l.stringValue)
+	}
+	return l.format(printing)
+}
+
 func (l *LoxValue) Bool() bool {
 	switch l.valueType {
 	case loxValueBoolType:
@@ -201,3 +345,17 @@ func NewClassInstanceLoxValue(classInstance LoxClassInstance) *LoxValue {
 		classInstance: classInstance,
 	}
 }
+
+func NewListLoxValue(list *LoxList) *LoxValue {
+	return &LoxValue{
+		valueType:  loxListType,
+		listObject: list,
+	}
+}
+
+func NewMapLoxValue(loxMap *LoxMap) *LoxValue {
+	return &LoxValue{
+		valueType: loxMapType,
+		mapObject: loxMap,
+	}
+}
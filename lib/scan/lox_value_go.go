@@ -0,0 +1,255 @@
+package scan
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// GoFunc is the Go form of a Lox function or class: arguments and the
+// result are converted with FromGo and ToGo.
+type GoFunc func(args ...interface{}) (interface{}, error)
+
+// LoxFieldsInstance is implemented by class instances whose fields are
+// snapshotted by ToGo.
+type LoxFieldsInstance interface {
+	LoxClassInstance
+	Fields() map[string]*LoxValue
+}
+
+// LoxGoInstance is implemented by class instances wrapping a Go value,
+// ToGo returns that value.
+type LoxGoInstance interface {
+	LoxClassInstance
+	Value() interface{}
+}
+
+// ToGo converts the value to plain Go data: float64, string, bool and nil for
+// primitives, []interface{} for lists, map[interface{}]interface{} for maps,
+// GoFunc for functions and classes, map[string]interface{} with a snapshot
+// of fields for class instances, or the wrapped value of native instances.
+func (l *LoxValue) ToGo() (interface{}, error) {
+	return l.toGo(make(map[interface{}]bool))
+}
+
+func (l *LoxValue) toGo(converting map[interface{}]bool) (interface{}, error) {
+	switch l.valueType {
+	case loxValueFloatType:
+		return l.floatValue, nil
+	case loxValueStringType:
+		return l.stringValue, nil
+	case loxValueBoolType:
+		return l.boolValue, nil
+	case loxValueNilType:
+		return nil, nil
+	case loxCallableType:
+		return goFunc(l.callableObject), nil
+	case loxClassType:
+		return goFunc(l.classObject), nil
+	case loxClassInstanceType:
+		switch instance := l.classInstance.(type) {
+		case LoxGoInstance:
+			return instance.Value(), nil
+		case LoxFieldsInstance:
+			if converting[instance] {
+				return nil, fmt.Errorf("can't convert cyclic value to Go")
+			}
+			converting[instance] = true
+			defer delete(converting, instance)
+
+			fields := make(map[string]interface{})
+			for name, field := range instance.Fields() {
+				goField, err := field.toGo(converting)
+				if err != nil {
+					return nil, err
+				}
+				fields[name] = goField
+			}
+			return fields, nil
+		}
+		return nil, fmt.Errorf("can't convert %s to Go", l.classInstance.String())
+	case loxListType:
+		if converting[l.listObject] {
+			return nil, fmt.Errorf("can't convert cyclic value to Go")
+		}
+		converting[l.listObject] = true
+		defer delete(converting, l.listObject)
+
+		elements := make([]interface{}, 0, l.listObject.Len())
+		for _, element := range l.listObject.elements {
+			goElement, err := element.toGo(converting)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, goElement)
+		}
+		return elements, nil
+	case loxMapType:
+		if converting[l.mapObject] {
+			return nil, fmt.Errorf("can't convert cyclic value to Go")
+		}
+		converting[l.mapObject] = true
+		defer delete(converting, l.mapObject)
+
+		entries := make(map[interface{}]interface{}, l.mapObject.Len())
+		for _, entry := range l.mapObject.order {
+			if entry == nil {
+				continue
+			}
+			key, err := entry.key.toGo(converting)
+			if err != nil {
+				return nil, err
+			}
+			value, err := entry.value.toGo(converting)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return entries, nil
+	}
+
+	// unreachable
+	panic("use not implemented value type")
+}
+
+func goFunc(callable LoxCallable) GoFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if arity := callable.Arity(); arity != VariadicArity && arity != len(args) {
+			return nil, fmt.Errorf("expected %d arguments but got %d", arity, len(args))
+		}
+		loxArgs := make([]*LoxValue, 0, len(args))
+		for _, arg := range args {
+			loxArg, err := FromGo(arg)
+			if err != nil {
+				return nil, err
+			}
+			loxArgs = append(loxArgs, loxArg)
+		}
+		result, err := callable.Call(loxArgs)
+		if err != nil {
+			return nil, err
+		}
+		return result.ToGo()
+	}
+}
+
+// goCallable makes a GoFunc callable from scripts.
+type goCallable struct {
+	fn GoFunc
+}
+
+func (g goCallable) String() string {
+	return "[function] <go>"
+}
+
+func (g goCallable) Arity() int {
+	return VariadicArity
+}
+
+func (g goCallable) Call(args []*LoxValue) (*LoxValue, error) {
+	goArgs := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		goArg, err := arg.ToGo()
+		if err != nil {
+			return nil, err
+		}
+		goArgs = append(goArgs, goArg)
+	}
+	result, err := g.fn(goArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return FromGo(result)
+}
+
+// FromGo converts Go data to a Lox value. Besides the types ToGo produces it
+// accepts every integer and float type, slices and arrays, maps with keys that
+// convert to strings, numbers or bools, and values that already are Lox values,
+// callables or class instances. Structs and other types are an error, expose
+// them as native classes instead.
+func FromGo(value interface{}) (*LoxValue, error) {
+	switch goValue := value.(type) {
+	case nil:
+		return NewNilLoxValue(), nil
+	case *LoxValue:
+		if goValue == nil {
+			return NewNilLoxValue(), nil
+		}
+		return goValue, nil
+	case bool:
+		return NewBooleanLoxValue(goValue), nil
+	case string:
+		return NewStringLoxValue(goValue), nil
+	case float64:
+		return NewFloatLoxValue(goValue), nil
+	case GoFunc:
+		return NewCallableLoxValue(goCallable{fn: goValue}), nil
+	case func(args ...interface{}) (interface{}, error):
+		return NewCallableLoxValue(goCallable{fn: goValue}), nil
+	case *LoxList:
+		return NewListLoxValue(goValue), nil
+	case *LoxMap:
+		return NewMapLoxValue(goValue), nil
+	case LoxClassInstance:
+		return NewClassInstanceLoxValue(goValue), nil
+	case LoxCallable:
+		return NewCallableLoxValue(goValue), nil
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewFloatLoxValue(float64(reflected.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewFloatLoxValue(float64(reflected.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloatLoxValue(reflected.Float()), nil
+	case reflect.String:
+		return NewStringLoxValue(reflected.String()), nil
+	case reflect.Bool:
+		return NewBooleanLoxValue(reflected.Bool()), nil
+	case reflect.Slice, reflect.Array:
+		if reflected.Kind() == reflect.Slice && reflected.IsNil() {
+			return NewNilLoxValue(), nil
+		}
+		elements := make([]*LoxValue, 0, reflected.Len())
+		for idx := 0; idx < reflected.Len(); idx++ {
+			element, err := FromGo(reflected.Index(idx).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return NewListLoxValue(NewLoxList(elements)), nil
+	case reflect.Map:
+		if reflected.IsNil() {
+			return NewNilLoxValue(), nil
+		}
+		// Go maps are unordered, sort the keys so scripts see a stable order
+		keys := reflected.MapKeys()
+		sort.Slice(keys, func(a, b int) bool {
+			return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+		})
+		loxMap := NewLoxMap()
+		for _, goKey := range keys {
+			key, err := FromGo(goKey.Interface())
+			if err != nil {
+				return nil, err
+			}
+			element, err := FromGo(reflected.MapIndex(goKey).Interface())
+			if err != nil {
+				return nil, err
+			}
+			if err := loxMap.Set(key, element); err != nil {
+				return nil, err
+			}
+		}
+		return NewMapLoxValue(loxMap), nil
+	case reflect.Pointer, reflect.Interface:
+		if reflected.IsNil() {
+			return NewNilLoxValue(), nil
+		}
+	}
+	return nil, fmt.Errorf("can't convert Go type %T to a Lox value", value)
+}
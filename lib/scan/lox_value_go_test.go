@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoxValue_ToGo(t *testing.T) {
+	inner := NewLoxMap()
+	assert.NoError(t, inner.Set(NewStringLoxValue("a"), NewFloatLoxValue(1)))
+	assert.NoError(t, inner.Set(NewFloatLoxValue(2), NewNilLoxValue()))
+
+	type testCase struct {
+		value    *LoxValue
+		expected interface{}
+	}
+
+	tcs := []testCase{
+		{NewFloatLoxValue(1.5), 1.5},
+		{NewStringLoxValue("s"), "s"},
+		{NewBooleanLoxValue(true), true},
+		{NewNilLoxValue(), nil},
+		{
+			NewListLoxValue(NewLoxList([]*LoxValue{NewFloatLoxValue(1), NewMapLoxValue(inner)})),
+			[]interface{}{1., map[interface{}]interface{}{"a": 1., 2.: nil}},
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("lox_value_to_go_test_case_%d", i),
+			func(t *testing.T) {
+				value, err := tc.value.ToGo()
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, value)
+			},
+		)
+	}
+
+	cyclic := NewLoxList(nil)
+	cyclic.Push(NewListLoxValue(cyclic))
+	_, err := NewListLoxValue(cyclic).ToGo()
+	assert.EqualError(t, err, "can't convert cyclic value to Go")
+
+	// a value met twice without a cycle is fine
+	shared := NewListLoxValue(NewLoxList([]*LoxValue{NewFloatLoxValue(1)}))
+	value, err := NewListLoxValue(NewLoxList([]*LoxValue{shared, shared})).ToGo()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{1.}, []interface{}{1.}}, value)
+}
+
+func TestFromGo(t *testing.T) {
+	type testCase struct {
+		value    interface{}
+		expected string
+	}
+
+	tcs := []testCase{
+		{nil, "nil"},
+		{3, "3"},
+		{uint8(7), "7"},
+		{float32(0.5), "0.5"},
+		{"s", "s"},
+		{false, "false"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, `["a", "b"]`},
+		{map[string]interface{}{"b": []interface{}{true}, "a": nil}, `{"a": nil, "b": [true]}`},
+		{map[int]string{2: "two", 1: "one"}, `{1: "one", 2: "two"}`},
+		{NewFloatLoxValue(4), "4"},
+		{(*int)(nil), "nil"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("from_go_test_case_%d", i),
+			func(t *testing.T) {
+				value, err := FromGo(tc.value)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, value.String())
+			},
+		)
+	}
+
+	_, err := FromGo(struct{}{})
+	assert.EqualError(t, err, "can't convert Go type struct {} to a Lox value")
+	_, err = FromGo(map[interface{}]int{nil: 1, struct{}{}: 2})
+	assert.EqualError(t, err, "can't convert Go type struct {} to a Lox value")
+	_, err = FromGo(make(chan int))
+	assert.EqualError(t, err, "can't convert Go type chan int to a Lox value")
+}
+
+func TestFromGo_Func(t *testing.T) {
+	value, err := FromGo(func(args ...interface{}) (interface{}, error) {
+		sum := 0.
+		for _, arg := range args {
+			sum += arg.(float64)
+		}
+		return sum, nil
+	})
+	assert.NoError(t, err)
+	callable, err := value.Callable()
+	assert.NoError(t, err)
+	result, err := callable.Call([]*LoxValue{NewFloatLoxValue(1), NewFloatLoxValue(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", result.String())
+
+	goValue, err := value.ToGo()
+	assert.NoError(t, err)
+	sum, err := goValue.(GoFunc)(1, 2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, sum)
+}
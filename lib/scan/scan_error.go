@@ -3,20 +3,25 @@ package scan
 import "fmt"
 
 type ScanError struct {
-	line  int
-	where string
-	err   error
+	position Position
+	err      error
 }
 
-func NewScanError(line int, where string, err error) *ScanError {
+func NewScanError(position Position, err error) *ScanError {
 	return &ScanError{
-		line:  line,
-		where: where,
-		err:   err,
+		position: position,
+		err:      err,
 	}
 }
 
 func (se *ScanError) Error() string {
-	// ctx for HAD_ERROR ???
-	return fmt.Sprintf("[Line %d] Error %s: %s", se.line, se.where, se.err.Error())
+	return fmt.Sprintf("[%s] Error: %s", se.position, se.err.Error())
+}
+
+func (se *ScanError) Position() Position {
+	return se.position
+}
+
+func (se *ScanError) Unwrap() error {
+	return se.err
 }
@@ -8,15 +8,36 @@ import (
 
 func (sc *Scanner) ScanTokens() ([]Token, error) {
 	for !sc.IsAtEnd() {
-		sc.start = sc.current
+		sc.start, sc.startOffset = sc.current, sc.currentOffset
+		sc.startPosition = sc.position()
 		if err := sc.scanToken(); err != nil {
 			return nil, err
 		}
 	}
-	sc.tokens = append(sc.tokens, NewToken(EOF, "", nil, sc.line))
+	sc.start, sc.startOffset = sc.current, sc.currentOffset
+	sc.startPosition = sc.position()
+	sc.addToken(EOF)
 	return sc.tokens, nil
 }
 
+// position returns the position of the current token start.
+func (sc *Scanner) position() Position {
+	return Position{
+		File:   sc.file,
+		Line:   sc.line,
+		Column: sc.start - sc.lineStart + 1,
+		Start:  sc.startOffset,
+		End:    sc.startOffset,
+	}
+}
+
+// spanPosition spans everything consumed since the current token start.
+func (sc *Scanner) spanPosition() Position {
+	position := sc.startPosition
+	position.End = sc.currentOffset
+	return position
+}
+
 func (sc *Scanner) scanToken() error {
 	sourceChar := sc.advance()
 	switch sourceChar {
@@ -28,8 +49,14 @@ func (sc *Scanner) scanToken() error {
 		sc.addToken(LEFT_BRACE)
 	case '}':
 		sc.addToken(RIGHT_BRACE)
+	case '[':
+		sc.addToken(LEFT_BRACKET)
+	case ']':
+		sc.addToken(RIGHT_BRACKET)
 	case ',':
 		sc.addToken(COMMA)
+	case ':':
+		sc.addToken(COLON)
 	case '.':
 		sc.addToken(DOT)
 	case '-':
@@ -73,17 +100,16 @@ func (sc *Scanner) scanToken() error {
 		} else if sc.matchNext('*') {
 			var terminated bool
 			for !sc.IsAtEnd() {
-				if sc.matchNext('\n') {
-					sc.line += 1
-				}
 				if sc.matchNext('*') && sc.matchNext('/') {
 					terminated = true
 					break
 				}
-				sc.advance()
+				if sc.advance() == '\n' {
+					sc.newLine()
+				}
 			}
 			if !terminated {
-				return NewScanError(sc.line, strconv.Itoa(sc.current), fmt.Errorf("comment not terminated"))
+				return NewScanError(sc.spanPosition(), fmt.Errorf("comment not terminated"))
 			}
 		} else {
 			sc.addToken(SLASH)
@@ -94,7 +120,7 @@ func (sc *Scanner) scanToken() error {
 		}
 
 	case '\n':
-		sc.line += 1
+		sc.newLine()
 	case ' ', '\r', '\t':
 		break
 
@@ -107,7 +133,7 @@ func (sc *Scanner) scanToken() error {
 			sc.identifier()
 		} else {
 			// todo typing for fmt.Errorf("unexpected character.")
-			return NewScanError(sc.line, strconv.Itoa(sc.current), fmt.Errorf("unexpected character"))
+			return NewScanError(sc.spanPosition(), fmt.Errorf("unexpected character"))
 		}
 	}
 
@@ -120,7 +146,12 @@ func (sc *Scanner) addToken(tokenType TokenType) {
 
 func (sc *Scanner) addTokenWithLiteral(tokenType TokenType, literal *Literal) {
 	lexeme := string(sc.source[sc.start:sc.current])
-	sc.tokens = append(sc.tokens, NewToken(tokenType, lexeme, literal, sc.line))
+	sc.tokens = append(sc.tokens, Token{
+		Type:     tokenType,
+		Lexeme:   lexeme,
+		Literal:  literal,
+		Position: sc.spanPosition(),
+	})
 }
 
 func (sc *Scanner) isDigit(char rune) bool {
@@ -161,7 +192,7 @@ func (sc *Scanner) number() error {
 
 	floatVal, err := strconv.ParseFloat(string(sc.source[sc.start:sc.current]), 64)
 	if err != nil {
-		return NewScanError(sc.line, strconv.Itoa(sc.current), err)
+		return NewScanError(sc.spanPosition(), err)
 	}
 	sc.addTokenWithLiteral(NUMBER, NewLiteral(NewFloatLoxValue(floatVal)))
 	return nil
@@ -169,14 +200,13 @@ func (sc *Scanner) number() error {
 
 func (sc *Scanner) string() error {
 	for sc.peek() != '"' && !sc.IsAtEnd() {
-		if sc.peek() == '\n' {
-			sc.line += 1
+		if sc.advance() == '\n' {
+			sc.newLine()
 		}
-		sc.advance()
 	}
 
 	if sc.IsAtEnd() {
-		return NewScanError(sc.line, strconv.Itoa(sc.current), fmt.Errorf("unterminated string"))
+		return NewScanError(sc.spanPosition(), fmt.Errorf("unterminated string"))
 	}
 	sc.advance()
 
@@ -6,67 +6,83 @@ import (
 	"testing"
 )
 
+// tokenView leaves out columns and offsets, covered by TestScanner_ScanTokensPosition
+type tokenView struct {
+	Type    TokenType
+	Lexeme  string
+	Literal *Literal
+	Line    int
+}
+
+func viewTokens(tokens []Token) []tokenView {
+	views := make([]tokenView, 0, len(tokens))
+	for _, token := range tokens {
+		views = append(views, tokenView{token.Type, token.Lexeme, token.Literal, token.Line})
+	}
+	return views
+}
+
 func TestScanner_ScanTokensOk(t *testing.T) {
 	assert.Equal(t, 1, 1)
 
 	type testCase struct {
 		source       string
-		expectTokens []Token
+		expectTokens []tokenView
 	}
 
 	testCases := []testCase{
 		{
 			`!false; // true.`,
-			[]Token{
-				{BANG, "!", nil, 0},
-				{FALSE, "false", nil, 0},
-				{SEMICOLON, ";", nil, 0},
-				{EOF, "", nil, 0},
+			[]tokenView{
+				{BANG, "!", nil, 1},
+				{FALSE, "false", nil, 1},
+				{SEMICOLON, ";", nil, 1},
+				{EOF, "", nil, 1},
 			},
 		}, {
 			`var average = (min + max) / 2;`,
-			[]Token{
-				{VAR, "var", nil, 0},
-				{IDENTIFIER, "average", nil, 0},
-				{EQUAL, "=", nil, 0},
-				{LEFT_PAREN, "(", nil, 0},
-				{IDENTIFIER, "min", nil, 0},
-				{PLUS, "+", nil, 0},
-				{IDENTIFIER, "max", nil, 0},
-				{RIGHT_PAREN, ")", nil, 0},
-				{SLASH, "/", nil, 0},
-				{NUMBER, "2", NewLiteral(NewFloatLoxValue(2.)), 0},
-				{SEMICOLON, ";", nil, 0},
-				{EOF, "", nil, 0},
+			[]tokenView{
+				{VAR, "var", nil, 1},
+				{IDENTIFIER, "average", nil, 1},
+				{EQUAL, "=", nil, 1},
+				{LEFT_PAREN, "(", nil, 1},
+				{IDENTIFIER, "min", nil, 1},
+				{PLUS, "+", nil, 1},
+				{IDENTIFIER, "max", nil, 1},
+				{RIGHT_PAREN, ")", nil, 1},
+				{SLASH, "/", nil, 1},
+				{NUMBER, "2", NewLiteral(NewFloatLoxValue(2.)), 1},
+				{SEMICOLON, ";", nil, 1},
+				{EOF, "", nil, 1},
 			},
 		}, {
 			`for (var a = 1; a < 10; a = a + 1) {
 						print a;
 					}`,
-			[]Token{
-				{FOR, "for", nil, 0},
-				{LEFT_PAREN, "(", nil, 0},
-				{VAR, "var", nil, 0},
-				{IDENTIFIER, "a", nil, 0},
-				{EQUAL, "=", nil, 0},
-				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 0},
-				{SEMICOLON, ";", nil, 0},
-				{IDENTIFIER, "a", nil, 0},
-				{LESS, "<", nil, 0},
-				{NUMBER, "10", NewLiteral(NewFloatLoxValue(10.)), 0},
-				{SEMICOLON, ";", nil, 0},
-				{IDENTIFIER, "a", nil, 0},
-				{EQUAL, "=", nil, 0},
-				{IDENTIFIER, "a", nil, 0},
-				{PLUS, "+", nil, 0},
-				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 0},
-				{RIGHT_PAREN, ")", nil, 0},
-				{LEFT_BRACE, "{", nil, 0},
-				{PRINT, "print", nil, 1},
+			[]tokenView{
+				{FOR, "for", nil, 1},
+				{LEFT_PAREN, "(", nil, 1},
+				{VAR, "var", nil, 1},
+				{IDENTIFIER, "a", nil, 1},
+				{EQUAL, "=", nil, 1},
+				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 1},
+				{SEMICOLON, ";", nil, 1},
 				{IDENTIFIER, "a", nil, 1},
+				{LESS, "<", nil, 1},
+				{NUMBER, "10", NewLiteral(NewFloatLoxValue(10.)), 1},
 				{SEMICOLON, ";", nil, 1},
-				{RIGHT_BRACE, "}", nil, 2},
-				{EOF, "", nil, 2},
+				{IDENTIFIER, "a", nil, 1},
+				{EQUAL, "=", nil, 1},
+				{IDENTIFIER, "a", nil, 1},
+				{PLUS, "+", nil, 1},
+				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 1},
+				{RIGHT_PAREN, ")", nil, 1},
+				{LEFT_BRACE, "{", nil, 1},
+				{PRINT, "print", nil, 2},
+				{IDENTIFIER, "a", nil, 2},
+				{SEMICOLON, ";", nil, 2},
+				{RIGHT_BRACE, "}", nil, 3},
+				{EOF, "", nil, 3},
 			},
 		}, {
 			` var a = 1;
@@ -74,30 +90,30 @@ func TestScanner_ScanTokensOk(t *testing.T) {
 						print a;
 						a = a + 1; 
 					  }`,
-			[]Token{
-				{VAR, "var", nil, 0},
-				{IDENTIFIER, "a", nil, 0},
-				{EQUAL, "=", nil, 0},
-				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 0},
-				{SEMICOLON, ";", nil, 0},
-				{WHILE, "while", nil, 1},
-				{LEFT_PAREN, "(", nil, 1},
+			[]tokenView{
+				{VAR, "var", nil, 1},
 				{IDENTIFIER, "a", nil, 1},
-				{LESS, "<", nil, 1},
-				{NUMBER, "10", NewLiteral(NewFloatLoxValue(10.)), 1},
-				{RIGHT_PAREN, ")", nil, 1},
-				{LEFT_BRACE, "{", nil, 1},
-				{PRINT, "print", nil, 2},
+				{EQUAL, "=", nil, 1},
+				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 1},
+				{SEMICOLON, ";", nil, 1},
+				{WHILE, "while", nil, 2},
+				{LEFT_PAREN, "(", nil, 2},
 				{IDENTIFIER, "a", nil, 2},
-				{SEMICOLON, ";", nil, 2},
-				{IDENTIFIER, "a", nil, 3},
-				{EQUAL, "=", nil, 3},
+				{LESS, "<", nil, 2},
+				{NUMBER, "10", NewLiteral(NewFloatLoxValue(10.)), 2},
+				{RIGHT_PAREN, ")", nil, 2},
+				{LEFT_BRACE, "{", nil, 2},
+				{PRINT, "print", nil, 3},
 				{IDENTIFIER, "a", nil, 3},
-				{PLUS, "+", nil, 3},
-				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 3},
 				{SEMICOLON, ";", nil, 3},
-				{RIGHT_BRACE, "}", nil, 4},
-				{EOF, "", nil, 4},
+				{IDENTIFIER, "a", nil, 4},
+				{EQUAL, "=", nil, 4},
+				{IDENTIFIER, "a", nil, 4},
+				{PLUS, "+", nil, 4},
+				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 4},
+				{SEMICOLON, ";", nil, 4},
+				{RIGHT_BRACE, "}", nil, 5},
+				{EOF, "", nil, 5},
 			},
 		}, {
 			`if (condition) {
@@ -105,50 +121,50 @@ func TestScanner_ScanTokensOk(t *testing.T) {
 					  } else {
 						print "no";
 					}`,
-			[]Token{
-				{IF, "if", nil, 0},
-				{LEFT_PAREN, "(", nil, 0},
-				{IDENTIFIER, "condition", nil, 0},
-				{RIGHT_PAREN, ")", nil, 0},
-				{LEFT_BRACE, "{", nil, 0},
-				{PRINT, "print", nil, 1},
-				{STRING, "\"yes\"", NewLiteral(NewStringLoxValue("yes")), 1},
-				{SEMICOLON, ";", nil, 1},
-				{RIGHT_BRACE, "}", nil, 2},
-				{ELSE, "else", nil, 2},
-				{LEFT_BRACE, "{", nil, 2},
-				{PRINT, "print", nil, 3},
-				{STRING, "\"no\"", NewLiteral(NewStringLoxValue("no")), 3},
-				{SEMICOLON, ";", nil, 3},
-				{RIGHT_BRACE, "}", nil, 4},
-				{EOF, "", nil, 4},
+			[]tokenView{
+				{IF, "if", nil, 1},
+				{LEFT_PAREN, "(", nil, 1},
+				{IDENTIFIER, "condition", nil, 1},
+				{RIGHT_PAREN, ")", nil, 1},
+				{LEFT_BRACE, "{", nil, 1},
+				{PRINT, "print", nil, 2},
+				{STRING, "\"yes\"", NewLiteral(NewStringLoxValue("yes")), 2},
+				{SEMICOLON, ";", nil, 2},
+				{RIGHT_BRACE, "}", nil, 3},
+				{ELSE, "else", nil, 3},
+				{LEFT_BRACE, "{", nil, 3},
+				{PRINT, "print", nil, 4},
+				{STRING, "\"no\"", NewLiteral(NewStringLoxValue("no")), 4},
+				{SEMICOLON, ";", nil, 4},
+				{RIGHT_BRACE, "}", nil, 5},
+				{EOF, "", nil, 5},
 			},
 		}, {
 			`fun calculation(arg1, arg2) { 
                         return (arg1+45.6)*arg2/3; // parameters calculation
                     }`,
-			[]Token{
-				{FUN, "fun", nil, 0},
-				{IDENTIFIER, "calculation", nil, 0},
-				{LEFT_PAREN, "(", nil, 0},
-				{IDENTIFIER, "arg1", nil, 0},
-				{COMMA, ",", nil, 0},
-				{IDENTIFIER, "arg2", nil, 0},
-				{RIGHT_PAREN, ")", nil, 0},
-				{LEFT_BRACE, "{", nil, 0},
-				{RETURN, "return", nil, 1},
+			[]tokenView{
+				{FUN, "fun", nil, 1},
+				{IDENTIFIER, "calculation", nil, 1},
 				{LEFT_PAREN, "(", nil, 1},
 				{IDENTIFIER, "arg1", nil, 1},
-				{PLUS, "+", nil, 1},
-				{NUMBER, "45.6", NewLiteral(NewFloatLoxValue(45.6)), 1},
-				{RIGHT_PAREN, ")", nil, 1},
-				{STAR, "*", nil, 1},
+				{COMMA, ",", nil, 1},
 				{IDENTIFIER, "arg2", nil, 1},
-				{SLASH, "/", nil, 1},
-				{NUMBER, "3", NewLiteral(NewFloatLoxValue(3)), 1},
-				{SEMICOLON, ";", nil, 1},
-				{RIGHT_BRACE, "}", nil, 2},
-				{EOF, "", nil, 2},
+				{RIGHT_PAREN, ")", nil, 1},
+				{LEFT_BRACE, "{", nil, 1},
+				{RETURN, "return", nil, 2},
+				{LEFT_PAREN, "(", nil, 2},
+				{IDENTIFIER, "arg1", nil, 2},
+				{PLUS, "+", nil, 2},
+				{NUMBER, "45.6", NewLiteral(NewFloatLoxValue(45.6)), 2},
+				{RIGHT_PAREN, ")", nil, 2},
+				{STAR, "*", nil, 2},
+				{IDENTIFIER, "arg2", nil, 2},
+				{SLASH, "/", nil, 2},
+				{NUMBER, "3", NewLiteral(NewFloatLoxValue(3)), 2},
+				{SEMICOLON, ";", nil, 2},
+				{RIGHT_BRACE, "}", nil, 3},
+				{EOF, "", nil, 3},
 			},
 		}, {
 			`class Breakfast {
@@ -171,80 +187,80 @@ func TestScanner_ScanTokensOk(t *testing.T) {
 				    benedict.serve("Noble Reader");
 				`,
 
-			[]Token{
-				{CLASS, "class", nil, 0},
-				{IDENTIFIER, "Breakfast", nil, 0},
-				{LEFT_BRACE, "{", nil, 0},
-				{IDENTIFIER, "init", nil, 1},
-				{LEFT_PAREN, "(", nil, 1},
-				{IDENTIFIER, "meat", nil, 1},
-				{COMMA, ",", nil, 1},
-				{IDENTIFIER, "bread", nil, 1},
-				{RIGHT_PAREN, ")", nil, 1},
+			[]tokenView{
+				{CLASS, "class", nil, 1},
+				{IDENTIFIER, "Breakfast", nil, 1},
 				{LEFT_BRACE, "{", nil, 1},
-				{THIS, "this", nil, 2},
-				{DOT, ".", nil, 2},
+				{IDENTIFIER, "init", nil, 2},
+				{LEFT_PAREN, "(", nil, 2},
 				{IDENTIFIER, "meat", nil, 2},
-				{EQUAL, "=", nil, 2},
-				{IDENTIFIER, "meat", nil, 2},
-				{SEMICOLON, ";", nil, 2},
+				{COMMA, ",", nil, 2},
+				{IDENTIFIER, "bread", nil, 2},
+				{RIGHT_PAREN, ")", nil, 2},
+				{LEFT_BRACE, "{", nil, 2},
 				{THIS, "this", nil, 3},
 				{DOT, ".", nil, 3},
-				{IDENTIFIER, "bread", nil, 3},
+				{IDENTIFIER, "meat", nil, 3},
 				{EQUAL, "=", nil, 3},
-				{IDENTIFIER, "bread", nil, 3},
+				{IDENTIFIER, "meat", nil, 3},
 				{SEMICOLON, ";", nil, 3},
-				{RIGHT_BRACE, "}", nil, 4},
-				{RIGHT_BRACE, "}", nil, 6},
-				{VAR, "var", nil, 7},
-				{IDENTIFIER, "baconAndToast", nil, 7},
-				{EQUAL, "=", nil, 7},
-				{IDENTIFIER, "Breakfast", nil, 7},
-				{LEFT_PAREN, "(", nil, 7},
-				{STRING, "\"bacon\"", NewLiteral(NewStringLoxValue("bacon")), 7},
-				{COMMA, ",", nil, 7},
-				{STRING, "\"toast\"", NewLiteral(NewStringLoxValue("toast")), 7},
-				{RIGHT_PAREN, ")", nil, 7},
-				{SEMICOLON, ";", nil, 7},
+				{THIS, "this", nil, 4},
+				{DOT, ".", nil, 4},
+				{IDENTIFIER, "bread", nil, 4},
+				{EQUAL, "=", nil, 4},
+				{IDENTIFIER, "bread", nil, 4},
+				{SEMICOLON, ";", nil, 4},
+				{RIGHT_BRACE, "}", nil, 5},
+				{RIGHT_BRACE, "}", nil, 7},
+				{VAR, "var", nil, 8},
 				{IDENTIFIER, "baconAndToast", nil, 8},
-				{DOT, ".", nil, 8},
-				{IDENTIFIER, "serve", nil, 8},
+				{EQUAL, "=", nil, 8},
+				{IDENTIFIER, "Breakfast", nil, 8},
 				{LEFT_PAREN, "(", nil, 8},
-				{STRING, "\"Dear Reader\"", NewLiteral(NewStringLoxValue("Dear Reader")), 8},
+				{STRING, "\"bacon\"", NewLiteral(NewStringLoxValue("bacon")), 8},
+				{COMMA, ",", nil, 8},
+				{STRING, "\"toast\"", NewLiteral(NewStringLoxValue("toast")), 8},
 				{RIGHT_PAREN, ")", nil, 8},
 				{SEMICOLON, ";", nil, 8},
-				{CLASS, "class", nil, 10},
-				{IDENTIFIER, "Brunch", nil, 10},
-				{LESS, "<", nil, 10},
-				{IDENTIFIER, "Breakfast", nil, 10},
-				{LEFT_BRACE, "{", nil, 10},
-				{IDENTIFIER, "drink", nil, 11},
-				{LEFT_PAREN, "(", nil, 11},
-				{RIGHT_PAREN, ")", nil, 11},
+				{IDENTIFIER, "baconAndToast", nil, 9},
+				{DOT, ".", nil, 9},
+				{IDENTIFIER, "serve", nil, 9},
+				{LEFT_PAREN, "(", nil, 9},
+				{STRING, "\"Dear Reader\"", NewLiteral(NewStringLoxValue("Dear Reader")), 9},
+				{RIGHT_PAREN, ")", nil, 9},
+				{SEMICOLON, ";", nil, 9},
+				{CLASS, "class", nil, 11},
+				{IDENTIFIER, "Brunch", nil, 11},
+				{LESS, "<", nil, 11},
+				{IDENTIFIER, "Breakfast", nil, 11},
 				{LEFT_BRACE, "{", nil, 11},
-				{PRINT, "print", nil, 12},
-				{STRING, "\"How about a Bloody Mary?\"", NewLiteral(NewStringLoxValue("How about a Bloody Mary?")), 12},
-				{SEMICOLON, ";", nil, 12},
-				{RIGHT_BRACE, "}", nil, 13},
+				{IDENTIFIER, "drink", nil, 12},
+				{LEFT_PAREN, "(", nil, 12},
+				{RIGHT_PAREN, ")", nil, 12},
+				{LEFT_BRACE, "{", nil, 12},
+				{PRINT, "print", nil, 13},
+				{STRING, "\"How about a Bloody Mary?\"", NewLiteral(NewStringLoxValue("How about a Bloody Mary?")), 13},
+				{SEMICOLON, ";", nil, 13},
 				{RIGHT_BRACE, "}", nil, 14},
-				{VAR, "var", nil, 16},
-				{IDENTIFIER, "benedict", nil, 16},
-				{EQUAL, "=", nil, 16},
-				{IDENTIFIER, "Brunch", nil, 16},
-				{LEFT_PAREN, "(", nil, 16},
-				{STRING, "\"ham\"", NewLiteral(NewStringLoxValue("ham")), 16},
-				{COMMA, ",", nil, 16},
-				{STRING, "\"English muffin\"", NewLiteral(NewStringLoxValue("English muffin")), 16},
-				{RIGHT_PAREN, ")", nil, 16},
-				{SEMICOLON, ";", nil, 16},
+				{RIGHT_BRACE, "}", nil, 15},
+				{VAR, "var", nil, 17},
 				{IDENTIFIER, "benedict", nil, 17},
-				{DOT, ".", nil, 17},
-				{IDENTIFIER, "serve", nil, 17},
+				{EQUAL, "=", nil, 17},
+				{IDENTIFIER, "Brunch", nil, 17},
 				{LEFT_PAREN, "(", nil, 17},
-				{STRING, "\"Noble Reader\"", NewLiteral(NewStringLoxValue("Noble Reader")), 17},
+				{STRING, "\"ham\"", NewLiteral(NewStringLoxValue("ham")), 17},
+				{COMMA, ",", nil, 17},
+				{STRING, "\"English muffin\"", NewLiteral(NewStringLoxValue("English muffin")), 17},
 				{RIGHT_PAREN, ")", nil, 17},
 				{SEMICOLON, ";", nil, 17},
-				{EOF, "", nil, 18},
+				{IDENTIFIER, "benedict", nil, 18},
+				{DOT, ".", nil, 18},
+				{IDENTIFIER, "serve", nil, 18},
+				{LEFT_PAREN, "(", nil, 18},
+				{STRING, "\"Noble Reader\"", NewLiteral(NewStringLoxValue("Noble Reader")), 18},
+				{RIGHT_PAREN, ")", nil, 18},
+				{SEMICOLON, ";", nil, 18},
+				{EOF, "", nil, 19},
 			},
 		}, {
 			`var a = 1;
@@ -253,18 +269,18 @@ func TestScanner_ScanTokensOk(t *testing.T) {
 					comment
 					*/
 					var b = 2;`,
-			[]Token{
-				{VAR, "var", nil, 0},
-				{IDENTIFIER, "a", nil, 0},
-				{EQUAL, "=", nil, 0},
-				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 0},
-				{SEMICOLON, ";", nil, 0},
-				{VAR, "var", nil, 5},
-				{IDENTIFIER, "b", nil, 5},
-				{EQUAL, "=", nil, 5},
-				{NUMBER, "2", NewLiteral(NewFloatLoxValue(2.)), 5},
-				{SEMICOLON, ";", nil, 5},
-				{EOF, "", nil, 5},
+			[]tokenView{
+				{VAR, "var", nil, 1},
+				{IDENTIFIER, "a", nil, 1},
+				{EQUAL, "=", nil, 1},
+				{NUMBER, "1", NewLiteral(NewFloatLoxValue(1.)), 1},
+				{SEMICOLON, ";", nil, 1},
+				{VAR, "var", nil, 6},
+				{IDENTIFIER, "b", nil, 6},
+				{EQUAL, "=", nil, 6},
+				{NUMBER, "2", NewLiteral(NewFloatLoxValue(2.)), 6},
+				{SEMICOLON, ";", nil, 6},
+				{EOF, "", nil, 6},
 			},
 		},
 	}
@@ -276,12 +292,76 @@ func TestScanner_ScanTokensOk(t *testing.T) {
 				sc := NewScanner(tc.source)
 				tokens, err := sc.ScanTokens()
 				assert.NoError(t, err)
-				assert.Equal(t, tc.expectTokens, tokens)
+				assert.Equal(t, tc.expectTokens, viewTokens(tokens))
 			},
 		)
 	}
 }
 
+func TestScanner_ScanTokensBrackets(t *testing.T) {
+	tokens, err := NewScanner(`xs[0] = [1];`).ScanTokens()
+	assert.NoError(t, err)
+
+	types := make([]TokenType, 0, len(tokens))
+	for _, token := range tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(
+		t,
+		[]TokenType{
+			IDENTIFIER, LEFT_BRACKET, NUMBER, RIGHT_BRACKET, EQUAL,
+			LEFT_BRACKET, NUMBER, RIGHT_BRACKET, SEMICOLON, EOF,
+		},
+		types,
+	)
+}
+
+func TestScanner_ScanTokensPosition(t *testing.T) {
+	source := "var ж = \"ü\";\n  print ж;"
+	tokens, err := NewFileScanner("script.lox", source).ScanTokens()
+	assert.NoError(t, err)
+
+	positions := make([]Position, 0, len(tokens))
+	for _, token := range tokens {
+		positions = append(positions, token.Position)
+	}
+	assert.Equal(
+		t,
+		[]Position{
+			{"script.lox", 1, 1, 0, 3},
+			{"script.lox", 1, 5, 4, 6},
+			{"script.lox", 1, 7, 7, 8},
+			{"script.lox", 1, 9, 9, 13},
+			{"script.lox", 1, 12, 13, 14},
+			{"script.lox", 2, 3, 17, 22},
+			{"script.lox", 2, 9, 23, 25},
+			{"script.lox", 2, 10, 25, 26},
+			{"script.lox", 2, 11, 26, 26},
+		},
+		positions,
+	)
+	assert.Equal(t, "script.lox:2:3", tokens[5].Position.String())
+}
+
 func TestScanner_ScanTokensFail(t *testing.T) {
-	t.Skip() // todo
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	testCases := []testCase{
+		{"print 1;\nprint \"open;", "[2:7] Error: unterminated string"},
+		{"var a = 1;\n  /* comment", "[2:3] Error: comment not terminated"},
+		{"print 1 # 2;", "[1:9] Error: unexpected character"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(
+			fmt.Sprintf("test_case_%d", i),
+			func(t *testing.T) {
+				_, err := NewScanner(tc.source).ScanTokens()
+				assert.EqualError(t, err, tc.expected)
+			},
+		)
+	}
 }
@@ -1,20 +1,43 @@
 package scan
 
+import "unicode/utf8"
+
 type Scanner struct {
+	file                 string
 	source               []rune
 	tokens               []Token
 	start, current, line int
+	// rune index where the current line begins
+	lineStart int
+	// byte offsets matching start and current
+	startOffset, currentOffset int
+	// position of the token being scanned
+	startPosition Position
 }
 
 func NewScanner(source string) *Scanner {
+	return NewFileScanner("", source)
+}
+
+// NewFileScanner creates a scanner whose token positions refer to file.
+func NewFileScanner(file string, source string) *Scanner {
 	return &Scanner{
+		file:   file,
 		source: []rune(source),
+		line:   1,
 	}
 }
 
 func (sc *Scanner) advance() rune {
 	sc.current += 1
-	return sc.source[sc.current-1]
+	char := sc.source[sc.current-1]
+	sc.currentOffset += utf8.RuneLen(char)
+	return char
+}
+
+func (sc *Scanner) newLine() {
+	sc.line += 1
+	sc.lineStart = sc.current
 }
 
 func (sc *Scanner) matchNext(expected rune) bool {
@@ -24,7 +47,7 @@ func (sc *Scanner) matchNext(expected rune) bool {
 	if sc.source[sc.current] != expected {
 		return false
 	}
-	sc.current += 1
+	sc.advance()
 	return true
 }
 
@@ -18,17 +18,20 @@ type TokenType string
 
 const (
 	// Single-character tokens
-	LEFT_PAREN  TokenType = "LEFT_PAREN"
-	RIGHT_PAREN TokenType = "RIGHT_PAREN"
-	LEFT_BRACE  TokenType = "LEFT_BRACE"
-	RIGHT_BRACE TokenType = "RIGHT_BRACE"
-	COMMA       TokenType = "COMMA"
-	DOT         TokenType = "DOT"
-	MINUS       TokenType = "MINUS"
-	PLUS        TokenType = "PLUS"
-	SEMICOLON   TokenType = "SEMICOLON"
-	SLASH       TokenType = "SLASH"
-	STAR        TokenType = "STAR"
+	LEFT_PAREN    TokenType = "LEFT_PAREN"
+	RIGHT_PAREN   TokenType = "RIGHT_PAREN"
+	LEFT_BRACE    TokenType = "LEFT_BRACE"
+	RIGHT_BRACE   TokenType = "RIGHT_BRACE"
+	LEFT_BRACKET  TokenType = "LEFT_BRACKET"
+	RIGHT_BRACKET TokenType = "RIGHT_BRACKET"
+	COMMA         TokenType = "COMMA"
+	COLON         TokenType = "COLON"
+	DOT           TokenType = "DOT"
+	MINUS         TokenType = "MINUS"
+	PLUS          TokenType = "PLUS"
+	SEMICOLON     TokenType = "SEMICOLON"
+	SLASH         TokenType = "SLASH"
+	STAR          TokenType = "STAR"
 
 	// One or two character tokens
 	BANG       TokenType = "BANG"
@@ -60,6 +63,11 @@ const (
 	OR       TokenType = "OR"
 	CONTINUE TokenType = "CONTINUE"
 	BREAK    TokenType = "BREAK"
+	THROW    TokenType = "THROW"
+	TRY      TokenType = "TRY"
+	CATCH    TokenType = "CATCH"
+	FINALLY  TokenType = "FINALLY"
+	IMPORT   TokenType = "IMPORT"
 
 	PRINT  TokenType = "PRINT"
 	RETURN TokenType = "RETURN"
@@ -91,21 +99,43 @@ var keywordsToToken map[string]TokenType = map[string]TokenType{
 	"while":    WHILE,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"import":   IMPORT,
+}
+
+// Position locates a piece of source. Line and Column are 1-based, Column
+// counts runes. Start and End are byte offsets, End is exclusive.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Start  int
+	End    int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
 type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal *Literal
-	Line    int
+	Position
 }
 
 func NewToken(tokenType TokenType, lexeme string, literal *Literal, line int) Token {
 	return Token{
-		Type:    tokenType,
-		Lexeme:  lexeme,
-		Literal: literal,
-		Line:    line,
+		Type:     tokenType,
+		Lexeme:   lexeme,
+		Literal:  literal,
+		Position: Position{Line: line},
 	}
 }
 
@@ -0,0 +1,200 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// callValue calls the callee below argCount arguments on top of the stack.
+// Lox functions get a frame that runs next, everything else is called right
+// away and replaces the callee and its arguments with the result.
+func (vm *VM) callValue(callee *scan.LoxValue, argCount int, paren *scan.Token) error {
+	callable, err := callee.Callable()
+	if err != nil {
+		return interpret.ConvertToRuntimeError("can only call functions or classes", err, paren)
+	}
+	if err := checkArity(callable, argCount, paren); err != nil {
+		return err
+	}
+
+	calleeSlot := len(vm.stack) - argCount - 1
+	switch calleeType := callable.(type) {
+	case *Closure:
+		return vm.callClosure(calleeType, argCount, calleeType.function.Name())
+	case *BoundMethod:
+		vm.stack[calleeSlot] = calleeType.receiver
+		return vm.callClosure(calleeType.method, argCount, calleeType.method.function.Name())
+	case *Class:
+		if err := vm.limits.Allocate(interpret.InstanceSize, calleeType.name); err != nil {
+			return err
+		}
+		instance := &Instance{class: calleeType, fields: make(map[string]*scan.LoxValue)}
+		vm.stack[calleeSlot] = scan.NewClassInstanceLoxValue(instance)
+		if initializer, ok := calleeType.methods["init"]; ok {
+			return vm.callClosure(initializer, argCount, calleeType.name.Lexeme)
+		}
+		vm.stack = vm.stack[:calleeSlot+1]
+		return nil
+	}
+
+	args := make([]*scan.LoxValue, argCount)
+	copy(args, vm.stack[calleeSlot+1:])
+	result, err := callable.Call(args)
+	if err != nil {
+		var limitErr *interpret.LimitError
+		if !errors.As(err, &limitErr) {
+			err = interpret.ConvertToRuntimeError("native function error", err, paren)
+		}
+		return err
+	}
+	if result == nil {
+		result = scan.NewNilLoxValue()
+	}
+	vm.stack = vm.stack[:calleeSlot]
+	vm.push(result)
+	return nil
+}
+
+func checkArity(callable scan.LoxCallable, argCount int, paren *scan.Token) error {
+	if callable.Arity() != scan.VariadicArity && argCount != callable.Arity() {
+		return interpret.NewRuntimeError(
+			fmt.Sprintf("expected %d arguments but got %d", callable.Arity(), argCount),
+			paren,
+		)
+	}
+	return nil
+}
+
+// callClosure pushes the frame of a call, the callee and its arguments are
+// on the stack already and become the first slots of the frame.
+func (vm *VM) callClosure(closure *Closure, argCount int, name string) error {
+	function := closure.function
+	if function.kind != scriptFunction {
+		if err := vm.limits.Check(function.name); err != nil {
+			return err
+		}
+		if err := vm.limits.EnterCall(function.name); err != nil {
+			// the trace shows the call that didn't fit on the stack
+			var runtimeErr *interpret.RuntimeError
+			if errors.As(err, &runtimeErr) {
+				vm.frames = append(vm.frames, callFrame{closure: closure, name: name})
+				runtimeErr.SetTrace(vm.trace(function.name))
+				vm.frames = vm.frames[:len(vm.frames)-1]
+			}
+			return err
+		}
+		if err := vm.limits.Allocate(interpret.EnvironmentSize, nil); err != nil {
+			vm.limits.ExitCall()
+			return err
+		}
+	}
+	vm.frames = append(vm.frames, callFrame{
+		closure: closure,
+		slots:   len(vm.stack) - argCount - 1,
+		name:    name,
+	})
+	return nil
+}
+
+// popFrame removes the innermost frame along with the exception handlers it installed.
+func (vm *VM) popFrame() {
+	frame := len(vm.frames) - 1
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame >= frame {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+	if vm.frames[frame].closure.function.kind != scriptFunction {
+		vm.limits.ExitCall()
+	}
+	vm.frames = vm.frames[:frame]
+}
+
+// invoke calls the method name of the receiver below argCount arguments
+// without creating a bound method first.
+func (vm *VM) invoke(name *scan.Token, argCount int, paren *scan.Token) error {
+	receiver := vm.peek(argCount)
+	calleeSlot := len(vm.stack) - argCount - 1
+	if receiver.IsClassInstance() {
+		object, _ := receiver.ClassInstance()
+		if instance, ok := object.(*Instance); ok {
+			if field, ok := instance.fields[name.Lexeme]; ok {
+				vm.stack[calleeSlot] = field
+				return vm.callValue(field, argCount, paren)
+			}
+			method, ok := instance.class.methods[name.Lexeme]
+			if !ok {
+				return interpret.NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), name)
+			}
+			if err := checkArity(method, argCount, paren); err != nil {
+				return err
+			}
+			return vm.callClosure(method, argCount, method.function.Name())
+		}
+	}
+
+	callee, err := vm.property(receiver, name)
+	if err != nil {
+		return err
+	}
+	vm.stack[calleeSlot] = callee
+	return vm.callValue(callee, argCount, paren)
+}
+
+// property reads a property of any value that has them.
+func (vm *VM) property(object *scan.LoxValue, name *scan.Token) (*scan.LoxValue, error) {
+	if object.IsList() {
+		list, _ := object.List()
+		return interpret.ListMethod(list, *name, &vm.limits)
+	}
+	if object.IsMap() {
+		loxMap, _ := object.Map()
+		return interpret.MapMethod(loxMap, *name, &vm.limits)
+	}
+	if object.IsString() {
+		return interpret.StringMethod(object.String(), *name, &vm.limits)
+	}
+	if !object.IsClassInstance() {
+		return nil, interpret.NewRuntimeError("only instances have properties", name)
+	}
+	instance, err := object.ClassInstance()
+	if err != nil {
+		return nil, err
+	}
+	return instance.Get(*name)
+}
+
+// superMethod looks name up in the superclass on top of the stack, it
+// replaces the superclass.
+func (vm *VM) superMethod(name *scan.Token) (*Closure, error) {
+	superclass, _ := vm.pop().Callable()
+	method, ok := superclass.(*Class).methods[name.Lexeme]
+	if !ok {
+		return nil, interpret.NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), name)
+	}
+	return method, nil
+}
+
+// inherit copies the methods of the superclass below the class on top of the stack.
+func (vm *VM) inherit(token *scan.Token) error {
+	class, _ := vm.pop().Callable()
+	superclass, err := vm.peek(0).Callable()
+	if err != nil {
+		return interpret.NewRuntimeError(
+			fmt.Sprintf("superclass must be a class. error: %s", err.Error()),
+			token,
+		)
+	}
+	loxSuperclass, ok := superclass.(*Class)
+	if !ok {
+		return interpret.NewRuntimeError(
+			fmt.Sprintf("superclass must be a class declared in Lox, got %s", superclass.String()),
+			token,
+		)
+	}
+	for name, method := range loxSuperclass.methods {
+		class.(*Class).methods[name] = method
+	}
+	return nil
+}
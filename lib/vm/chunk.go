@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type OpCode byte
+
+// Operands follow the opcode: u8 is a single byte, u16 two bytes big endian.
+// Name operands index Chunk.names, constant operands Chunk.constants and
+// function operands Chunk.functions.
+const (
+	OpConstant     OpCode = iota // u16 constant
+	OpNil                        //
+	OpTrue                       //
+	OpFalse                      //
+	OpPop                        //
+	OpGetLocal                   // u8 slot
+	OpSetLocal                   // u8 slot
+	OpGetGlobal                  // u16 name
+	OpDefineGlobal               // u16 name
+	OpSetGlobal                  // u16 name
+	OpGetUpvalue                 // u8 index
+	OpSetUpvalue                 // u8 index
+	OpGetProperty                // u16 name
+	OpSetProperty                // u16 name
+	OpGetSuper                   // u16 name
+	OpEqual                      //
+	OpGreater                    //
+	OpGreaterEqual               //
+	OpLess                       //
+	OpLessEqual                  //
+	OpAdd                        //
+	OpSubtract                   //
+	OpMultiply                   //
+	OpDivide                     //
+	OpNot                        //
+	OpNegate                     //
+	OpPrint                      //
+	OpJump                       // u16 forward offset
+	OpJumpIfFalse                // u16 forward offset, the condition stays on the stack
+	OpLoop                       // u16 backward offset
+	OpCall                       // u8 argument count
+	OpInvoke                     // u16 name, u8 argument count
+	OpSuperInvoke                // u16 name, u8 argument count
+	OpClosure                    // u16 function, then u8 isLocal, u8 index per upvalue
+	OpCloseUpvalue               //
+	OpReturn                     //
+	OpClass                      // u16 name
+	OpInherit                    //
+	OpMethod                     // u16 name
+	OpList                       // u16 element count
+	OpMap                        // u16 entry count
+	OpIndex                      //
+	OpIndexSet                   //
+	OpThrow                      //
+	OpTryBegin                   // u8 handler kind, u16 forward offset to the handler
+	OpTryEnd                     //
+	OpRethrow                    //
+	OpImport                     // u16 name of the imported module
+	OpImportAll                  //
+)
+
+// Kinds of exception handlers: a catch handler receives the thrown value, a
+// finally handler the pending error to rethrow once the finally block is done.
+const (
+	catchHandler byte = iota
+	finallyHandler
+)
+
+// Chunk is the bytecode of a single function. tokens has an entry for every
+// byte of code: the token runtime errors of the instruction are reported at,
+// for the bytes of a name operand the token the name is used at.
+type Chunk struct {
+	code        []byte
+	tokens      []*scan.Token
+	constants   []*scan.LoxValue
+	names       []string
+	nameIndexes map[string]int
+	functions   []*Function
+}
+
+func (c *Chunk) write(b byte, token *scan.Token) {
+	c.code = append(c.code, b)
+	c.tokens = append(c.tokens, token)
+}
+
+func (c *Chunk) writeShort(value int, token *scan.Token) {
+	c.write(byte(value>>8), token)
+	c.write(byte(value), token)
+}
+
+func (c *Chunk) addConstant(value *scan.LoxValue) int {
+	c.constants = append(c.constants, value)
+	return len(c.constants) - 1
+}
+
+// addName returns the index of name, every name is stored once however
+// often the function uses it.
+func (c *Chunk) addName(name string) int {
+	if index, ok := c.nameIndexes[name]; ok {
+		return index
+	}
+	if c.nameIndexes == nil {
+		c.nameIndexes = make(map[string]int)
+	}
+	c.names = append(c.names, name)
+	c.nameIndexes[name] = len(c.names) - 1
+	return len(c.names) - 1
+}
+
+func (c *Chunk) readShort(offset int) int {
+	return int(c.code[offset])<<8 | int(c.code[offset+1])
+}
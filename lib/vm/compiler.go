@@ -0,0 +1,422 @@
+package vm
+
+import (
+	"math"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+const (
+	maxLocals   = math.MaxUint8 + 1
+	maxUpvalues = math.MaxUint8 + 1
+	maxOperand  = math.MaxUint16
+)
+
+type local struct {
+	name string
+	// depth is the scope depth the local is declared at, -1 until its
+	// initializer is compiled
+	depth    int
+	captured bool
+}
+
+type upvalueRef struct {
+	index   byte
+	isLocal bool
+}
+
+// loop collects the jumps of break and continue statements until the loop
+// knows where they go.
+type loop struct {
+	localCount int
+	tryDepth   int
+	breaks     []int
+	continues  []int
+}
+
+// tryContext is a try statement whose exception handler is active: leaving
+// it with break, continue or return removes the handler and runs finally.
+type tryContext struct {
+	finally []parse.Statement
+}
+
+type classCompiler struct {
+	enclosing     *classCompiler
+	hasSuperclass bool
+}
+
+// compiler compiles the body of a single function, nested functions get a
+// compiler of their own. Besides the code it reports the same static errors
+// as interpret.Resolver, so both backends accept the same programs.
+type compiler struct {
+	enclosing  *compiler
+	function   *Function
+	locals     []local
+	upvalues   []upvalueRef
+	scopeDepth int
+	loops      []*loop
+	tries      []tryContext
+	class      *classCompiler
+	// initializing is the global whose initializer is being compiled
+	initializing string
+}
+
+func newCompiler(enclosing *compiler, kind functionKind, name *scan.Token) *compiler {
+	c := &compiler{
+		enclosing: enclosing,
+		function:  &Function{name: name, kind: kind},
+	}
+	if enclosing != nil {
+		c.class = enclosing.class
+	}
+	// slot 0 holds the called function, or the receiver inside methods
+	slotName := ""
+	if kind == methodFunction || kind == initializerFunction {
+		slotName = "this"
+	}
+	c.locals = append(c.locals, local{name: slotName})
+	return c
+}
+
+// Compile compiles a program into the function of its top-level code.
+// Top-level variables become globals, looked up by name when they are used.
+func Compile(stmts []parse.Statement) (*Function, error) {
+	c := newCompiler(nil, scriptFunction, nil)
+	for _, stmt := range stmts {
+		if err := c.statement(stmt); err != nil {
+			return nil, err
+		}
+	}
+	c.emitReturn(nil)
+	return c.function, nil
+}
+
+// CompileExpression compiles expr into a function returning its value.
+func CompileExpression(expr parse.Expression) (*Function, error) {
+	c := newCompiler(nil, scriptFunction, nil)
+	if err := c.expression(expr); err != nil {
+		return nil, err
+	}
+	c.emit(OpReturn, nil)
+	return c.function, nil
+}
+
+func (c *compiler) statement(stmt parse.Statement) error {
+	_, err := stmt.Accept(c)
+	return err
+}
+
+func (c *compiler) statements(stmts []parse.Statement) error {
+	for _, stmt := range stmts {
+		if err := c.statement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) expression(expr parse.Expression) error {
+	_, err := expr.Accept(c)
+	return err
+}
+
+func (c *compiler) chunk() *Chunk {
+	return &c.function.chunk
+}
+
+func (c *compiler) emit(op OpCode, token *scan.Token) {
+	c.chunk().write(byte(op), token)
+}
+
+func (c *compiler) emitByte(b byte, token *scan.Token) {
+	c.chunk().write(b, token)
+}
+
+func (c *compiler) emitShort(value int, token *scan.Token) {
+	c.chunk().writeShort(value, token)
+}
+
+func (c *compiler) emitConstant(value *scan.LoxValue, token *scan.Token) error {
+	index := c.chunk().addConstant(value)
+	if index > maxOperand {
+		return interpret.NewResolveError("too many constants in one function", token)
+	}
+	c.emit(OpConstant, token)
+	c.emitShort(index, token)
+	return nil
+}
+
+// emitName emits op with an operand referring to name.
+func (c *compiler) emitName(op OpCode, name *scan.Token) error {
+	index := c.chunk().addName(name.Lexeme)
+	if index > maxOperand {
+		return interpret.NewResolveError("too many names in one function", name)
+	}
+	c.emit(op, name)
+	c.emitShort(index, name)
+	return nil
+}
+
+// emitJump emits a forward jump with a placeholder offset and returns
+// where the offset is to be patched.
+func (c *compiler) emitJump(op OpCode, token *scan.Token) int {
+	c.emit(op, token)
+	c.emitShort(0xffff, token)
+	return len(c.chunk().code) - 2
+}
+
+// patchJump makes the jump at offset land at the current end of the code.
+func (c *compiler) patchJump(offset int, token *scan.Token) error {
+	jump := len(c.chunk().code) - offset - 2
+	if jump > maxOperand {
+		return interpret.NewResolveError("too much code to jump over", token)
+	}
+	c.chunk().code[offset] = byte(jump >> 8)
+	c.chunk().code[offset+1] = byte(jump)
+	return nil
+}
+
+func (c *compiler) emitLoop(start int, token *scan.Token) error {
+	c.emit(OpLoop, token)
+	offset := len(c.chunk().code) - start + 2
+	if offset > maxOperand {
+		return interpret.NewResolveError("loop body too large", token)
+	}
+	c.emitShort(offset, token)
+	return nil
+}
+
+func (c *compiler) emitReturn(token *scan.Token) {
+	if c.function.kind == initializerFunction {
+		c.emit(OpGetLocal, token)
+		c.emitByte(0, token)
+	} else {
+		c.emit(OpNil, token)
+	}
+	c.emit(OpReturn, token)
+}
+
+func (c *compiler) beginScope() {
+	c.scopeDepth += 1
+}
+
+func (c *compiler) endScope() {
+	c.scopeDepth -= 1
+	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
+		c.popLocal(c.locals[len(c.locals)-1])
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+// popLocals emits the code dropping locals above count from the stack
+// without forgetting them, for jumps out of scopes that keep compiling.
+func (c *compiler) popLocals(count int) {
+	for i := len(c.locals) - 1; i >= count; i -= 1 {
+		c.popLocal(c.locals[i])
+	}
+}
+
+func (c *compiler) popLocal(l local) {
+	if l.captured {
+		c.emit(OpCloseUpvalue, nil)
+	} else {
+		c.emit(OpPop, nil)
+	}
+}
+
+func (c *compiler) addLocal(name string, token *scan.Token) error {
+	if len(c.locals) >= maxLocals {
+		return interpret.NewResolveError("too many local variables in function", token)
+	}
+	c.locals = append(c.locals, local{name: name, depth: -1})
+	return nil
+}
+
+func (c *compiler) declareLocal(name *scan.Token) error {
+	for i := len(c.locals) - 1; i >= 0; i -= 1 {
+		if c.locals[i].depth != -1 && c.locals[i].depth < c.scopeDepth {
+			break
+		}
+		if c.locals[i].name == name.Lexeme {
+			return interpret.NewResolveError("already variable with this name in this scope", name)
+		}
+	}
+	return c.addLocal(name.Lexeme, name)
+}
+
+func (c *compiler) markInitialized() {
+	c.locals[len(c.locals)-1].depth = c.scopeDepth
+}
+
+// declareVariable declares name in the current scope, names at the top
+// level of the script are globals and are defined by defineVariable.
+func (c *compiler) declareVariable(name *scan.Token) error {
+	if c.scopeDepth == 0 {
+		return nil
+	}
+	return c.declareLocal(name)
+}
+
+func (c *compiler) defineVariable(name *scan.Token) error {
+	if c.scopeDepth == 0 {
+		return c.emitName(OpDefineGlobal, name)
+	}
+	c.markInitialized()
+	return nil
+}
+
+// resolveLocal returns the slot of the innermost local called name, -1 if
+// there is none. Locals still being initialized are skipped unless
+// uninitialized is set, assignments to them go to the enclosing variable
+// the same way they do in the tree-walking interpreter.
+func (c *compiler) resolveLocal(name string, uninitialized bool) int {
+	for i := len(c.locals) - 1; i >= 0; i -= 1 {
+		if c.locals[i].name == name && (uninitialized || c.locals[i].depth != -1) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *compiler) resolveUpvalue(name *scan.Token) (int, error) {
+	if c.enclosing == nil {
+		return -1, nil
+	}
+	if slot := c.enclosing.resolveLocal(name.Lexeme, false); slot != -1 {
+		c.enclosing.locals[slot].captured = true
+		return c.addUpvalue(byte(slot), true, name)
+	}
+	index, err := c.enclosing.resolveUpvalue(name)
+	if err != nil || index == -1 {
+		return index, err
+	}
+	return c.addUpvalue(byte(index), false, name)
+}
+
+func (c *compiler) addUpvalue(index byte, isLocal bool, name *scan.Token) (int, error) {
+	for i, existing := range c.upvalues {
+		if existing.index == index && existing.isLocal == isLocal {
+			return i, nil
+		}
+	}
+	if len(c.upvalues) >= maxUpvalues {
+		return 0, interpret.NewResolveError("too many closure variables in function", name)
+	}
+	c.upvalues = append(c.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	return len(c.upvalues) - 1, nil
+}
+
+// checkInitializer reports a variable read in its own initializer. Like
+// the resolver it looks only at the innermost scope.
+func (c *compiler) checkInitializer(name *scan.Token) error {
+	uninitialized := c.scopeDepth == 0 && c.initializing == name.Lexeme
+	if c.scopeDepth > 0 {
+		slot := c.resolveLocal(name.Lexeme, true)
+		uninitialized = slot != -1 && c.locals[slot].depth == -1
+	}
+	if uninitialized {
+		return interpret.NewResolveError("can't read local variable in its own initializer", name)
+	}
+	return nil
+}
+
+func (c *compiler) getVariable(name *scan.Token) error {
+	if slot := c.resolveLocal(name.Lexeme, false); slot != -1 {
+		c.emit(OpGetLocal, name)
+		c.emitByte(byte(slot), name)
+		return nil
+	}
+	index, err := c.resolveUpvalue(name)
+	if err != nil {
+		return err
+	}
+	if index != -1 {
+		c.emit(OpGetUpvalue, name)
+		c.emitByte(byte(index), name)
+		return nil
+	}
+	return c.emitName(OpGetGlobal, name)
+}
+
+func (c *compiler) setVariable(name *scan.Token) error {
+	if slot := c.resolveLocal(name.Lexeme, false); slot != -1 {
+		c.emit(OpSetLocal, name)
+		c.emitByte(byte(slot), name)
+		return nil
+	}
+	index, err := c.resolveUpvalue(name)
+	if err != nil {
+		return err
+	}
+	if index != -1 {
+		c.emit(OpSetUpvalue, name)
+		c.emitByte(byte(index), name)
+		return nil
+	}
+	return c.emitName(OpSetGlobal, name)
+}
+
+// compileFunction compiles a function declaration and emits the closure creating it.
+func (c *compiler) compileFunction(stmt *parse.StmtFunction, kind functionKind) error {
+	fc := newCompiler(c, kind, &stmt.Name)
+	fc.beginScope()
+	for i := range stmt.Params {
+		if err := fc.declareLocal(&stmt.Params[i]); err != nil {
+			return err
+		}
+		fc.markInitialized()
+	}
+	fc.function.arity = len(stmt.Params)
+	if err := fc.statements(stmt.Body); err != nil {
+		return err
+	}
+	fc.emitReturn(nil)
+	fc.function.upvalueCount = len(fc.upvalues)
+
+	c.chunk().functions = append(c.chunk().functions, fc.function)
+	if len(c.chunk().functions) > maxOperand {
+		return interpret.NewResolveError("too many functions in one function", &stmt.Name)
+	}
+	c.emit(OpClosure, &stmt.Name)
+	c.emitShort(len(c.chunk().functions)-1, &stmt.Name)
+	for _, ref := range fc.upvalues {
+		isLocal := byte(0)
+		if ref.isLocal {
+			isLocal = 1
+		}
+		c.emitByte(isLocal, &stmt.Name)
+		c.emitByte(ref.index, &stmt.Name)
+	}
+	return nil
+}
+
+// exitTries leaves the try statements above depth on the way to a jump out
+// of them: their handlers are removed and finally blocks run, innermost first.
+func (c *compiler) exitTries(depth int) error {
+	tries := c.tries
+	defer func() {
+		c.tries = tries
+	}()
+	for i := len(tries) - 1; i >= depth; i -= 1 {
+		c.emit(OpTryEnd, nil)
+		// a jump inside the finally block must not run it again
+		c.tries = tries[:i]
+		if tries[i].finally != nil {
+			if err := c.block(tries[i].finally); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *compiler) block(stmts []parse.Statement) error {
+	c.beginScope()
+	if err := c.statements(stmts); err != nil {
+		return err
+	}
+	c.endScope()
+	return nil
+}
@@ -0,0 +1,252 @@
+package vm
+
+import (
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+var binaryOps = map[scan.TokenType]OpCode{
+	scan.EQUAL_EQUAL:   OpEqual,
+	scan.GREATER:       OpGreater,
+	scan.GREATER_EQUAL: OpGreaterEqual,
+	scan.LESS:          OpLess,
+	scan.LESS_EQUAL:    OpLessEqual,
+	scan.PLUS:          OpAdd,
+	scan.MINUS:         OpSubtract,
+	scan.STAR:          OpMultiply,
+	scan.SLASH:         OpDivide,
+}
+
+func (c *compiler) VisitBinaryExpr(expr *parse.BinaryExpression) (interface{}, error) {
+	if err := c.expression(expr.Left); err != nil {
+		return nil, err
+	}
+	if err := c.expression(expr.Right); err != nil {
+		return nil, err
+	}
+	if expr.Operator.Type == scan.BANG_EQUAL {
+		c.emit(OpEqual, &expr.Operator)
+		c.emit(OpNot, &expr.Operator)
+		return nil, nil
+	}
+	c.emit(binaryOps[expr.Operator.Type], &expr.Operator)
+	return nil, nil
+}
+
+func (c *compiler) VisitGroupingExpr(expr *parse.GroupingExpression) (interface{}, error) {
+	return nil, c.expression(expr.Expr)
+}
+
+func (c *compiler) VisitLiteralExpr(expr *parse.LiteralExpression) (interface{}, error) {
+	value := expr.Value.Value
+	switch {
+	case value.IsNil():
+		c.emit(OpNil, nil)
+	case value.IsBoolean() && value.Bool():
+		c.emit(OpTrue, nil)
+	case value.IsBoolean():
+		c.emit(OpFalse, nil)
+	default:
+		return nil, c.emitConstant(value, nil)
+	}
+	return nil, nil
+}
+
+func (c *compiler) VisitUnaryExpr(expr *parse.UnaryExpression) (interface{}, error) {
+	if err := c.expression(expr.Right); err != nil {
+		return nil, err
+	}
+	if expr.Operator.Type == scan.MINUS {
+		c.emit(OpNegate, &expr.Operator)
+	} else {
+		c.emit(OpNot, &expr.Operator)
+	}
+	return nil, nil
+}
+
+func (c *compiler) VisitVariableExpr(expr *parse.VariableExpression) (interface{}, error) {
+	if err := c.checkInitializer(&expr.Name); err != nil {
+		return nil, err
+	}
+	return nil, c.getVariable(&expr.Name)
+}
+
+func (c *compiler) VisitAssignExpr(expr *parse.AssignExpression) (interface{}, error) {
+	if err := c.expression(expr.Value); err != nil {
+		return nil, err
+	}
+	return nil, c.setVariable(&expr.Name)
+}
+
+// VisitLogicalExpr leaves the left operand as the result when it decides
+// the outcome, the right operand is evaluated only otherwise.
+func (c *compiler) VisitLogicalExpr(expr *parse.LogicalExpression) (interface{}, error) {
+	if err := c.expression(expr.Left); err != nil {
+		return nil, err
+	}
+	var endJump int
+	if expr.Operator.Type == scan.OR {
+		elseJump := c.emitJump(OpJumpIfFalse, nil)
+		endJump = c.emitJump(OpJump, nil)
+		if err := c.patchJump(elseJump, &expr.Operator); err != nil {
+			return nil, err
+		}
+	} else {
+		endJump = c.emitJump(OpJumpIfFalse, nil)
+	}
+	c.emit(OpPop, nil)
+	if err := c.expression(expr.Right); err != nil {
+		return nil, err
+	}
+	return nil, c.patchJump(endJump, &expr.Operator)
+}
+
+// VisitCallExpr compiles calls of methods into a single invoke instruction
+// that doesn't create a bound method.
+func (c *compiler) VisitCallExpr(expr *parse.CallExpression) (interface{}, error) {
+	var (
+		op   = OpCall
+		name *scan.Token
+	)
+	switch callee := expr.Callee.(type) {
+	case *parse.GetExpression:
+		if err := c.expression(callee.Object); err != nil {
+			return nil, err
+		}
+		op, name = OpInvoke, &callee.Name
+	case *parse.SuperExpression:
+		// the superclass is pushed after the arguments
+		if err := c.this(callee); err != nil {
+			return nil, err
+		}
+		op, name = OpSuperInvoke, &callee.Method
+	default:
+		if err := c.expression(expr.Callee); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, arg := range expr.Arguments {
+		if err := c.expression(arg); err != nil {
+			return nil, err
+		}
+	}
+	if op == OpSuperInvoke {
+		if err := c.getVariable(&expr.Callee.(*parse.SuperExpression).Keyword); err != nil {
+			return nil, err
+		}
+	}
+	c.emit(op, &expr.Paren)
+	if name != nil {
+		index := c.chunk().addName(name.Lexeme)
+		if index > maxOperand {
+			return nil, interpret.NewResolveError("too many names in one function", name)
+		}
+		c.emitShort(index, name)
+	}
+	c.emitByte(byte(len(expr.Arguments)), &expr.Paren)
+	return nil, nil
+}
+
+func (c *compiler) VisitSuperExpr(expr *parse.SuperExpression) (interface{}, error) {
+	if err := c.this(expr); err != nil {
+		return nil, err
+	}
+	if err := c.getVariable(&expr.Keyword); err != nil {
+		return nil, err
+	}
+	return nil, c.emitName(OpGetSuper, &expr.Method)
+}
+
+// this pushes the receiver of a super expression, the superclass to look
+// the method up in is pushed on top of it right before the lookup.
+func (c *compiler) this(expr *parse.SuperExpression) error {
+	if c.class == nil {
+		return interpret.NewResolveError("can't use 'super' outside of a class", &expr.Keyword)
+	} else if !c.class.hasSuperclass {
+		return interpret.NewResolveError("can't use 'super' in a class with no superclass", &expr.Keyword)
+	}
+	this := scan.Token{Type: scan.THIS, Lexeme: "this", Position: expr.Keyword.Position}
+	return c.getVariable(&this)
+}
+
+func (c *compiler) VisitThisExpr(expr *parse.ThisExpression) (interface{}, error) {
+	if c.class == nil {
+		return nil, interpret.NewResolveError("can't use 'this' outside of a class", &expr.Keyword)
+	}
+	return nil, c.getVariable(&expr.Keyword)
+}
+
+func (c *compiler) VisitSetExpr(expr *parse.SetExpression) (interface{}, error) {
+	if err := c.expression(expr.Object); err != nil {
+		return nil, err
+	}
+	if err := c.expression(expr.Value); err != nil {
+		return nil, err
+	}
+	return nil, c.emitName(OpSetProperty, &expr.Name)
+}
+
+func (c *compiler) VisitGetExpr(expr *parse.GetExpression) (interface{}, error) {
+	if err := c.expression(expr.Object); err != nil {
+		return nil, err
+	}
+	return nil, c.emitName(OpGetProperty, &expr.Name)
+}
+
+func (c *compiler) VisitListExpr(expr *parse.ListExpression) (interface{}, error) {
+	for _, element := range expr.Elements {
+		if err := c.expression(element); err != nil {
+			return nil, err
+		}
+	}
+	if len(expr.Elements) > maxOperand {
+		return nil, interpret.NewResolveError("too many elements in a list literal", &expr.Bracket)
+	}
+	c.emit(OpList, &expr.Bracket)
+	c.emitShort(len(expr.Elements), &expr.Bracket)
+	return nil, nil
+}
+
+func (c *compiler) VisitIndexExpr(expr *parse.IndexExpression) (interface{}, error) {
+	if err := c.expression(expr.Object); err != nil {
+		return nil, err
+	}
+	if err := c.expression(expr.Index); err != nil {
+		return nil, err
+	}
+	c.emit(OpIndex, &expr.Bracket)
+	return nil, nil
+}
+
+func (c *compiler) VisitIndexSetExpr(expr *parse.IndexSetExpression) (interface{}, error) {
+	if err := c.expression(expr.Object); err != nil {
+		return nil, err
+	}
+	if err := c.expression(expr.Index); err != nil {
+		return nil, err
+	}
+	if err := c.expression(expr.Value); err != nil {
+		return nil, err
+	}
+	c.emit(OpIndexSet, &expr.Bracket)
+	return nil, nil
+}
+
+func (c *compiler) VisitMapExpr(expr *parse.MapExpression) (interface{}, error) {
+	for idx, key := range expr.Keys {
+		if err := c.expression(key); err != nil {
+			return nil, err
+		}
+		if err := c.expression(expr.Values[idx]); err != nil {
+			return nil, err
+		}
+	}
+	if len(expr.Keys) > maxOperand {
+		return nil, interpret.NewResolveError("too many entries in a map literal", &expr.Brace)
+	}
+	c.emit(OpMap, &expr.Brace)
+	c.emitShort(len(expr.Keys), &expr.Brace)
+	return nil, nil
+}
@@ -0,0 +1,380 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+func (c *compiler) VisitStmtExpression(stmt *parse.StmtExpression) (interface{}, error) {
+	if err := c.expression(stmt.Expression); err != nil {
+		return nil, err
+	}
+	c.emit(OpPop, nil)
+	return nil, nil
+}
+
+func (c *compiler) VisitStmtPrint(stmt *parse.StmtPrint) (interface{}, error) {
+	if err := c.expression(stmt.Expression); err != nil {
+		return nil, err
+	}
+	c.emit(OpPrint, nil)
+	return nil, nil
+}
+
+func (c *compiler) VisitStmtVar(stmt *parse.StmtVar) (interface{}, error) {
+	if err := c.declareVariable(&stmt.Name); err != nil {
+		return nil, err
+	}
+	if c.scopeDepth == 0 {
+		c.initializing = stmt.Name.Lexeme
+	}
+	if stmt.Initializer != nil {
+		if err := c.expression(stmt.Initializer); err != nil {
+			return nil, err
+		}
+	} else {
+		c.emit(OpNil, &stmt.Name)
+	}
+	c.initializing = ""
+	return nil, c.defineVariable(&stmt.Name)
+}
+
+func (c *compiler) VisitStmtBlock(stmt *parse.StmtBlock) (interface{}, error) {
+	return nil, c.block(stmt.Stmts)
+}
+
+func (c *compiler) VisitStmtIf(stmt *parse.StmtIf) (interface{}, error) {
+	if err := c.expression(stmt.Condition); err != nil {
+		return nil, err
+	}
+	thenJump := c.emitJump(OpJumpIfFalse, nil)
+	c.emit(OpPop, nil)
+	if err := c.statement(stmt.ThenBranch); err != nil {
+		return nil, err
+	}
+	elseJump := c.emitJump(OpJump, nil)
+	if err := c.patchJump(thenJump, nil); err != nil {
+		return nil, err
+	}
+	c.emit(OpPop, nil)
+	if stmt.ElseBranch != nil {
+		if err := c.statement(stmt.ElseBranch); err != nil {
+			return nil, err
+		}
+	}
+	return nil, c.patchJump(elseJump, nil)
+}
+
+// VisitStmtWhile compiles while and for loops. A continue jumps to the
+// increment of a for loop, a break past the pop of the loop condition.
+func (c *compiler) VisitStmtWhile(stmt *parse.StmtWhile) (interface{}, error) {
+	start := len(c.chunk().code)
+	if err := c.expression(stmt.Condition); err != nil {
+		return nil, err
+	}
+	exitJump := c.emitJump(OpJumpIfFalse, nil)
+	c.emit(OpPop, nil)
+
+	current := &loop{localCount: len(c.locals), tryDepth: len(c.tries)}
+	c.loops = append(c.loops, current)
+	err := c.statement(stmt.Body)
+	c.loops = c.loops[:len(c.loops)-1]
+	if err != nil {
+		return nil, err
+	}
+
+	for _, jump := range current.continues {
+		if err := c.patchJump(jump, nil); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.Increment != nil {
+		if err := c.expression(stmt.Increment); err != nil {
+			return nil, err
+		}
+		c.emit(OpPop, nil)
+	}
+	if err := c.emitLoop(start, nil); err != nil {
+		return nil, err
+	}
+
+	if err := c.patchJump(exitJump, nil); err != nil {
+		return nil, err
+	}
+	c.emit(OpPop, nil)
+	for _, jump := range current.breaks {
+		if err := c.patchJump(jump, nil); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (c *compiler) VisitStmtExecuteControl(stmt *parse.StmtExecuteControl) (interface{}, error) {
+	if len(c.loops) == 0 {
+		return nil, interpret.NewResolveError(
+			fmt.Sprintf("can't use '%s' outside of a loop", stmt.Control.Lexeme),
+			&stmt.Control,
+		)
+	}
+	current := c.loops[len(c.loops)-1]
+	if err := c.exitTries(current.tryDepth); err != nil {
+		return nil, err
+	}
+	c.popLocals(current.localCount)
+	jump := c.emitJump(OpJump, &stmt.Control)
+	if stmt.Control.Type == scan.BREAK {
+		current.breaks = append(current.breaks, jump)
+	} else {
+		current.continues = append(current.continues, jump)
+	}
+	return nil, nil
+}
+
+func (c *compiler) VisitStmtFunction(stmt *parse.StmtFunction) (interface{}, error) {
+	if err := c.declareVariable(&stmt.Name); err != nil {
+		return nil, err
+	}
+	// the function may call itself
+	if c.scopeDepth > 0 {
+		c.markInitialized()
+	}
+	if err := c.compileFunction(stmt, plainFunction); err != nil {
+		return nil, err
+	}
+	return nil, c.defineVariable(&stmt.Name)
+}
+
+func (c *compiler) VisitStmtReturn(stmt *parse.StmtReturn) (interface{}, error) {
+	if c.function.kind == scriptFunction {
+		return nil, interpret.NewResolveError("can't return from top-level code", &stmt.Keyword)
+	}
+	if stmt.Value != nil {
+		if c.function.kind == initializerFunction {
+			return nil, interpret.NewResolveError("can't return a value from an initializer", &stmt.Keyword)
+		}
+		if err := c.expression(stmt.Value); err != nil {
+			return nil, err
+		}
+	} else if c.function.kind == initializerFunction {
+		c.emit(OpGetLocal, &stmt.Keyword)
+		c.emitByte(0, &stmt.Keyword)
+	} else {
+		c.emit(OpNil, &stmt.Keyword)
+	}
+
+	if len(c.tries) > 0 {
+		// the value waits in a slot of its own while finally blocks run
+		if err := c.addLocal("", &stmt.Keyword); err != nil {
+			return nil, err
+		}
+		c.markInitialized()
+		err := c.exitTries(0)
+		c.locals = c.locals[:len(c.locals)-1]
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.emit(OpReturn, &stmt.Keyword)
+	return nil, nil
+}
+
+func (c *compiler) VisitStmtClass(stmt *parse.StmtClass) (interface{}, error) {
+	if err := c.declareVariable(&stmt.Name); err != nil {
+		return nil, err
+	}
+	if err := c.emitName(OpClass, &stmt.Name); err != nil {
+		return nil, err
+	}
+	if err := c.defineVariable(&stmt.Name); err != nil {
+		return nil, err
+	}
+
+	class := &classCompiler{enclosing: c.class}
+	c.class = class
+	defer func() {
+		c.class = class.enclosing
+	}()
+
+	if stmt.SuperClass != nil {
+		if stmt.SuperClass.Name.Lexeme == stmt.Name.Lexeme {
+			return nil, interpret.NewResolveError("a class can't inherit from itself", &stmt.SuperClass.Name)
+		}
+		if err := c.getVariable(&stmt.SuperClass.Name); err != nil {
+			return nil, err
+		}
+		// methods capture the superclass as the local super of this scope
+		c.beginScope()
+		if err := c.addLocal("super", &stmt.SuperClass.Name); err != nil {
+			return nil, err
+		}
+		c.markInitialized()
+		if err := c.getVariable(&stmt.Name); err != nil {
+			return nil, err
+		}
+		c.emit(OpInherit, &stmt.SuperClass.Name)
+		class.hasSuperclass = true
+	}
+
+	if err := c.getVariable(&stmt.Name); err != nil {
+		return nil, err
+	}
+	for _, method := range stmt.Methods {
+		stmtFunc := method.(*parse.StmtFunction)
+		kind := methodFunction
+		if stmtFunc.Name.Lexeme == "init" {
+			kind = initializerFunction
+		}
+		if err := c.compileFunction(stmtFunc, kind); err != nil {
+			return nil, err
+		}
+		if err := c.emitName(OpMethod, &stmtFunc.Name); err != nil {
+			return nil, err
+		}
+	}
+	c.emit(OpPop, nil)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+	return nil, nil
+}
+
+func (c *compiler) VisitStmtThrow(stmt *parse.StmtThrow) (interface{}, error) {
+	if err := c.expression(stmt.Value); err != nil {
+		return nil, err
+	}
+	c.emit(OpThrow, &stmt.Keyword)
+	return nil, nil
+}
+
+// VisitStmtImport pushes the module and binds it to the import name, or
+// copies its exports into the globals without one.
+func (c *compiler) VisitStmtImport(stmt *parse.StmtImport) (interface{}, error) {
+	if c.scopeDepth > 0 {
+		return nil, interpret.NewResolveError("can't import outside of top-level code", &stmt.Keyword)
+	}
+	index := c.chunk().addName(stmt.ModulePath())
+	if index > maxOperand {
+		return nil, interpret.NewResolveError("too many names in one function", &stmt.Path)
+	}
+	c.emit(OpImport, &stmt.Path)
+	c.emitShort(index, &stmt.Path)
+	if stmt.Name != nil {
+		return nil, c.defineVariable(stmt.Name)
+	}
+	c.emit(OpImportAll, &stmt.Path)
+	return nil, nil
+}
+
+// VisitStmtTry compiles the try block under an exception handler that jumps
+// to the catch clause. With a finally block the catch clause runs under a
+// handler of its own that jumps to a copy of the finally block ending with
+// a rethrow of the pending error; every other way out of the statement runs
+// finally as well: falling through, break, continue and return compile it
+// in before they jump.
+func (c *compiler) VisitStmtTry(stmt *parse.StmtTry) (interface{}, error) {
+	handlerKind := catchHandler
+	if stmt.CatchName == nil {
+		handlerKind = finallyHandler
+	}
+	handler := c.emitTry(handlerKind, &stmt.Keyword)
+	c.beginScope()
+	if err := c.guarded(stmt.TryBlock, stmt.FinallyBlock); err != nil {
+		return nil, err
+	}
+	c.endScope()
+	exitJumps := []int{c.emitJump(OpJump, nil)}
+	if err := c.patchJump(handler, &stmt.Keyword); err != nil {
+		return nil, err
+	}
+
+	if stmt.CatchName != nil {
+		// the handler leaves the caught value on the stack, it becomes the
+		// exception variable sharing the scope with the catch body
+		c.beginScope()
+		if err := c.declareLocal(stmt.CatchName); err != nil {
+			return nil, err
+		}
+		c.markInitialized()
+		if stmt.FinallyBlock == nil {
+			if err := c.statements(stmt.CatchBlock); err != nil {
+				return nil, err
+			}
+		} else {
+			handler = c.emitTry(finallyHandler, &stmt.Keyword)
+			catchLocals := len(c.locals)
+			if err := c.guarded(stmt.CatchBlock, stmt.FinallyBlock); err != nil {
+				return nil, err
+			}
+			c.popLocals(catchLocals - 1)
+			exitJumps = append(exitJumps, c.emitJump(OpJump, nil))
+			if err := c.patchJump(handler, &stmt.Keyword); err != nil {
+				return nil, err
+			}
+			// the handler drops the locals of the catch body, not the exception variable
+			c.locals = c.locals[:catchLocals]
+			if err := c.finallyLanding(stmt); err != nil {
+				return nil, err
+			}
+		}
+		c.endScope()
+	} else {
+		c.beginScope()
+		if err := c.finallyLanding(stmt); err != nil {
+			return nil, err
+		}
+		c.endScope()
+	}
+
+	for _, jump := range exitJumps {
+		if err := c.patchJump(jump, &stmt.Keyword); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.FinallyBlock != nil {
+		return nil, c.block(stmt.FinallyBlock)
+	}
+	return nil, nil
+}
+
+// finallyLanding is where a finally handler jumps to: the handler leaves
+// the pending error on the stack, it is rethrown after the finally block.
+func (c *compiler) finallyLanding(stmt *parse.StmtTry) error {
+	if err := c.addLocal("", &stmt.Keyword); err != nil {
+		return err
+	}
+	c.markInitialized()
+	if err := c.block(stmt.FinallyBlock); err != nil {
+		return err
+	}
+	c.emit(OpGetLocal, nil)
+	c.emitByte(byte(len(c.locals)-1), nil)
+	c.emit(OpRethrow, nil)
+	return nil
+}
+
+// emitTry installs an exception handler, the returned offset is patched
+// with the jump to the handler code.
+func (c *compiler) emitTry(kind byte, token *scan.Token) int {
+	c.emit(OpTryBegin, token)
+	c.emitByte(kind, token)
+	c.emitShort(0xffff, token)
+	return len(c.chunk().code) - 2
+}
+
+// guarded compiles stmts under the handler installed last and removes the
+// handler after them. finally runs on jumps out of stmts.
+func (c *compiler) guarded(stmts []parse.Statement, finally []parse.Statement) error {
+	c.tries = append(c.tries, tryContext{finally: finally})
+	err := c.statements(stmts)
+	c.tries = c.tries[:len(c.tries)-1]
+	if err != nil {
+		return err
+	}
+	c.emit(OpTryEnd, nil)
+	return nil
+}
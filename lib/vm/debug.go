@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+)
+
+var opNames = map[OpCode]string{
+	OpConstant:     "CONSTANT",
+	OpNil:          "NIL",
+	OpTrue:         "TRUE",
+	OpFalse:        "FALSE",
+	OpPop:          "POP",
+	OpGetLocal:     "GET_LOCAL",
+	OpSetLocal:     "SET_LOCAL",
+	OpGetGlobal:    "GET_GLOBAL",
+	OpDefineGlobal: "DEFINE_GLOBAL",
+	OpSetGlobal:    "SET_GLOBAL",
+	OpGetUpvalue:   "GET_UPVALUE",
+	OpSetUpvalue:   "SET_UPVALUE",
+	OpGetProperty:  "GET_PROPERTY",
+	OpSetProperty:  "SET_PROPERTY",
+	OpGetSuper:     "GET_SUPER",
+	OpEqual:        "EQUAL",
+	OpGreater:      "GREATER",
+	OpGreaterEqual: "GREATER_EQUAL",
+	OpLess:         "LESS",
+	OpLessEqual:    "LESS_EQUAL",
+	OpAdd:          "ADD",
+	OpSubtract:     "SUBTRACT",
+	OpMultiply:     "MULTIPLY",
+	OpDivide:       "DIVIDE",
+	OpNot:          "NOT",
+	OpNegate:       "NEGATE",
+	OpPrint:        "PRINT",
+	OpJump:         "JUMP",
+	OpJumpIfFalse:  "JUMP_IF_FALSE",
+	OpLoop:         "LOOP",
+	OpCall:         "CALL",
+	OpInvoke:       "INVOKE",
+	OpSuperInvoke:  "SUPER_INVOKE",
+	OpClosure:      "CLOSURE",
+	OpCloseUpvalue: "CLOSE_UPVALUE",
+	OpReturn:       "RETURN",
+	OpClass:        "CLASS",
+	OpInherit:      "INHERIT",
+	OpMethod:       "METHOD",
+	OpList:         "LIST",
+	OpMap:          "MAP",
+	OpIndex:        "INDEX",
+	OpIndexSet:     "INDEX_SET",
+	OpThrow:        "THROW",
+	OpTryBegin:     "TRY_BEGIN",
+	OpTryEnd:       "TRY_END",
+	OpRethrow:      "RETHROW",
+	OpImport:       "IMPORT",
+	OpImportAll:    "IMPORT_ALL",
+}
+
+func (op OpCode) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", byte(op))
+}
+
+// Disassemble writes the bytecode of the function, and then of the functions
+// declared in it, in a readable form.
+func (f *Function) Disassemble(writer io.Writer) error {
+	if _, err := fmt.Fprintf(writer, "== %s ==\n", f.Name()); err != nil {
+		return err
+	}
+	chunk := &f.chunk
+	for offset := 0; offset < len(chunk.code); {
+		line, next := chunk.instruction(offset)
+		if _, err := fmt.Fprintf(writer, "%04d %s\n", offset, line); err != nil {
+			return err
+		}
+		offset = next
+	}
+	for _, function := range chunk.functions {
+		if err := function.Disassemble(writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instruction formats the instruction at offset and returns the offset of the next one.
+func (c *Chunk) instruction(offset int) (string, int) {
+	op := OpCode(c.code[offset])
+	switch op {
+	case OpConstant:
+		index := c.readShort(offset + 1)
+		return fmt.Sprintf("%-16s %4d '%s'", op, index, c.constants[index]), offset + 3
+	case OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetProperty, OpSetProperty, OpGetSuper, OpClass, OpMethod, OpImport:
+		index := c.readShort(offset + 1)
+		return fmt.Sprintf("%-16s %4d '%s'", op, index, c.names[index]), offset + 3
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		return fmt.Sprintf("%-16s %4d", op, c.code[offset+1]), offset + 2
+	case OpList, OpMap:
+		return fmt.Sprintf("%-16s %4d", op, c.readShort(offset+1)), offset + 3
+	case OpJump, OpJumpIfFalse:
+		return fmt.Sprintf("%-16s %4d -> %d", op, offset, offset+3+c.readShort(offset+1)), offset + 3
+	case OpLoop:
+		return fmt.Sprintf("%-16s %4d -> %d", op, offset, offset+3-c.readShort(offset+1)), offset + 3
+	case OpInvoke, OpSuperInvoke:
+		index := c.readShort(offset + 1)
+		return fmt.Sprintf("%-16s (%d args) %4d '%s'", op, c.code[offset+3], index, c.names[index]), offset + 4
+	case OpTryBegin:
+		kind := "catch"
+		if c.code[offset+1] == finallyHandler {
+			kind = "finally"
+		}
+		return fmt.Sprintf("%-16s %s -> %d", op, kind, offset+4+c.readShort(offset+2)), offset + 4
+	case OpClosure:
+		function := c.functions[c.readShort(offset+1)]
+		line := fmt.Sprintf("%-16s %4d <%s>", op, c.readShort(offset+1), function.Name())
+		next := offset + 3
+		for i := 0; i < function.upvalueCount; i += 1 {
+			kind := "upvalue"
+			if c.code[next] == 1 {
+				kind = "local"
+			}
+			line += fmt.Sprintf(" %s %d", kind, c.code[next+1])
+			next += 2
+		}
+		return line, next
+	}
+	return op.String(), offset + 1
+}
@@ -0,0 +1,64 @@
+package vm
+
+import (
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// InterpretScript compiles and runs the statements of the script read from
+// name, see interpret.Interpreter.InterpretScript.
+func (vm *VM) InterpretScript(name string, stmts []parse.Statement) error {
+	if path, ok := interpret.ResolveScript(vm.loader, name); ok {
+		if _, ok := vm.modules[path]; !ok {
+			vm.modules[path] = interpret.NewModule(path, vm.globals)
+		}
+		vm.importing = append(vm.importing, path)
+		defer func() {
+			vm.importing = vm.importing[:len(vm.importing)-1]
+		}()
+	}
+	return vm.Interpret(stmts)
+}
+
+// importModule returns the module from the cache or compiles and runs it
+// with globals of its own, every module runs once per VM. token is the
+// module path of the import.
+func (vm *VM) importModule(name string, token *scan.Token) (*interpret.Module, error) {
+	path, err := interpret.ResolveModule(vm.loader, name, token)
+	if err != nil {
+		return nil, err
+	}
+	// the running script is cached already, importing it is still a cycle
+	if err := interpret.CheckImportCycle(vm.importing, path, token); err != nil {
+		return nil, err
+	}
+	if module, ok := vm.modules[path]; ok {
+		return module, nil
+	}
+	stmts, err := interpret.LoadModule(vm.loader, path, token)
+	if err != nil {
+		return nil, err
+	}
+	function, err := Compile(stmts)
+	if err != nil {
+		return nil, err
+	}
+	if err := vm.limits.Allocate(interpret.EnvironmentSize, token); err != nil {
+		return nil, err
+	}
+
+	vm.importing = append(vm.importing, path)
+	defer func() {
+		vm.importing = vm.importing[:len(vm.importing)-1]
+	}()
+
+	globals := make(globalScope)
+	closure := &Closure{vm: vm, function: function, globals: globals}
+	if _, err := vm.callFromGo(scan.NewCallableLoxValue(closure), nil); err != nil {
+		return nil, err
+	}
+	module := interpret.NewModule(path, globals)
+	vm.modules[path] = module
+	return module, nil
+}
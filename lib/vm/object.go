@@ -0,0 +1,163 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type functionKind int
+
+const (
+	scriptFunction functionKind = iota
+	plainFunction
+	methodFunction
+	initializerFunction
+)
+
+// Function is a compiled function: its bytecode and what a closure of it
+// needs to capture. The top-level code of a program is a Function too.
+type Function struct {
+	name         *scan.Token
+	arity        int
+	upvalueCount int
+	kind         functionKind
+	chunk        Chunk
+}
+
+func (f *Function) Name() string {
+	if f.name == nil {
+		return interpret.ScriptFrameName
+	}
+	return f.name.Lexeme
+}
+
+// upvalue is a variable captured by a closure. While the variable is still
+// on the stack the upvalue refers to its slot, once the variable goes out of
+// scope the value is moved into the upvalue.
+type upvalue struct {
+	slot   int
+	open   bool
+	closed *scan.LoxValue
+}
+
+// Closure is a function value: the compiled function with the variables it
+// captured. Calling it from Go, e.g. from a native, runs it on its VM.
+type Closure struct {
+	vm       *VM
+	function *Function
+	upvalues []*upvalue
+	// globals are the top-level variables of the module the function is declared in
+	globals globalScope
+}
+
+func (c *Closure) String() string {
+	return fmt.Sprintf("[function] %s", c.function.Name())
+}
+
+func (c *Closure) Arity() int {
+	return c.function.arity
+}
+
+func (c *Closure) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	return c.vm.callFromGo(scan.NewCallableLoxValue(c), args)
+}
+
+// BoundMethod is a method taken from an instance, this inside of it is the instance.
+type BoundMethod struct {
+	receiver *scan.LoxValue
+	method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.method.String()
+}
+
+func (b *BoundMethod) Arity() int {
+	return b.method.Arity()
+}
+
+func (b *BoundMethod) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	return b.method.vm.callFromGo(scan.NewCallableLoxValue(b), args)
+}
+
+// Class is a class declared in Lox. Inherited methods are copied into the
+// subclass when it is created, so a method lookup is a single map access.
+type Class struct {
+	vm      *VM
+	name    *scan.Token
+	methods map[string]*Closure
+}
+
+func (c *Class) String() string {
+	return fmt.Sprintf("[class] %s", c.name.Lexeme)
+}
+
+func (c *Class) Arity() int {
+	if initializer, ok := c.methods["init"]; ok {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+func (c *Class) Call(args []*scan.LoxValue) (*scan.LoxValue, error) {
+	return c.vm.callFromGo(scan.NewClassLoxValue(c), args)
+}
+
+type Instance struct {
+	class  *Class
+	fields map[string]*scan.LoxValue
+}
+
+func (i *Instance) String() string {
+	return fmt.Sprintf("[class instance] %s", i.class.name.Lexeme)
+}
+
+func (i *Instance) Get(name scan.Token) (*scan.LoxValue, error) {
+	if value, ok := i.fields[name.Lexeme]; ok {
+		return value, nil
+	}
+	if method, ok := i.class.methods[name.Lexeme]; ok {
+		return scan.NewCallableLoxValue(&BoundMethod{receiver: scan.NewClassInstanceLoxValue(i), method: method}), nil
+	}
+	return nil, interpret.NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), &name)
+}
+
+func (i *Instance) Set(name scan.Token, value *scan.LoxValue) error {
+	if _, ok := i.fields[name.Lexeme]; !ok {
+		if err := i.class.vm.limits.Allocate(interpret.FieldSize, &name); err != nil {
+			return err
+		}
+	}
+	i.fields[name.Lexeme] = value
+	return nil
+}
+
+// Fields returns a copy of the fields set on the instance.
+func (i *Instance) Fields() map[string]*scan.LoxValue {
+	fields := make(map[string]*scan.LoxValue, len(i.fields))
+	for name, value := range i.fields {
+		fields[name] = value
+	}
+	return fields
+}
+
+// pendingError carries an error through a finally block that runs because
+// of it, the block ends with rethrowing the error. Scripts never see it: it
+// lives in a local slot that has no name.
+type pendingError struct {
+	err error
+}
+
+func (p *pendingError) String() string {
+	return "[pending error]"
+}
+
+func (p *pendingError) Get(name scan.Token) (*scan.LoxValue, error) {
+	return nil, interpret.NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), &name)
+}
+
+func (p *pendingError) Set(name scan.Token, value *scan.LoxValue) error {
+	return interpret.NewRuntimeError(fmt.Sprintf("undefined property '%s'", name.Lexeme), &name)
+}
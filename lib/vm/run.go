@@ -0,0 +1,408 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+// execute is the dispatch loop. It returns the result once the frame at
+// base returns, or the first error, the ip of the frame that failed is
+// past the failing instruction.
+func (vm *VM) execute(base int) (*scan.LoxValue, error) {
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := &frame.closure.function.chunk
+
+	readByte := func() byte {
+		frame.ip += 1
+		return chunk.code[frame.ip-1]
+	}
+	readShort := func() int {
+		frame.ip += 2
+		return chunk.readShort(frame.ip - 2)
+	}
+	// readName reads a name operand and the token of its bytes, where the
+	// name is used in the source
+	readName := func() (string, *scan.Token) {
+		token := chunk.tokens[frame.ip]
+		return chunk.names[readShort()], token
+	}
+	// refresh follows a change of the innermost frame
+	refresh := func() {
+		frame = &vm.frames[len(vm.frames)-1]
+		chunk = &frame.closure.function.chunk
+	}
+
+	for {
+		vm.limits.Step()
+		token := chunk.tokens[frame.ip]
+		op := OpCode(readByte())
+		switch op {
+		case OpConstant:
+			vm.push(chunk.constants[readShort()])
+		case OpNil:
+			vm.push(scan.NewNilLoxValue())
+		case OpTrue:
+			vm.push(scan.NewBooleanLoxValue(true))
+		case OpFalse:
+			vm.push(scan.NewBooleanLoxValue(false))
+		case OpPop:
+			vm.pop()
+
+		case OpGetLocal:
+			vm.push(vm.stack[frame.slots+int(readByte())])
+		case OpSetLocal:
+			vm.stack[frame.slots+int(readByte())] = vm.peek(0)
+		case OpGetGlobal:
+			name, nameToken := readName()
+			value, ok := frame.closure.globals[name]
+			if !ok {
+				value, ok = vm.natives[name]
+			}
+			if !ok {
+				return nil, interpret.NewRuntimeError("undefined variable", nameToken)
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			name, _ := readName()
+			frame.closure.globals[name] = vm.pop()
+		case OpSetGlobal:
+			name, nameToken := readName()
+			if _, ok := frame.closure.globals[name]; ok {
+				frame.closure.globals[name] = vm.peek(0)
+			} else if _, ok := vm.natives[name]; ok {
+				vm.natives[name] = vm.peek(0)
+			} else {
+				return nil, interpret.NewRuntimeError("undefined variable", nameToken)
+			}
+		case OpGetUpvalue:
+			vm.push(vm.getUpvalue(frame.closure.upvalues[readByte()]))
+		case OpSetUpvalue:
+			vm.setUpvalue(frame.closure.upvalues[readByte()], vm.peek(0))
+
+		case OpGetProperty:
+			_, name := readName()
+			value, err := vm.property(vm.peek(0), name)
+			if err != nil {
+				return nil, err
+			}
+			vm.stack[len(vm.stack)-1] = value
+		case OpSetProperty:
+			_, name := readName()
+			object := vm.peek(1)
+			if !object.IsClassInstance() {
+				return nil, interpret.NewRuntimeError("only instances have fields", name)
+			}
+			instance, _ := object.ClassInstance()
+			value := vm.pop()
+			if err := instance.Set(*name, value); err != nil {
+				return nil, err
+			}
+			vm.stack[len(vm.stack)-1] = value
+		case OpGetSuper:
+			_, name := readName()
+			method, err := vm.superMethod(name)
+			if err != nil {
+				return nil, err
+			}
+			vm.stack[len(vm.stack)-1] = scan.NewCallableLoxValue(&BoundMethod{receiver: vm.peek(0), method: method})
+
+		case OpEqual:
+			right := vm.pop()
+			vm.stack[len(vm.stack)-1] = scan.NewBooleanLoxValue(vm.peek(0).Equal(right))
+		case OpAdd:
+			left, right := vm.peek(1), vm.peek(0)
+			if left.IsString() || right.IsString() {
+				concatenated := left.String() + right.String()
+				if err := vm.limits.Allocate(interpret.StringAllocation(concatenated), token); err != nil {
+					return nil, err
+				}
+				vm.pop()
+				vm.stack[len(vm.stack)-1] = scan.NewStringLoxValue(concatenated)
+				continue
+			}
+			fallthrough
+		case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpSubtract, OpMultiply, OpDivide:
+			result, err := arithmetic(op, vm.peek(1), vm.peek(0), token)
+			if err != nil {
+				return nil, err
+			}
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = result
+		case OpNot:
+			vm.stack[len(vm.stack)-1] = scan.NewBooleanLoxValue(!vm.peek(0).Bool())
+		case OpNegate:
+			number, err := vm.peek(0).Number()
+			if err != nil {
+				return nil, interpret.ConvertToRuntimeError("evaluate expression error", err, token)
+			}
+			vm.stack[len(vm.stack)-1] = scan.NewFloatLoxValue(-1. * number)
+		case OpPrint:
+			if _, err := fmt.Fprintln(vm.writer, vm.pop().String()); err != nil {
+				return nil, err
+			}
+
+		case OpJump:
+			offset := readShort()
+			frame.ip += offset
+		case OpJumpIfFalse:
+			offset := readShort()
+			if !vm.peek(0).Bool() {
+				frame.ip += offset
+			}
+		case OpLoop:
+			offset := readShort()
+			frame.ip -= offset
+			if err := vm.limits.Check(nil); err != nil {
+				return nil, err
+			}
+
+		case OpCall:
+			argCount := int(readByte())
+			if err := vm.callValue(vm.peek(argCount), argCount, token); err != nil {
+				return nil, err
+			}
+			refresh()
+		case OpInvoke:
+			_, name := readName()
+			argCount := int(readByte())
+			if err := vm.invoke(name, argCount, token); err != nil {
+				return nil, err
+			}
+			refresh()
+		case OpSuperInvoke:
+			_, name := readName()
+			argCount := int(readByte())
+			method, err := vm.superMethod(name)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkArity(method, argCount, token); err != nil {
+				return nil, err
+			}
+			if err := vm.callClosure(method, argCount, method.function.Name()); err != nil {
+				return nil, err
+			}
+			refresh()
+		case OpClosure:
+			function := chunk.functions[readShort()]
+			closure := &Closure{
+				vm:       vm,
+				function: function,
+				upvalues: make([]*upvalue, function.upvalueCount),
+				globals:  frame.closure.globals,
+			}
+			for i := range closure.upvalues {
+				isLocal, index := readByte(), int(readByte())
+				if isLocal == 1 {
+					closure.upvalues[i] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(scan.NewCallableLoxValue(closure))
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.stack = vm.stack[:frame.slots]
+			vm.popFrame()
+			if len(vm.frames) == base {
+				return result, nil
+			}
+			vm.push(result)
+			refresh()
+
+		case OpClass:
+			_, name := readName()
+			class := &Class{vm: vm, name: name, methods: make(map[string]*Closure)}
+			vm.push(scan.NewClassLoxValue(class))
+		case OpInherit:
+			if err := vm.inherit(token); err != nil {
+				return nil, err
+			}
+		case OpMethod:
+			name, _ := readName()
+			method, _ := vm.pop().Callable()
+			class, _ := vm.peek(0).Callable()
+			class.(*Class).methods[name] = method.(*Closure)
+
+		case OpList:
+			count := readShort()
+			if err := vm.limits.Allocate(interpret.ListAllocation(count), token); err != nil {
+				return nil, err
+			}
+			elements := make([]*scan.LoxValue, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(scan.NewListLoxValue(scan.NewLoxList(elements)))
+		case OpMap:
+			count := readShort()
+			if err := vm.limits.Allocate(interpret.MapAllocation(count), token); err != nil {
+				return nil, err
+			}
+			loxMap := scan.NewLoxMap()
+			entries := vm.stack[len(vm.stack)-2*count:]
+			for i := 0; i < len(entries); i += 2 {
+				if err := loxMap.Set(entries[i], entries[i+1]); err != nil {
+					return nil, interpret.ConvertToRuntimeError("invalid map key", err, token)
+				}
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(scan.NewMapLoxValue(loxMap))
+		case OpIndex:
+			value, err := index(vm.peek(1), vm.peek(0), token)
+			if err != nil {
+				return nil, err
+			}
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = value
+		case OpIndexSet:
+			if err := vm.setIndex(vm.peek(2), vm.peek(1), vm.peek(0), token); err != nil {
+				return nil, err
+			}
+			value := vm.pop()
+			vm.stack = vm.stack[:len(vm.stack)-1]
+			vm.stack[len(vm.stack)-1] = value
+
+		case OpThrow:
+			return nil, interpret.NewThrowError(vm.pop(), token)
+		case OpTryBegin:
+			kind := readByte()
+			offset := readShort()
+			vm.handlers = append(vm.handlers, handler{
+				kind:   kind,
+				frame:  len(vm.frames) - 1,
+				stack:  len(vm.stack),
+				target: frame.ip + offset,
+			})
+		case OpTryEnd:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OpRethrow:
+			pending, _ := vm.pop().ClassInstance()
+			return nil, pending.(*pendingError).err
+
+		case OpImport:
+			name, nameToken := readName()
+			module, err := vm.importModule(name, nameToken)
+			if err != nil {
+				return nil, err
+			}
+			// the module ran in frames of its own
+			refresh()
+			vm.push(scan.NewClassInstanceLoxValue(module))
+		case OpImportAll:
+			object, _ := vm.pop().ClassInstance()
+			for name, value := range object.(*interpret.Module).Fields() {
+				frame.closure.globals[name] = value
+			}
+
+		default:
+			return nil, interpret.NewRuntimeError(fmt.Sprintf("unknown opcode %d", op), token)
+		}
+	}
+}
+
+// arithmetic runs the binary operators on numbers.
+func arithmetic(op OpCode, left, right *scan.LoxValue, token *scan.Token) (*scan.LoxValue, error) {
+	leftNum, err := left.Number()
+	if err != nil {
+		return nil, interpret.ConvertToRuntimeError("evaluate expression error", err, token)
+	}
+	rightNum, err := right.Number()
+	if err != nil {
+		return nil, interpret.ConvertToRuntimeError("evaluate expression error", err, token)
+	}
+
+	switch op {
+	case OpGreater:
+		return scan.NewBooleanLoxValue(leftNum > rightNum), nil
+	case OpGreaterEqual:
+		return scan.NewBooleanLoxValue(leftNum >= rightNum), nil
+	case OpLess:
+		return scan.NewBooleanLoxValue(leftNum < rightNum), nil
+	case OpLessEqual:
+		return scan.NewBooleanLoxValue(leftNum <= rightNum), nil
+	case OpSubtract:
+		return scan.NewFloatLoxValue(leftNum - rightNum), nil
+	case OpMultiply:
+		return scan.NewFloatLoxValue(leftNum * rightNum), nil
+	case OpDivide:
+		if rightNum == 0. {
+			return nil, interpret.ConvertToRuntimeError(
+				"evaluate expression error",
+				fmt.Errorf("zero division error"),
+				token,
+			)
+		}
+		return scan.NewFloatLoxValue(leftNum / rightNum), nil
+	}
+	return scan.NewFloatLoxValue(leftNum + rightNum), nil
+}
+
+func index(object, key *scan.LoxValue, token *scan.Token) (*scan.LoxValue, error) {
+	if object.IsMap() {
+		loxMap, _ := object.Map()
+		value, ok, err := loxMap.Get(key)
+		if err != nil {
+			return nil, interpret.ConvertToRuntimeError("invalid map key", err, token)
+		}
+		if !ok {
+			return nil, interpret.NewRuntimeError(fmt.Sprintf("undefined map key %s", key.String()), token)
+		}
+		return value, nil
+	}
+	if object.IsString() {
+		position, err := key.Number()
+		if err != nil {
+			return nil, interpret.ConvertToRuntimeError("invalid string index", err, token)
+		}
+		value, err := interpret.StringIndex(object.String(), position)
+		if err != nil {
+			return nil, interpret.ConvertToRuntimeError("invalid string index", err, token)
+		}
+		return value, nil
+	}
+	list, err := object.List()
+	if err != nil {
+		return nil, interpret.ConvertToRuntimeError("only lists, maps and strings can be indexed", err, token)
+	}
+	position, err := key.Number()
+	if err != nil {
+		return nil, interpret.ConvertToRuntimeError("invalid list index", err, token)
+	}
+	value, err := list.Get(position)
+	if err != nil {
+		return nil, interpret.ConvertToRuntimeError("invalid list index", err, token)
+	}
+	return value, nil
+}
+
+func (vm *VM) setIndex(object, key, value *scan.LoxValue, token *scan.Token) error {
+	if object.IsMap() {
+		loxMap, _ := object.Map()
+		lengthBefore := loxMap.Len()
+		if err := loxMap.Set(key, value); err != nil {
+			return interpret.ConvertToRuntimeError("invalid map key", err, token)
+		}
+		if loxMap.Len() > lengthBefore {
+			return vm.limits.Allocate(interpret.MapEntrySize, token)
+		}
+		return nil
+	}
+	list, err := object.List()
+	if err != nil {
+		return interpret.ConvertToRuntimeError("only lists and maps support index assignment", err, token)
+	}
+	position, err := key.Number()
+	if err != nil {
+		return interpret.ConvertToRuntimeError("invalid list index", err, token)
+	}
+	if err := list.Set(position, value); err != nil {
+		return interpret.ConvertToRuntimeError("invalid list index", err, token)
+	}
+	return nil
+}
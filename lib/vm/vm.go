@@ -0,0 +1,349 @@
+// Package vm is the bytecode backend: statements parsed by the parse package
+// are compiled into bytecode which runs on a stack based virtual machine with
+// clox-style closures. It behaves like the tree-walking interpreter of the
+// interpret package and shares its values, natives and errors.
+package vm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+)
+
+type Option func(vm *VM)
+
+// WithMaxSteps limits the number of instructions a single run may execute.
+func WithMaxSteps(steps int) Option {
+	return func(vm *VM) {
+		vm.limits.MaxSteps = steps
+	}
+}
+
+// WithMaxCallDepth limits how deep Lox function calls may nest, see interpret.WithMaxCallDepth.
+func WithMaxCallDepth(depth int) Option {
+	return func(vm *VM) {
+		vm.limits.MaxCallDepth = depth
+	}
+}
+
+// WithMaxMemory limits the approximate number of bytes a single run may allocate.
+func WithMaxMemory(bytes int64) Option {
+	return func(vm *VM) {
+		vm.limits.MaxMemory = bytes
+	}
+}
+
+// WithTimeout limits the wall-clock time of a single run.
+func WithTimeout(timeout time.Duration) Option {
+	return func(vm *VM) {
+		vm.limits.Timeout = timeout
+	}
+}
+
+// WithDeadline stops every run still executing at deadline.
+func WithDeadline(deadline time.Time) Option {
+	return func(vm *VM) {
+		vm.limits.Deadline = deadline
+	}
+}
+
+// WithContext stops execution once ctx is done.
+func WithContext(ctx context.Context) Option {
+	return func(vm *VM) {
+		vm.limits.Context = ctx
+	}
+}
+
+// WithModuleLoader sets where imported modules come from, see interpret.WithModuleLoader.
+func WithModuleLoader(loader interpret.ModuleLoader) Option {
+	return func(vm *VM) {
+		vm.loader = loader
+	}
+}
+
+// globalScope holds the top-level variables of the script or of a module,
+// every closure refers to the scope of the code it was declared in.
+type globalScope map[string]*scan.LoxValue
+
+func (g globalScope) Names() []string {
+	names := make([]string, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g globalScope) Lookup(name string) (*scan.LoxValue, bool) {
+	value, ok := g[name]
+	return value, ok
+}
+
+type callFrame struct {
+	closure *Closure
+	ip      int
+	// slots is the stack index of slot 0 of the frame
+	slots int
+	// name is the function name in stack traces, the class name for initializers
+	name string
+}
+
+type handler struct {
+	kind   byte
+	frame  int
+	stack  int
+	target int
+}
+
+type VM struct {
+	writer io.Writer
+	// natives are the builtins and the values defined by the host, visible
+	// to the script and to every module
+	natives globalScope
+	// globals are the top-level variables of the script
+	globals  globalScope
+	stack    []*scan.LoxValue
+	frames   []callFrame
+	handlers []handler
+	// openUpvalues are upvalues still referring to stack slots, ordered by slot
+	openUpvalues []*upvalue
+	limits       interpret.Limits
+	// loader finds and reads imported modules
+	loader interpret.ModuleLoader
+	// modules caches imported modules by path
+	modules map[string]*interpret.Module
+	// importing is the chain of modules being run, to detect import cycles
+	importing []string
+}
+
+func New(writer io.Writer, options ...Option) *VM {
+	vm := &VM{
+		writer:  writer,
+		natives: make(globalScope),
+		globals: make(globalScope),
+		limits:  interpret.Limits{MaxCallDepth: interpret.DefaultMaxCallDepth},
+		loader:  interpret.NewFileLoader(),
+		modules: make(map[string]*interpret.Module),
+	}
+	for name, native := range interpret.Builtins() {
+		vm.natives[name] = scan.NewCallableLoxValue(native)
+	}
+	for _, option := range options {
+		option(vm)
+	}
+	return vm
+}
+
+// Get returns the value of a global: a top-level variable of a run or a
+// value defined by the host.
+func (vm *VM) Get(name string) (*scan.LoxValue, bool) {
+	if value, ok := vm.globals[name]; ok {
+		return value, true
+	}
+	value, ok := vm.natives[name]
+	return value, ok
+}
+
+// Define sets a global visible to every following run and to imported modules.
+func (vm *VM) Define(name string, value *scan.LoxValue) {
+	vm.natives[name] = value
+}
+
+// DefineNative makes a Go function callable from scripts, see interpret.Interpreter.DefineNative.
+func (vm *VM) DefineNative(name string, arity int, fn interpret.NativeFunc) {
+	vm.Define(name, scan.NewCallableLoxValue(interpret.NewNativeFunction(name, arity, fn)))
+}
+
+// DefineClass makes a native class available to scripts under its name.
+func (vm *VM) DefineClass(class *interpret.NativeClass) {
+	vm.Define(class.Name(), scan.NewClassLoxValue(class))
+}
+
+// Interpret compiles and runs a program. Globals it declares stay defined
+// for the following runs.
+func (vm *VM) Interpret(stmts []parse.Statement) error {
+	function, err := Compile(stmts)
+	if err != nil {
+		return err
+	}
+	_, err = vm.Run(function)
+	return err
+}
+
+// Eval evaluates a top-level expression the way Interpret runs statements.
+func (vm *VM) Eval(expr parse.Expression) (*scan.LoxValue, error) {
+	function, err := CompileExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return vm.Run(function)
+}
+
+// Run runs compiled top-level code with fresh execution limits and returns
+// the value it returns.
+func (vm *VM) Run(function *Function) (*scan.LoxValue, error) {
+	vm.limits.Begin()
+	closure := &Closure{vm: vm, function: function, globals: vm.globals}
+	return vm.callFromGo(scan.NewCallableLoxValue(closure), nil)
+}
+
+// callFromGo calls callee with args and runs the VM until the call returns.
+// It is reentrant: natives called by a script may call back into Lox.
+func (vm *VM) callFromGo(callee *scan.LoxValue, args []*scan.LoxValue) (*scan.LoxValue, error) {
+	base, stackBase := len(vm.frames), len(vm.stack)
+	vm.stack = append(vm.stack, callee)
+	vm.stack = append(vm.stack, args...)
+
+	err := vm.callValue(callee, len(args), nil)
+	var result *scan.LoxValue
+	if err == nil {
+		if len(vm.frames) == base {
+			// natives and classes without an initializer are done already
+			result = vm.pop()
+		} else {
+			result, err = vm.run(base)
+		}
+	}
+	if err != nil {
+		vm.unwind(base, stackBase)
+		return nil, err
+	}
+	return result, nil
+}
+
+// unwind drops the frames of a call that failed, down to base.
+func (vm *VM) unwind(base, stackBase int) {
+	for len(vm.frames) > base {
+		vm.popFrame()
+	}
+	vm.closeUpvalues(stackBase)
+	vm.stack = vm.stack[:stackBase]
+}
+
+func (vm *VM) push(value *scan.LoxValue) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() *scan.LoxValue {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) *scan.LoxValue {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// run executes instructions until the frame at base returns, runtime errors
+// are passed to the exception handlers installed since base.
+func (vm *VM) run(base int) (*scan.LoxValue, error) {
+	for {
+		result, err := vm.execute(base)
+		if err == nil {
+			return result, nil
+		}
+		if err = vm.handle(err, base); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// handle unwinds the stack to the innermost exception handler and resumes
+// execution there. Errors other than runtime errors, e.g. exceeded limits,
+// are not catchable.
+func (vm *VM) handle(err error, base int) error {
+	var runtimeErr *interpret.RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		return err
+	}
+	if runtimeErr.Trace() == nil {
+		runtimeErr.SetTrace(vm.trace(runtimeErr.Token()))
+	}
+	if len(vm.handlers) == 0 || vm.handlers[len(vm.handlers)-1].frame < base {
+		return err
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	for len(vm.frames)-1 > h.frame {
+		vm.popFrame()
+	}
+	vm.closeUpvalues(h.stack)
+	vm.stack = vm.stack[:h.stack]
+	if h.kind == catchHandler {
+		vm.push(runtimeErr.Value())
+	} else {
+		vm.push(scan.NewClassInstanceLoxValue(&pendingError{err: err}))
+	}
+	vm.frames[len(vm.frames)-1].ip = h.target
+	return nil
+}
+
+// trace is the call stack, innermost frame first: the frame that failed is
+// at the error position, every other frame at the call it is running.
+func (vm *VM) trace(token *scan.Token) []interpret.StackFrame {
+	position := scan.Position{}
+	if token != nil {
+		position = token.Position
+	}
+	trace := make([]interpret.StackFrame, 0, len(vm.frames))
+	for i := len(vm.frames) - 1; i >= 0; i -= 1 {
+		frame := &vm.frames[i]
+		if i < len(vm.frames)-1 {
+			position = scan.Position{}
+			if callSite := frame.closure.function.chunk.tokens[frame.ip-1]; callSite != nil {
+				position = callSite.Position
+			}
+		}
+		trace = append(trace, interpret.StackFrame{Function: frame.name, Position: position})
+	}
+	return trace
+}
+
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= slot {
+		if vm.openUpvalues[i-1].slot == slot {
+			return vm.openUpvalues[i-1]
+		}
+		i -= 1
+	}
+	created := &upvalue{slot: slot, open: true}
+	vm.openUpvalues = append(vm.openUpvalues, nil)
+	copy(vm.openUpvalues[i+1:], vm.openUpvalues[i:])
+	vm.openUpvalues[i] = created
+	return created
+}
+
+// closeUpvalues moves the values of slots from last up into their upvalues.
+func (vm *VM) closeUpvalues(last int) {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= last {
+		closing := vm.openUpvalues[i-1]
+		closing.closed = vm.stack[closing.slot]
+		closing.open = false
+		i -= 1
+	}
+	vm.openUpvalues = vm.openUpvalues[:i]
+}
+
+func (vm *VM) getUpvalue(uv *upvalue) *scan.LoxValue {
+	if uv.open {
+		return vm.stack[uv.slot]
+	}
+	return uv.closed
+}
+
+func (vm *VM) setUpvalue(uv *upvalue, value *scan.LoxValue) {
+	if uv.open {
+		vm.stack[uv.slot] = value
+	} else {
+		uv.closed = value
+	}
+}
@@ -0,0 +1,190 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hrumst/gox-lox/lib/interpret"
+	"github.com/hrumst/gox-lox/lib/parse"
+	"github.com/hrumst/gox-lox/lib/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseSource(t *testing.T, source string) []parse.Statement {
+	tokens, err := scan.NewFileScanner("test.lox", source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := parse.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+func TestVM_Interpret(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+	}
+
+	tcs := []testCase{
+		{`print 1 + 2;`, "3\n"},
+		{`var a = 1; { var b = a + 1; { var c = b + 1; print c; } print b; }`, "3\n2\n"},
+		{`fun add(a, b) { return a + b; } print add(1, add(2, 3));`, "6\n"},
+		{`fun f() { var x = 1; fun g() { x = x + 1; return x; } return g; } var g = f(); g(); print g();`, "3\n"},
+		{`{ var x = "closed"; fun f() { print x; } x = "changed"; f(); }`, "changed\n"},
+		{`var i = 0; while (i < 100) { i = i + 1; if (i > 3) break; } print i;`, "4\n"},
+		{`class A { m() { return "A"; } } class B < A { m() { return super.m() + "B"; } } print B().m();`, "AB\n"},
+		{`try { throw 1; } catch (e) { print e; } finally { print 2; }`, "1\n2\n"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("vm_interpret_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				assert.NoError(t, New(buf).Interpret(parseSource(t, tc.source)))
+				assert.Equal(t, tc.expected, buf.String())
+			},
+		)
+	}
+}
+
+func TestVM_Host(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	vm := New(buf)
+	vm.Define("base", scan.NewFloatLoxValue(10))
+	vm.DefineNative("call", 2, func(args []*scan.LoxValue) (*scan.LoxValue, error) {
+		fn, err := interpret.CallableArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn.Call(args[1:])
+	})
+
+	assert.NoError(t, vm.Interpret(parseSource(t, `fun addBase(n) { return base + n; } print call(addBase, 1);`)))
+	assert.Equal(t, "11\n", buf.String())
+
+	value, ok := vm.Get("addBase")
+	assert.True(t, ok)
+	fn, _ := value.Callable()
+	result, err := fn.Call([]*scan.LoxValue{scan.NewFloatLoxValue(5)})
+	assert.NoError(t, err)
+	assert.Equal(t, "15", result.String())
+
+	tokens, _ := scan.NewScanner(`addBase(base)`).ScanTokens()
+	expr, _ := parse.NewParser(tokens).ParseExpression()
+	result, err = vm.Eval(expr)
+	assert.NoError(t, err)
+	assert.Equal(t, "20", result.String())
+
+	// a failed run leaves the vm usable
+	assert.Error(t, vm.Interpret(parseSource(t, `fun f() { return 1 / 0; } f();`)))
+	assert.NoError(t, vm.Interpret(parseSource(t, `print addBase(1);`)))
+	assert.Equal(t, "11\n11\n", buf.String())
+	assert.Empty(t, vm.stack)
+	assert.Empty(t, vm.frames)
+	assert.Empty(t, vm.handlers)
+}
+
+func TestVM_Import(t *testing.T) {
+	type testCase struct {
+		source   string
+		expected string
+		err      string
+	}
+
+	loader := interpret.NewMapLoader(map[string]string{
+		"a.lox":     `import "b.lox";`,
+		"b.lox":     `import "a.lox";`,
+		"host.lox":  `var scaled = base * 2;`,
+		"fail.lox":  "var x = 1;\nprint x.y;",
+		"state.lox": `var n = 0; fun next() { n = n + 1; return n; }`,
+	})
+	tcs := []testCase{
+		{`import s from "state.lox"; var n = 10; s.next(); print s.next(); print n;`, "2\n10\n", ""},
+		{`import "host.lox"; print scaled;`, "20\n", ""},
+		{`import "a.lox";`, "", "import cycle: a.lox -> b.lox -> a.lox\nat b.lox:1:8"},
+		{"print 1;\nimport \"fail.lox\";", "1\n", "only instances have properties\nat fail.lox:2:9"},
+	}
+
+	for i, tc := range tcs {
+		t.Run(
+			fmt.Sprintf("vm_import_test_case_%d", i),
+			func(t *testing.T) {
+				buf := bytes.NewBufferString("")
+				vm := New(buf, WithModuleLoader(loader))
+				vm.Define("base", scan.NewFloatLoxValue(10))
+				err := vm.Interpret(parseSource(t, tc.source))
+				assert.Equal(t, tc.expected, buf.String())
+				if tc.err == "" {
+					assert.NoError(t, err)
+					return
+				}
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				// the VM is usable after a failed import
+				assert.NoError(t, vm.Interpret(parseSource(t, `print "ok";`)))
+			},
+		)
+	}
+}
+
+func TestVM_ManyNameReferences(t *testing.T) {
+	// a name is stored once per function however often it is used
+	source := "var x = 0;\n" + strings.Repeat("x = x + 1;\n", 40000) + "print x;"
+	buf := bytes.NewBufferString("")
+	assert.NoError(t, New(buf).Interpret(parseSource(t, source)))
+	assert.Equal(t, "40000\n", buf.String())
+
+	// errors are still reported where the name is used
+	err := New(buf).Interpret(parseSource(t, "var a = 1;\nprint a;\nprint  missing;"))
+	var runtimeErr *interpret.RuntimeError
+	if assert.ErrorAs(t, err, &runtimeErr) {
+		assert.Equal(t, "missing", runtimeErr.Token().Lexeme)
+		assert.Equal(t, "test.lox:3:8", runtimeErr.Token().Position.String())
+	}
+	err = New(buf).Interpret(parseSource(t, "class A {}\nvar a = A();\na.m();"))
+	if assert.ErrorAs(t, err, &runtimeErr) {
+		assert.ErrorContains(t, err, "undefined property 'm'")
+		assert.Equal(t, "test.lox:3:3", runtimeErr.Token().Position.String())
+	}
+}
+
+func TestFunction_Disassemble(t *testing.T) {
+	function, err := Compile(parseSource(t, `fun add(a) { var b = 1; fun get() { return a + b; } return get; }
+print add(1)();`))
+	assert.NoError(t, err)
+
+	buf := bytes.NewBufferString("")
+	assert.NoError(t, function.Disassemble(buf))
+	assert.Equal(t, `== script ==
+0000 CLOSURE             0 <add>
+0003 DEFINE_GLOBAL       0 'add'
+0006 GET_GLOBAL          0 'add'
+0009 CONSTANT            0 '1'
+0012 CALL                1
+0014 CALL                0
+0016 PRINT
+0017 NIL
+0018 RETURN
+== add ==
+0000 CONSTANT            0 '1'
+0003 CLOSURE             0 <get> local 1 local 2
+0010 GET_LOCAL           3
+0012 RETURN
+0013 NIL
+0014 RETURN
+== get ==
+0000 GET_UPVALUE         0
+0002 GET_UPVALUE         1
+0004 ADD
+0005 RETURN
+0006 NIL
+0007 RETURN
+`, buf.String())
+}